@@ -35,6 +35,98 @@ type SlingshotTenantSpec struct {
 
 	// VNIBlockName specifies the name of the VNI block.
 	VNIBlockName string `json:"vniBlockName"`
+
+	// VLAN configures the VLAN of the Tenant network.
+	// +optional
+	VLAN VLANSpec `json:"vlan,omitempty"`
+}
+
+// VLAN modes of a SlingshotTenant
+const (
+	// VLANModeUntagged makes the tenant VLAN the native VLAN of the edge ports
+	VLANModeUntagged = "untagged"
+
+	// VLANModeTagged only allows tagged traffic on the edge ports
+	VLANModeTagged = "tagged"
+)
+
+// Drift policies of a SlingshotTenant
+const (
+	// DriftPolicyRevert reverts the changes made in Fabric Manager
+	DriftPolicyRevert = "Revert"
+
+	// DriftPolicyReport only reports the changes made in Fabric Manager
+	DriftPolicyReport = "Report"
+)
+
+// VLAN statuses of a SlingshotTenant
+const (
+	// VLANStatusOnline is the status of a VLAN that carries traffic
+	VLANStatusOnline = "ONLINE"
+
+	// VLANStatusOffline is the status of a VLAN that does not carry traffic
+	VLANStatusOffline = "OFFLINE"
+)
+
+// VLANSpec represents the VLAN configuration for the Tenant network.
+type VLANSpec struct {
+	// ID is the VLAN ID requested for the Tenant. When zero, a VLAN ID is allocated.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=4094
+	// +optional
+	ID int `json:"id,omitempty"`
+
+	// Mode is untagged, where the VLAN is the native VLAN of the edge ports,
+	// or tagged, where untagged traffic is not allowed. Defaults to untagged.
+	// +kubebuilder:validation:Enum=untagged;tagged
+	// +optional
+	Mode string `json:"mode,omitempty"`
+
+	// AllowedVLANs are the IDs of additional VLANs allowed on the edge ports.
+	// +optional
+	AllowedVLANs []int `json:"allowedVLANs,omitempty"`
+
+	// Status is the status of the VLAN in Fabric Manager. Defaults to ONLINE.
+	// +kubebuilder:validation:Enum=ONLINE;OFFLINE
+	// +optional
+	Status string `json:"status,omitempty"`
+
+	// AutoRetry is the link auto-retry policy of the port policy. When not
+	// set, the setting of Fabric Manager is kept.
+	// +optional
+	AutoRetry *LinkRecoveryPolicy `json:"autoRetry,omitempty"`
+
+	// HeadShellReset is the head-shell reset policy of the port policy. When
+	// not set, the setting of Fabric Manager is kept.
+	// +optional
+	HeadShellReset *LinkRecoveryPolicy `json:"headShellReset,omitempty"`
+
+	// DriftPolicy is Revert, where changes made to the VLAN and its port
+	// policy in Fabric Manager are reverted, or Report, where they are only
+	// reported in the VLANReady condition. Defaults to Revert.
+	// +kubebuilder:validation:Enum=Revert;Report
+	// +optional
+	DriftPolicy string `json:"driftPolicy,omitempty"`
+}
+
+// LinkRecoveryPolicy represents how Fabric Manager recovers the links of the edge ports.
+type LinkRecoveryPolicy struct {
+	// Enabled turns the recovery on.
+	Enabled bool `json:"enabled"`
+
+	// Always applies the recovery to every link down event.
+	// +optional
+	Always bool `json:"always,omitempty"`
+
+	// Retries is the number of recovery attempts.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Retries int `json:"retries,omitempty"`
+
+	// DurationSeconds is the time the recovery is attempted for.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	DurationSeconds int `json:"durationSeconds,omitempty"`
 }
 
 // VNIPartition represents the VNI partition configuration for the Tenant network.
@@ -52,10 +144,110 @@ type SlingshotTenantStatus struct {
 	// Message provides a simple description of the current status of the SlingshotTenant resource.
 	// This can be used to communicate the operational state to users.
 	Message string `json:"message,omitempty"`
+
+	// ObservedGeneration is the generation of the SlingshotTenant the status was computed for.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest observations of the tenant network in Fabric Manager.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// VLANID is the VLAN allocated to the tenant.
+	VLANID int `json:"vlanID,omitempty"`
+
+	// PartitionSelfLink is the Fabric Manager link of the VNI partition.
+	PartitionSelfLink string `json:"partitionSelfLink,omitempty"`
+
+	// VNIRanges are the VNI ranges of the VNI partition, including the VNIs
+	// allocated when only a VNI count is requested.
+	VNIRanges []string `json:"vniRanges,omitempty"`
+
+	// VNIBlockSelfLink is the Fabric Manager link of the VNI block.
+	VNIBlockSelfLink string `json:"vniBlockSelfLink,omitempty"`
+
+	// EdgePortDFAs are the edge port DFAs resolved from the tenant xnames.
+	EdgePortDFAs []int `json:"edgePortDFAs,omitempty"`
+
+	// EnforcementTaskLink is the Fabric Manager link of the last VNI block enforcement task.
+	EnforcementTaskLink string `json:"enforcementTaskLink,omitempty"`
+
+	// EnforcementStage is the stage of the last VNI block enforcement task.
+	EnforcementStage string `json:"enforcementStage,omitempty"`
+
+	// EnforcementSubStage is the sub-stage of the last VNI block enforcement task.
+	EnforcementSubStage string `json:"enforcementSubStage,omitempty"`
+
+	// EnforcementAddVNIs are the VNIs the last VNI block enforcement task adds to the edge ports.
+	EnforcementAddVNIs []int `json:"enforcementAddVNIs,omitempty"`
+
+	// EnforcementRemoveVNIs are the VNIs the last VNI block enforcement task removes from the edge ports.
+	EnforcementRemoveVNIs []int `json:"enforcementRemoveVNIs,omitempty"`
+
+	// EnforcementDeadline is the time by which the last VNI block enforcement
+	// task must finish. A task that did not finish by then is retried.
+	// +optional
+	EnforcementDeadline *metav1.Time `json:"enforcementDeadline,omitempty"`
+
+	// EnforcementRetries is the number of times the VNI block enforcement was
+	// retried since it last finished.
+	EnforcementRetries int `json:"enforcementRetries,omitempty"`
+
+	// FirstEnforcedTime is when a VNI block enforcement task of the tenant
+	// finished for the first time.
+	// +optional
+	FirstEnforcedTime *metav1.Time `json:"firstEnforcedTime,omitempty"`
+
+	// AppliedVLAN is the VLAN spec last applied to Fabric Manager. With the
+	// Report drift policy, the VLAN is only updated when the spec changes.
+	// +optional
+	AppliedVLAN *VLANSpec `json:"appliedVLAN,omitempty"`
+
+	// AppliedVNI is the VNI partition and VNI block spec last applied to
+	// Fabric Manager. They are only recreated when the spec changes.
+	// +optional
+	AppliedVNI *AppliedVNISpec `json:"appliedVNI,omitempty"`
+}
+
+// AppliedVNISpec is the VNI partition and VNI block spec applied to Fabric Manager
+type AppliedVNISpec struct {
+	// VNIPartition is the applied VNI partition.
+	VNIPartition VNIPartition `json:"vnipartition,omitempty"`
+
+	// VNIBlockName is the name of the applied VNI block.
+	VNIBlockName string `json:"vniBlockName,omitempty"`
 }
 
+// Condition types of a SlingshotTenant
+const (
+	// ConditionPartitionReady is true when the VNI partition of the tenant exists
+	ConditionPartitionReady = "PartitionReady"
+
+	// ConditionVLANReady is true when the VLAN and port policy of the tenant exist
+	ConditionVLANReady = "VLANReady"
+
+	// ConditionVNIBlockReady is true when the VNI block of the tenant exists
+	ConditionVNIBlockReady = "VNIBlockReady"
+
+	// ConditionEnforcementComplete is true when the last VNI block enforcement task finished
+	ConditionEnforcementComplete = "EnforcementComplete"
+
+	// ConditionDegraded is true when the last reconciliation of the tenant failed
+	ConditionDegraded = "Degraded"
+
+	// ConditionTeardownComplete is set while the tenant network is deleted and
+	// is true once it has been removed from Fabric Manager
+	ConditionTeardownComplete = "TeardownComplete"
+)
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Tenant",type=string,JSONPath=`.spec.tenantname`
+//+kubebuilder:printcolumn:name="VLAN",type=integer,JSONPath=`.status.vlanID`
+//+kubebuilder:printcolumn:name="Enforcement",type=string,JSONPath=`.status.enforcementStage`
+//+kubebuilder:printcolumn:name="Degraded",type=string,JSONPath=`.status.conditions[?(@.type=="Degraded")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // SlingshotTenant is the Schema for the slingshottenants API
 type SlingshotTenant struct {
@@ -0,0 +1,465 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	tapmsv1alpha2 "github.hpe.com/hpe/sshot-net-operator/api/tapms/v1alpha2"
+)
+
+const (
+	// MaxVNI is the highest VNI that can be assigned to a tenant
+	MaxVNI = 65535
+
+	// MaxVNICount is the highest number of VNIs that can be requested
+	MaxVNICount = 65535
+
+	// MaxVLANID is the highest VLAN ID that can be assigned to a tenant
+	MaxVLANID = 4094
+)
+
+// Labels linking a SlingshotTenant to its TAPMS Tenant
+const (
+	// LabelTenantName is the tenant name of the SlingshotTenant
+	LabelTenantName = "slingshot.hpe.com/tenant-name"
+
+	// LabelTenant is the name of the TAPMS Tenant resource
+	LabelTenant = "slingshot.hpe.com/tenant"
+
+	// LabelTenantNamespace is the namespace of the TAPMS Tenant resource
+	LabelTenantNamespace = "slingshot.hpe.com/tenant-namespace"
+)
+
+// SetupWebhookWithManager registers the SlingshotTenant webhooks with the manager
+func (r *SlingshotTenant) SetupWebhookWithManager(mgr ctrl.Manager, defaults SlingshotTenantDefaults) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithDefaulter(&SlingshotTenantDefaulter{Client: mgr.GetClient(), Defaults: defaults}).
+		WithValidator(&SlingshotTenantValidator{Client: mgr.GetClient()}).
+		Complete()
+}
+
+// SlingshotTenantDefaults are the values set on the fields a SlingshotTenant leaves empty
+// +kubebuilder:object:generate=false
+type SlingshotTenantDefaults struct {
+	// VNIBlockName is the default VNI block name
+	VNIBlockName string
+
+	// TenantVersion is the default version of the TAPMS Tenant
+	TenantVersion string
+
+	// VNICount is the number of VNIs requested when neither a count nor ranges are set
+	VNICount int
+}
+
+//+kubebuilder:webhook:path=/mutate-slingshot-hpe-com-v1alpha1-slingshottenant,mutating=true,failurePolicy=fail,sideEffects=None,groups=slingshot.hpe.com,resources=slingshottenants,verbs=create;update,versions=v1alpha1,name=mslingshottenant.kb.io,admissionReviewVersions=v1
+
+// SlingshotTenantDefaulter fills in the fields a SlingshotTenant leaves empty
+// and labels it with its TAPMS Tenant
+// +kubebuilder:object:generate=false
+type SlingshotTenantDefaulter struct {
+	Client   client.Reader
+	Defaults SlingshotTenantDefaults
+}
+
+var _ webhook.CustomDefaulter = &SlingshotTenantDefaulter{}
+
+// Default sets the defaults of a SlingshotTenant
+func (d *SlingshotTenantDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	sshotTenant, ok := obj.(*SlingshotTenant)
+	if !ok {
+		return fmt.Errorf("expected a SlingshotTenant, got %T", obj)
+	}
+
+	//a tenant being deleted is left as it is, so its network can be torn down
+	if !sshotTenant.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	spec := &sshotTenant.Spec
+	if spec.VNIBlockName == "" {
+		spec.VNIBlockName = d.Defaults.VNIBlockName
+	}
+	if spec.TenantVersion == "" {
+		spec.TenantVersion = d.Defaults.TenantVersion
+	}
+	if spec.VNIPartition.VNICount == 0 && len(spec.VNIPartition.VNIRange) == 0 {
+		spec.VNIPartition.VNICount = d.Defaults.VNICount
+	}
+
+	return d.label(ctx, sshotTenant)
+}
+
+// label links the SlingshotTenant to its TAPMS Tenant. Labels that would be
+// invalid are left out and a missing Tenant is reported by the validating webhook
+func (d *SlingshotTenantDefaulter) label(ctx context.Context, sshotTenant *SlingshotTenant) error {
+	labels := map[string]string{LabelTenantName: sshotTenant.Spec.TenantName}
+
+	var tenants tapmsv1alpha2.TenantList
+	if err := d.Client.List(ctx, &tenants); err != nil {
+		return fmt.Errorf("cannot list tenants: %w", err)
+	}
+	for _, tenant := range tenants.Items {
+		if tenant.Spec.TenantName == sshotTenant.Spec.TenantName {
+			labels[LabelTenant] = tenant.Name
+			labels[LabelTenantNamespace] = tenant.Namespace
+			break
+		}
+	}
+
+	for _, key := range []string{LabelTenantName, LabelTenant, LabelTenantNamespace} {
+		value := labels[key]
+		if value == "" || len(validation.IsValidLabelValue(value)) > 0 {
+			delete(sshotTenant.Labels, key)
+			continue
+		}
+		if sshotTenant.Labels == nil {
+			sshotTenant.Labels = make(map[string]string)
+		}
+		sshotTenant.Labels[key] = value
+	}
+
+	return nil
+}
+
+//+kubebuilder:webhook:path=/validate-slingshot-hpe-com-v1alpha1-slingshottenant,mutating=false,failurePolicy=fail,sideEffects=None,groups=slingshot.hpe.com,resources=slingshottenants,verbs=create;update,versions=v1alpha1,name=vslingshottenant.kb.io,admissionReviewVersions=v1
+
+// SlingshotTenantValidator rejects SlingshotTenants that cannot be reconciled:
+// malformed or overlapping VNI ranges, a missing VNI block name, a VNI count
+// the ranges cannot satisfy or a TAPMS Tenant that does not exist
+// +kubebuilder:object:generate=false
+type SlingshotTenantValidator struct {
+	Client client.Reader
+}
+
+var _ webhook.CustomValidator = &SlingshotTenantValidator{}
+
+// ValidateCreate validates a new SlingshotTenant
+func (v *SlingshotTenantValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	sshotTenant, ok := obj.(*SlingshotTenant)
+	if !ok {
+		return nil, fmt.Errorf("expected a SlingshotTenant, got %T", obj)
+	}
+
+	return nil, v.validate(ctx, sshotTenant)
+}
+
+// ValidateUpdate validates a SlingshotTenant whose spec changed. Metadata
+// only updates, such as the removal of the finalizer once the TAPMS Tenant
+// is gone, are always allowed
+func (v *SlingshotTenantValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldTenant, ok := oldObj.(*SlingshotTenant)
+	if !ok {
+		return nil, fmt.Errorf("expected a SlingshotTenant, got %T", oldObj)
+	}
+	sshotTenant, ok := newObj.(*SlingshotTenant)
+	if !ok {
+		return nil, fmt.Errorf("expected a SlingshotTenant, got %T", newObj)
+	}
+
+	if !sshotTenant.DeletionTimestamp.IsZero() || reflect.DeepEqual(oldTenant.Spec, sshotTenant.Spec) {
+		return nil, nil
+	}
+
+	return nil, v.validate(ctx, sshotTenant)
+}
+
+// ValidateDelete allows every deletion
+func (v *SlingshotTenantValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *SlingshotTenantValidator) validate(ctx context.Context, sshotTenant *SlingshotTenant) error {
+	specPath := field.NewPath("spec")
+	allErrs := ValidateSpec(sshotTenant.Spec, specPath)
+
+	if sshotTenant.Spec.TenantName != "" {
+		err := v.validateTenant(ctx, sshotTenant.Spec.TenantName, specPath.Child("tenantname"))
+		if err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+
+	if len(allErrs) == 0 {
+		errs, err := v.validateOverlaps(ctx, sshotTenant, specPath.Child("vnipartition", "vniRanges"))
+		if err != nil {
+			return apierrors.NewInternalError(err)
+		}
+		allErrs = append(allErrs, errs...)
+
+		errs, err = v.validateVLANConflicts(ctx, sshotTenant, specPath.Child("vlan", "id"))
+		if err != nil {
+			return apierrors.NewInternalError(err)
+		}
+		allErrs = append(allErrs, errs...)
+	}
+
+	if len(allErrs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("SlingshotTenant").GroupKind(), sshotTenant.Name, allErrs)
+	}
+
+	return nil
+}
+
+// validateTenant checks that a TAPMS Tenant with the tenant name exists
+func (v *SlingshotTenantValidator) validateTenant(ctx context.Context, tenantName string, path *field.Path) *field.Error {
+	var tenants tapmsv1alpha2.TenantList
+	if err := v.Client.List(ctx, &tenants); err != nil {
+		return field.InternalError(path, fmt.Errorf("cannot list tenants: %w", err))
+	}
+
+	for _, tenant := range tenants.Items {
+		if tenant.Spec.TenantName == tenantName {
+			return nil
+		}
+	}
+
+	return field.NotFound(path, tenantName)
+}
+
+// validateOverlaps checks that the VNI ranges do not overlap with the VNI
+// ranges of the other SlingshotTenants
+func (v *SlingshotTenantValidator) validateOverlaps(ctx context.Context, sshotTenant *SlingshotTenant, path *field.Path) (field.ErrorList, error) {
+	if len(sshotTenant.Spec.VNIPartition.VNIRange) == 0 {
+		return nil, nil
+	}
+
+	var sshotTenants SlingshotTenantList
+	if err := v.Client.List(ctx, &sshotTenants); err != nil {
+		return nil, fmt.Errorf("cannot list slingshot tenants: %w", err)
+	}
+
+	var allErrs field.ErrorList
+	for i, vniRange := range sshotTenant.Spec.VNIPartition.VNIRange {
+		r, _ := ParseVNIRange(vniRange)
+		for _, other := range sshotTenants.Items {
+			if other.Namespace == sshotTenant.Namespace && other.Name == sshotTenant.Name {
+				continue
+			}
+			//the VNIs allocated to a tenant that only requests a count are in its status
+			otherRanges := other.Spec.VNIPartition.VNIRange
+			if len(otherRanges) == 0 {
+				otherRanges = other.Status.VNIRanges
+			}
+			for _, otherRange := range otherRanges {
+				o, err := ParseVNIRange(otherRange)
+				if err != nil {
+					continue
+				}
+				if r.Overlaps(o) {
+					allErrs = append(allErrs, field.Invalid(path.Index(i), vniRange,
+						fmt.Sprintf("overlaps with VNI range %s of slingshot tenant %s/%s", otherRange, other.Namespace, other.Name)))
+				}
+			}
+		}
+	}
+
+	return allErrs, nil
+}
+
+// validateVLANConflicts checks that the requested VLAN ID is not the VLAN ID
+// of another SlingshotTenant
+func (v *SlingshotTenantValidator) validateVLANConflicts(ctx context.Context, sshotTenant *SlingshotTenant, path *field.Path) (field.ErrorList, error) {
+	vlanID := sshotTenant.Spec.VLAN.ID
+	if vlanID == 0 {
+		return nil, nil
+	}
+
+	var sshotTenants SlingshotTenantList
+	if err := v.Client.List(ctx, &sshotTenants); err != nil {
+		return nil, fmt.Errorf("cannot list slingshot tenants: %w", err)
+	}
+
+	var allErrs field.ErrorList
+	for _, other := range sshotTenants.Items {
+		if other.Namespace == sshotTenant.Namespace && other.Name == sshotTenant.Name {
+			continue
+		}
+		//the VLAN ID allocated to a tenant that does not request one is in its status
+		otherID := other.Spec.VLAN.ID
+		if otherID == 0 {
+			otherID = other.Status.VLANID
+		}
+		if otherID == vlanID {
+			allErrs = append(allErrs, field.Invalid(path, vlanID,
+				fmt.Sprintf("is the VLAN ID of slingshot tenant %s/%s", other.Namespace, other.Name)))
+		}
+	}
+
+	return allErrs, nil
+}
+
+// VNIRange is an inclusive range of VNIs
+// +kubebuilder:object:generate=false
+type VNIRange struct {
+	Start int
+	End   int
+}
+
+// Size returns the number of VNIs in the range
+func (r VNIRange) Size() int {
+	return r.End - r.Start + 1
+}
+
+// Overlaps returns true when the ranges have at least one VNI in common
+func (r VNIRange) Overlaps(o VNIRange) bool {
+	return r.Start <= o.End && o.Start <= r.End
+}
+
+func (r VNIRange) String() string {
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// ParseVNIRange parses a VNI range of the form "start-end", or a single VNI
+func ParseVNIRange(vniRange string) (VNIRange, error) {
+	bounds := strings.Split(vniRange, "-")
+	if len(bounds) == 1 {
+		bounds = append(bounds, bounds[0])
+	}
+	if len(bounds) != 2 {
+		return VNIRange{}, fmt.Errorf("expected start-end or a single VNI")
+	}
+
+	start, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return VNIRange{}, fmt.Errorf("start is not a number: %s", bounds[0])
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+	if err != nil {
+		return VNIRange{}, fmt.Errorf("end is not a number: %s", bounds[1])
+	}
+
+	if start < 0 || end > MaxVNI {
+		return VNIRange{}, fmt.Errorf("VNIs must be between 0 and %d", MaxVNI)
+	}
+	if start > end {
+		return VNIRange{}, fmt.Errorf("start is greater than end")
+	}
+
+	return VNIRange{Start: start, End: end}, nil
+}
+
+// ValidateSpec checks the fields of a SlingshotTenant spec that do not depend
+// on other resources
+func ValidateSpec(spec SlingshotTenantSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if spec.TenantName == "" {
+		allErrs = append(allErrs, field.Required(path.Child("tenantname"), ""))
+	}
+	if spec.VNIBlockName == "" {
+		allErrs = append(allErrs, field.Required(path.Child("vniBlockName"), ""))
+	}
+
+	allErrs = append(allErrs, validateVLAN(spec.VLAN, path.Child("vlan"))...)
+
+	partitionPath := path.Child("vnipartition")
+	vniCount := spec.VNIPartition.VNICount
+	if vniCount < 0 || vniCount > MaxVNICount {
+		allErrs = append(allErrs, field.Invalid(partitionPath.Child("vniCount"), vniCount,
+			fmt.Sprintf("must be between 0 and %d", MaxVNICount)))
+	}
+
+	var ranges []VNIRange
+	rangesPath := partitionPath.Child("vniRanges")
+	for i, vniRange := range spec.VNIPartition.VNIRange {
+		r, err := ParseVNIRange(vniRange)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(rangesPath.Index(i), vniRange, err.Error()))
+			continue
+		}
+		ranges = append(ranges, r)
+	}
+	if len(ranges) != len(spec.VNIPartition.VNIRange) {
+		return allErrs
+	}
+
+	//the ranges of a tenant must not overlap each other
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	size := 0
+	for i, r := range ranges {
+		size += r.Size()
+		if i > 0 && r.Overlaps(ranges[i-1]) {
+			allErrs = append(allErrs, field.Invalid(rangesPath, spec.VNIPartition.VNIRange,
+				fmt.Sprintf("VNI ranges %s and %s overlap", ranges[i-1], r)))
+		}
+	}
+
+	if len(ranges) > 0 && vniCount > size {
+		allErrs = append(allErrs, field.Invalid(partitionPath.Child("vniCount"), vniCount,
+			fmt.Sprintf("the VNI ranges only contain %d VNIs", size)))
+	}
+
+	return allErrs
+}
+
+// validateVLAN checks the VLAN ID, the additional VLAN IDs and the options of
+// a VLAN spec
+func validateVLAN(vlan VLANSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if vlan.ID < 0 || vlan.ID > MaxVLANID {
+		allErrs = append(allErrs, field.Invalid(path.Child("id"), vlan.ID,
+			fmt.Sprintf("must be between 1 and %d, or 0 to allocate a VLAN ID", MaxVLANID)))
+	}
+	for i, allowed := range vlan.AllowedVLANs {
+		if allowed < 1 || allowed > MaxVLANID {
+			allErrs = append(allErrs, field.Invalid(path.Child("allowedVLANs").Index(i), allowed,
+				fmt.Sprintf("must be between 1 and %d", MaxVLANID)))
+		}
+	}
+
+	switch vlan.Mode {
+	case "", VLANModeUntagged, VLANModeTagged:
+	default:
+		allErrs = append(allErrs, field.NotSupported(path.Child("mode"), vlan.Mode, []string{VLANModeUntagged, VLANModeTagged}))
+	}
+	switch vlan.Status {
+	case "", VLANStatusOnline, VLANStatusOffline:
+	default:
+		allErrs = append(allErrs, field.NotSupported(path.Child("status"), vlan.Status, []string{VLANStatusOnline, VLANStatusOffline}))
+	}
+	switch vlan.DriftPolicy {
+	case "", DriftPolicyRevert, DriftPolicyReport:
+	default:
+		allErrs = append(allErrs, field.NotSupported(path.Child("driftPolicy"), vlan.DriftPolicy, []string{DriftPolicyRevert, DriftPolicyReport}))
+	}
+
+	allErrs = append(allErrs, validateLinkRecoveryPolicy(vlan.AutoRetry, path.Child("autoRetry"))...)
+	allErrs = append(allErrs, validateLinkRecoveryPolicy(vlan.HeadShellReset, path.Child("headShellReset"))...)
+
+	return allErrs
+}
+
+func validateLinkRecoveryPolicy(policy *LinkRecoveryPolicy, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if policy == nil {
+		return allErrs
+	}
+
+	if policy.Retries < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("retries"), policy.Retries, "must not be negative"))
+	}
+	if policy.DurationSeconds < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("durationSeconds"), policy.DurationSeconds, "must not be negative"))
+	}
+
+	return allErrs
+}
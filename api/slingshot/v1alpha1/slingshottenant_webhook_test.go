@@ -0,0 +1,252 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	tapmsv1alpha2 "github.hpe.com/hpe/sshot-net-operator/api/tapms/v1alpha2"
+)
+
+func newValidator(t *testing.T) *SlingshotTenantValidator {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := tapmsv1alpha2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&tapmsv1alpha2.Tenant{
+			ObjectMeta: metav1.ObjectMeta{Name: "vcluster-blue", Namespace: "tenants"},
+			Spec:       tapmsv1alpha2.TenantSpec{TenantName: "vcluster-blue"},
+		},
+		&SlingshotTenant{
+			ObjectMeta: metav1.ObjectMeta{Name: "red", Namespace: "slingshot-tenants"},
+			Spec: SlingshotTenantSpec{
+				TenantName:   "vcluster-red",
+				VNIBlockName: "block",
+				VNIPartition: VNIPartition{VNIRange: []string{"1000-1999"}},
+				VLAN:         VLANSpec{ID: 100},
+			},
+		},
+		&SlingshotTenant{
+			ObjectMeta: metav1.ObjectMeta{Name: "green", Namespace: "slingshot-tenants"},
+			Spec: SlingshotTenantSpec{
+				TenantName:   "vcluster-green",
+				VNIBlockName: "block",
+				VNIPartition: VNIPartition{VNICount: 100},
+			},
+			Status: SlingshotTenantStatus{VNIRanges: []string{"3000-3099"}, VLANID: 7},
+		},
+	).Build()
+
+	return &SlingshotTenantValidator{Client: c}
+}
+
+func newSlingshotTenant(vniCount int, vniRanges ...string) *SlingshotTenant {
+	return &SlingshotTenant{
+		ObjectMeta: metav1.ObjectMeta{Name: "blue", Namespace: "slingshot-tenants"},
+		Spec: SlingshotTenantSpec{
+			TenantName:   "vcluster-blue",
+			VNIBlockName: "block",
+			VNIPartition: VNIPartition{VNICount: vniCount, VNIRange: vniRanges},
+		},
+	}
+}
+
+func TestValidateCreate(t *testing.T) {
+	tests := []struct {
+		name         string
+		modify       func(s *SlingshotTenant)
+		expectedErrs []string
+	}{
+		{name: "count only", modify: func(s *SlingshotTenant) {}},
+		{name: "ranges", modify: func(s *SlingshotTenant) {
+			s.Spec.VNIPartition = VNIPartition{VNICount: 20, VNIRange: []string{"100-109", "2000-2009", "5000"}}
+		}},
+		{name: "missing VNI block name", modify: func(s *SlingshotTenant) { s.Spec.VNIBlockName = "" },
+			expectedErrs: []string{"spec.vniBlockName: Required value"}},
+		{name: "unknown tenant", modify: func(s *SlingshotTenant) { s.Spec.TenantName = "vcluster-green" },
+			expectedErrs: []string{`spec.tenantname: Not found: "vcluster-green"`}},
+		{name: "negative count", modify: func(s *SlingshotTenant) { s.Spec.VNIPartition.VNICount = -1 },
+			expectedErrs: []string{"spec.vnipartition.vniCount: Invalid value: -1"}},
+		{name: "malformed ranges", modify: func(s *SlingshotTenant) {
+			s.Spec.VNIPartition.VNIRange = []string{"100-109", "200-250-300", "300-x", "400-300", "65000-70000"}
+		}, expectedErrs: []string{
+			`spec.vnipartition.vniRanges[1]: Invalid value: "200-250-300": expected start-end or a single VNI`,
+			`spec.vnipartition.vniRanges[2]: Invalid value: "300-x": end is not a number`,
+			`spec.vnipartition.vniRanges[3]: Invalid value: "400-300": start is greater than end`,
+			`spec.vnipartition.vniRanges[4]: Invalid value: "65000-70000": VNIs must be between 0 and 65535`,
+		}},
+		{name: "overlapping ranges", modify: func(s *SlingshotTenant) {
+			s.Spec.VNIPartition.VNIRange = []string{"200-300", "100-200"}
+		}, expectedErrs: []string{"VNI ranges 100-200 and 200-300 overlap"}},
+		{name: "count larger than ranges", modify: func(s *SlingshotTenant) {
+			s.Spec.VNIPartition = VNIPartition{VNICount: 11, VNIRange: []string{"100-109"}}
+		}, expectedErrs: []string{"spec.vnipartition.vniCount: Invalid value: 11: the VNI ranges only contain 10 VNIs"}},
+		{name: "overlap with other tenant", modify: func(s *SlingshotTenant) {
+			s.Spec.VNIPartition.VNIRange = []string{"100-109", "1990-2009"}
+		}, expectedErrs: []string{
+			`spec.vnipartition.vniRanges[1]: Invalid value: "1990-2009": overlaps with VNI range 1000-1999 of slingshot tenant slingshot-tenants/red`,
+		}},
+		{name: "overlap with allocated VNIs", modify: func(s *SlingshotTenant) {
+			s.Spec.VNIPartition.VNIRange = []string{"3050-3059"}
+		}, expectedErrs: []string{
+			`spec.vnipartition.vniRanges[0]: Invalid value: "3050-3059": overlaps with VNI range 3000-3099 of slingshot tenant slingshot-tenants/green`,
+		}},
+		{name: "VLAN options", modify: func(s *SlingshotTenant) {
+			s.Spec.VLAN = VLANSpec{ID: 200, Mode: VLANModeTagged, AllowedVLANs: []int{300}, Status: VLANStatusOffline,
+				AutoRetry: &LinkRecoveryPolicy{Enabled: true, Retries: 3, DurationSeconds: 60}, DriftPolicy: DriftPolicyReport}
+		}},
+		{name: "invalid VLAN options", modify: func(s *SlingshotTenant) {
+			s.Spec.VLAN = VLANSpec{ID: 4095, Mode: "trunk", AllowedVLANs: []int{300, 0}, Status: "DOWN"}
+		}, expectedErrs: []string{
+			"spec.vlan.id: Invalid value: 4095",
+			"spec.vlan.allowedVLANs[1]: Invalid value: 0",
+			`spec.vlan.mode: Unsupported value: "trunk"`,
+			`spec.vlan.status: Unsupported value: "DOWN"`,
+		}},
+		{name: "invalid link recovery policies", modify: func(s *SlingshotTenant) {
+			s.Spec.VLAN = VLANSpec{
+				AutoRetry:      &LinkRecoveryPolicy{Enabled: true, Retries: -1},
+				HeadShellReset: &LinkRecoveryPolicy{Enabled: true, DurationSeconds: -5},
+				DriftPolicy:    "Ignore",
+			}
+		}, expectedErrs: []string{
+			"spec.vlan.autoRetry.retries: Invalid value: -1",
+			"spec.vlan.headShellReset.durationSeconds: Invalid value: -5",
+			`spec.vlan.driftPolicy: Unsupported value: "Ignore"`,
+		}},
+		{name: "VLAN ID of other tenant", modify: func(s *SlingshotTenant) { s.Spec.VLAN.ID = 100 },
+			expectedErrs: []string{"spec.vlan.id: Invalid value: 100: is the VLAN ID of slingshot tenant slingshot-tenants/red"}},
+		{name: "allocated VLAN ID", modify: func(s *SlingshotTenant) { s.Spec.VLAN.ID = 7 },
+			expectedErrs: []string{"spec.vlan.id: Invalid value: 7: is the VLAN ID of slingshot tenant slingshot-tenants/green"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sshotTenant := newSlingshotTenant(10)
+			tt.modify(sshotTenant)
+
+			_, err := newValidator(t).ValidateCreate(context.Background(), sshotTenant)
+			if len(tt.expectedErrs) == 0 {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+
+			if !apierrors.IsInvalid(err) {
+				t.Fatalf("expected an invalid error, got %v", err)
+			}
+			for _, expected := range tt.expectedErrs {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("expected %q in %v", expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	validator := newValidator(t)
+	ctx := context.Background()
+
+	// the tenant of red is gone, a metadata only update is still allowed
+	oldTenant := &SlingshotTenant{
+		ObjectMeta: metav1.ObjectMeta{Name: "red", Namespace: "slingshot-tenants", Finalizers: []string{"finalizer"}},
+		Spec: SlingshotTenantSpec{
+			TenantName:   "vcluster-red",
+			VNIBlockName: "block",
+			VNIPartition: VNIPartition{VNIRange: []string{"1000-1999"}},
+		},
+	}
+	newTenant := oldTenant.DeepCopy()
+	newTenant.Finalizers = nil
+	if _, err := validator.ValidateUpdate(ctx, oldTenant, newTenant); err != nil {
+		t.Errorf("expected the finalizer removal to be allowed, got %v", err)
+	}
+
+	// so is any update of a tenant being deleted
+	newTenant.Spec.VNIBlockName = ""
+	newTenant.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	if _, err := validator.ValidateUpdate(ctx, oldTenant, newTenant); err != nil {
+		t.Errorf("expected the update of a deleted tenant to be allowed, got %v", err)
+	}
+
+	// a spec change is validated, the tenant does not overlap with itself
+	oldTenant = newSlingshotTenant(0, "1000-1999")
+	oldTenant.Name = "red"
+	newTenant = oldTenant.DeepCopy()
+	newTenant.Spec.VNIPartition.VNIRange = []string{"1000-2999"}
+	if _, err := validator.ValidateUpdate(ctx, oldTenant, newTenant); err != nil {
+		t.Errorf("expected the range of red to be extended, got %v", err)
+	}
+	newTenant.Spec.VNIBlockName = ""
+	if _, err := validator.ValidateUpdate(ctx, oldTenant, newTenant); !apierrors.IsInvalid(err) {
+		t.Errorf("expected the spec change to be rejected, got %v", err)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	ctx := context.Background()
+	validator := newValidator(t)
+	defaulter := &SlingshotTenantDefaulter{
+		Client:   validator.Client,
+		Defaults: SlingshotTenantDefaults{VNIBlockName: "vniblock", TenantVersion: "v1alpha2", VNICount: 100},
+	}
+
+	sshotTenant := newSlingshotTenant(0)
+	sshotTenant.Spec.VNIBlockName = ""
+	if err := defaulter.Default(ctx, sshotTenant); err != nil {
+		t.Fatal(err)
+	}
+	expected := SlingshotTenantSpec{
+		TenantName:    "vcluster-blue",
+		TenantVersion: "v1alpha2",
+		VNIBlockName:  "vniblock",
+		VNIPartition:  VNIPartition{VNICount: 100},
+	}
+	if !reflect.DeepEqual(sshotTenant.Spec, expected) {
+		t.Errorf("expected spec %+v, got %+v", expected, sshotTenant.Spec)
+	}
+	expectedLabels := map[string]string{
+		LabelTenantName:      "vcluster-blue",
+		LabelTenant:          "vcluster-blue",
+		LabelTenantNamespace: "tenants",
+	}
+	if !reflect.DeepEqual(sshotTenant.Labels, expectedLabels) {
+		t.Errorf("expected labels %v, got %v", expectedLabels, sshotTenant.Labels)
+	}
+
+	// the fields that are set are kept, and the labels follow the tenant name
+	sshotTenant = newSlingshotTenant(0, "100-199")
+	sshotTenant.Spec.TenantName = "vcluster-green"
+	sshotTenant.Spec.TenantVersion = "v1alpha1"
+	sshotTenant.Labels = map[string]string{"app": "green", LabelTenant: "vcluster-blue", LabelTenantNamespace: "tenants"}
+	if err := defaulter.Default(ctx, sshotTenant); err != nil {
+		t.Fatal(err)
+	}
+	if sshotTenant.Spec.VNIBlockName != "block" || sshotTenant.Spec.TenantVersion != "v1alpha1" || sshotTenant.Spec.VNIPartition.VNICount != 0 {
+		t.Errorf("expected the spec to be kept, got %+v", sshotTenant.Spec)
+	}
+	expectedLabels = map[string]string{"app": "green", LabelTenantName: "vcluster-green"}
+	if !reflect.DeepEqual(sshotTenant.Labels, expectedLabels) {
+		t.Errorf("expected labels %v, got %v", expectedLabels, sshotTenant.Labels)
+	}
+}
@@ -10,16 +10,48 @@
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppliedVNISpec) DeepCopyInto(out *AppliedVNISpec) {
+	*out = *in
+	in.VNIPartition.DeepCopyInto(&out.VNIPartition)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppliedVNISpec.
+func (in *AppliedVNISpec) DeepCopy() *AppliedVNISpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AppliedVNISpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LinkRecoveryPolicy) DeepCopyInto(out *LinkRecoveryPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LinkRecoveryPolicy.
+func (in *LinkRecoveryPolicy) DeepCopy() *LinkRecoveryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(LinkRecoveryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SlingshotTenant) DeepCopyInto(out *SlingshotTenant) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SlingshotTenant.
@@ -76,6 +108,7 @@ func (in *SlingshotTenantList) DeepCopyObject() runtime.Object {
 func (in *SlingshotTenantSpec) DeepCopyInto(out *SlingshotTenantSpec) {
 	*out = *in
 	in.VNIPartition.DeepCopyInto(&out.VNIPartition)
+	in.VLAN.DeepCopyInto(&out.VLAN)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SlingshotTenantSpec.
@@ -91,6 +124,51 @@ func (in *SlingshotTenantSpec) DeepCopy() *SlingshotTenantSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SlingshotTenantStatus) DeepCopyInto(out *SlingshotTenantStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VNIRanges != nil {
+		in, out := &in.VNIRanges, &out.VNIRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EdgePortDFAs != nil {
+		in, out := &in.EdgePortDFAs, &out.EdgePortDFAs
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
+	if in.EnforcementAddVNIs != nil {
+		in, out := &in.EnforcementAddVNIs, &out.EnforcementAddVNIs
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
+	if in.EnforcementRemoveVNIs != nil {
+		in, out := &in.EnforcementRemoveVNIs, &out.EnforcementRemoveVNIs
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
+	if in.EnforcementDeadline != nil {
+		in, out := &in.EnforcementDeadline, &out.EnforcementDeadline
+		*out = (*in).DeepCopy()
+	}
+	if in.FirstEnforcedTime != nil {
+		in, out := &in.FirstEnforcedTime, &out.FirstEnforcedTime
+		*out = (*in).DeepCopy()
+	}
+	if in.AppliedVLAN != nil {
+		in, out := &in.AppliedVLAN, &out.AppliedVLAN
+		*out = new(VLANSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AppliedVNI != nil {
+		in, out := &in.AppliedVNI, &out.AppliedVNI
+		*out = new(AppliedVNISpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SlingshotTenantStatus.
@@ -103,6 +181,36 @@ func (in *SlingshotTenantStatus) DeepCopy() *SlingshotTenantStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VLANSpec) DeepCopyInto(out *VLANSpec) {
+	*out = *in
+	if in.AllowedVLANs != nil {
+		in, out := &in.AllowedVLANs, &out.AllowedVLANs
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
+	if in.AutoRetry != nil {
+		in, out := &in.AutoRetry, &out.AutoRetry
+		*out = new(LinkRecoveryPolicy)
+		**out = **in
+	}
+	if in.HeadShellReset != nil {
+		in, out := &in.HeadShellReset, &out.HeadShellReset
+		*out = new(LinkRecoveryPolicy)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VLANSpec.
+func (in *VLANSpec) DeepCopy() *VLANSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VLANSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VNIPartition) DeepCopyInto(out *VNIPartition) {
 	*out = *in
@@ -16,9 +16,11 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -26,9 +28,14 @@ import (
 
 	slingshotv1alpha1 "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
 	tapmsv1alpha2 "github.hpe.com/hpe/sshot-net-operator/api/tapms/v1alpha2"
+	"github.hpe.com/hpe/sshot-net-operator/fm"
+	"github.hpe.com/hpe/sshot-net-operator/internal/config"
 	slingshotcontroller "github.hpe.com/hpe/sshot-net-operator/internal/controller/slingshot"
 	tapmscontroller "github.hpe.com/hpe/sshot-net-operator/internal/controller/tapms"
-	"github.hpe.com/hpe/sshot-net-operator/models"
+	"github.hpe.com/hpe/sshot-net-operator/internal/gc"
+	"github.hpe.com/hpe/sshot-net-operator/internal/inventory"
+	"github.hpe.com/hpe/sshot-net-operator/internal/ipam"
+	"github.hpe.com/hpe/sshot-net-operator/token"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -63,6 +70,7 @@ func main() {
 		"If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	configFlags := config.BindFlags(flag.CommandLine)
 	opts := zap.Options{
 		Development: true,
 	}
@@ -71,10 +79,26 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	models.SkipTLSVerify = os.Getenv("SKIP_TLS_VERIFY")
-	models.ClientID = os.Getenv("CLIENT_ID")
-	models.NamespaceForClientData = os.Getenv("NAMESPACE")
-	models.SecretForClientData = os.Getenv("SECRET_NAME")
+	operatorConfig, err := configFlags.Load()
+	if err != nil {
+		setupLog.Error(err, "unable to load operator configuration")
+		os.Exit(1)
+	}
+	setupLog.Info("loaded operator configuration", "fabricManagerURL", operatorConfig.FabricManagerURL,
+		"caCertPath", operatorConfig.CACertPath, "skipTLSVerify", operatorConfig.SkipTLSVerify,
+		"caReloadInterval", operatorConfig.CAReloadInterval.Duration, "maxIdleConns", operatorConfig.MaxIdleConns,
+		"maxIdleConnsPerHost", operatorConfig.MaxIdleConnsPerHost, "maxConnsPerHost", operatorConfig.MaxConnsPerHost,
+		"idleConnTimeout", operatorConfig.IdleConnTimeout.Duration,
+		"requestTimeout", operatorConfig.RequestTimeout.Duration, "reconciliationTime", operatorConfig.ReconciliationTime.Duration,
+		"requestRetries", operatorConfig.RequestRetries, "retryBaseDelay", operatorConfig.RetryBaseDelay.Duration,
+		"retryMaxDelay", operatorConfig.RetryMaxDelay.Duration, "circuitBreakerThreshold", operatorConfig.CircuitBreakerThreshold,
+		"circuitBreakerCooldown", operatorConfig.CircuitBreakerCooldown.Duration,
+		"enforcementTimeout", operatorConfig.EnforcementTimeout.Duration, "enforcementRetries", operatorConfig.EnforcementRetries,
+		"gcInterval", operatorConfig.GCInterval.Duration, "gcGracePeriod", operatorConfig.GCGracePeriod.Duration, "gcDryRun", operatorConfig.GCDryRun,
+		"inventory", operatorConfig.InventoryNamespace+"/"+operatorConfig.InventoryName,
+		"crawlWorkers", operatorConfig.CrawlWorkers, "crawlRateLimit", operatorConfig.CrawlRateLimit,
+		"enableWebhooks", operatorConfig.EnableWebhooks, "slingshotTenantDefaults", operatorConfig.SlingshotTenantDefaults(),
+		"vniPool", operatorConfig.VNIPool, "vlanPool", operatorConfig.VLANPool, "vlanExclude", operatorConfig.VLANExclude)
 
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
@@ -124,22 +148,103 @@ func main() {
 		os.Exit(1)
 	}
 
+	// the token and fabric requests share the connections to the API gateway,
+	// and the transport reloads the CA certificate when the ConfigMap changes
+	transport := operatorConfig.Transport()
+	if _, err := transport.Client(); err != nil {
+		setupLog.Error(err, "unable to load CA certificate, requests will fail until it can be read")
+	}
+	if err := mgr.Add(transport); err != nil {
+		setupLog.Error(err, "unable to add CA certificate reload")
+		os.Exit(1)
+	}
+
+	// the token provider is shared by both controllers and reads the client
+	// secret through the manager cache, so a rotated secret is picked up
+	tokenProvider := token.NewProvider(mgr.GetClient(), operatorConfig.HTTPClient("", transport), operatorConfig.ClientID,
+		types.NamespacedName{Namespace: operatorConfig.ClientSecretNamespace, Name: operatorConfig.ClientSecretName})
+	fabricHTTPClient := operatorConfig.HTTPClient(operatorConfig.FabricManagerURL, transport)
+	fabricHTTPClient.TokenSource = tokenProvider
+	fabricHTTPClient.Breaker = operatorConfig.CircuitBreaker()
+	fabricClient := fm.NewClient(fabricHTTPClient)
+	fabricClient.Crawler().Workers = operatorConfig.CrawlWorkers
+	fabricClient.Crawler().SetRateLimit(operatorConfig.CrawlRateLimit)
+
+	// the inventory is read and written without the cache, so a document
+	// recorded by one controller is immediately seen by the other
+	inventoryClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme()})
+	if err != nil {
+		setupLog.Error(err, "unable to create inventory client")
+		os.Exit(1)
+	}
+	fabricInventory := inventory.New(inventoryClient, operatorConfig.InventoryNamespace, operatorConfig.InventoryName,
+		tapmscontroller.AdoptExisting(inventoryClient, fabricClient))
+
+	// without a VNI pool, Fabric Manager allocates the VNIs of the tenants
+	var vniAllocator *ipam.Allocator
+	if len(operatorConfig.VNIPool) > 0 {
+		vniPool, err := ipam.ParsePool(operatorConfig.VNIPool)
+		if err != nil {
+			setupLog.Error(err, "unable to parse VNI pool")
+			os.Exit(1)
+		}
+		vniAllocator = ipam.New(inventoryClient, operatorConfig.InventoryNamespace, operatorConfig.VNIAllocationsName, vniPool,
+			tapmscontroller.AdoptVNIAllocations(fabricClient, fabricInventory))
+	}
+
+	vlanPool, err := ipam.VLANPool(operatorConfig.VLANPool, operatorConfig.VLANExclude)
+	if err != nil {
+		setupLog.Error(err, "unable to parse VLAN pool")
+		os.Exit(1)
+	}
+	vlanAllocator := ipam.NewVLANAllocator(inventoryClient, operatorConfig.InventoryNamespace, operatorConfig.VLANAllocationsName, vlanPool,
+		tapmscontroller.AdoptVLANAllocations(fabricInventory))
+
 	if err = (&tapmscontroller.TenantReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+		Fabric:             fabricClient,
+		Inventory:          fabricInventory,
+		VNIs:               vniAllocator,
+		VLANs:              vlanAllocator,
+		ReconciliationTime: operatorConfig.ReconciliationTime.Duration,
+		Recorder:           mgr.GetEventRecorderFor("tenant-controller"),
+		EnforcementTimeout: operatorConfig.EnforcementTimeout.Duration,
+		EnforcementRetries: operatorConfig.EnforcementRetries,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Tenant")
 		os.Exit(1)
 	}
 	if err = (&slingshotcontroller.SlingshotTenantReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+		Fabric:             fabricClient,
+		Inventory:          fabricInventory,
+		VNIs:               vniAllocator,
+		VLANs:              vlanAllocator,
+		ReconciliationTime: operatorConfig.ReconciliationTime.Duration,
+		Recorder:           mgr.GetEventRecorderFor("slingshottenant-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "SlingshotTenant")
 		os.Exit(1)
 	}
+	if operatorConfig.EnableWebhooks {
+		if err = (&slingshotv1alpha1.SlingshotTenant{}).SetupWebhookWithManager(mgr, operatorConfig.SlingshotTenantDefaults()); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "SlingshotTenant")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
+	if operatorConfig.GCInterval.Duration > 0 {
+		collector := gc.NewCollector(mgr.GetClient(), fabricClient, fabricInventory, vniAllocator, vlanAllocator, operatorConfig.GCInterval.Duration,
+			operatorConfig.GCGracePeriod.Duration, operatorConfig.GCDryRun)
+		if err := mgr.Add(collector); err != nil {
+			setupLog.Error(err, "unable to add fabric garbage collector")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -148,6 +253,11 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	// the operator is not ready while the requests to Fabric Manager are stopped
+	if err := mgr.AddReadyzCheck("fabric-manager", fabricHTTPClient.Breaker.Check); err != nil {
+		setupLog.Error(err, "unable to set up fabric manager ready check")
+		os.Exit(1)
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
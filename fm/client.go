@@ -0,0 +1,112 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package fm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+)
+
+const (
+	switchesPath      = "/fabric/switches"
+	portsPath         = "/fabric/ports"
+	vlansPath         = "/fabric/vlans"
+	portPoliciesPath  = "/fabric/port-policies"
+	vniPartitionsPath = "/fabric/vni/partitions"
+	vniBlocksPath     = "/fabric/vni/blocks"
+)
+
+// Client is a typed client for the Fabric Manager REST API. It owns the
+// endpoint paths and decodes responses into the types defined in models.
+type Client struct {
+	httpClient *httpclient.Client
+	crawler    *Crawler
+	topology   *Topology
+}
+
+// NewClient returns a Fabric Manager client that sends its requests through httpClient
+func NewClient(httpClient *httpclient.Client) *Client {
+	c := &Client{
+		httpClient: httpClient,
+	}
+	c.crawler = newCrawler(c)
+	c.topology = newTopology(c)
+
+	return c
+}
+
+// Crawler returns the crawler of the switch and port documents
+func (c *Client) Crawler() *Crawler {
+	return c.crawler
+}
+
+// Topology returns the edge port cache shared by all the users of the client
+func (c *Client) Topology() *Topology {
+	return c.topology
+}
+
+// Switches returns the service for /fabric/switches
+func (c *Client) Switches() *SwitchesService {
+	return &SwitchesService{client: c}
+}
+
+// Ports returns the service for /fabric/ports
+func (c *Client) Ports() *PortsService {
+	return &PortsService{client: c}
+}
+
+// PortPolicyManager returns the manager of the port policies applied to the ports
+func (c *Client) PortPolicyManager() *PortPolicyManager {
+	return &PortPolicyManager{client: c}
+}
+
+// VLANs returns the service for /fabric/vlans
+func (c *Client) VLANs() *VLANsService {
+	return &VLANsService{client: c}
+}
+
+// PortPolicies returns the service for /fabric/port-policies
+func (c *Client) PortPolicies() *PortPoliciesService {
+	return &PortPoliciesService{client: c}
+}
+
+// VNIPartitions returns the service for /fabric/vni/partitions
+func (c *Client) VNIPartitions() *VNIPartitionsService {
+	return &VNIPartitionsService{client: c}
+}
+
+// VNIBlocks returns the service for /fabric/vni/blocks
+func (c *Client) VNIBlocks() *VNIBlocksService {
+	return &VNIBlocksService{client: c}
+}
+
+// do sends the request and, when out is not nil, decodes the response body into it
+func (c *Client) do(ctx context.Context, method string, requestPath string, data interface{}, out interface{}) error {
+	responseBody, err := c.httpClient.SendRequest(ctx, method, requestPath, data)
+	if err != nil {
+		return err
+	}
+
+	if out == nil || len(responseBody) == 0 {
+		return nil
+	}
+
+	err = json.Unmarshal(responseBody, out)
+	if err != nil {
+		return &httpclient.DecodeError{Body: responseBody, Err: fmt.Errorf("cannot unmarshal %s %s response: %w", method, requestPath, err)}
+	}
+
+	return nil
+}
+
+// LinkName returns the last element of a Fabric Manager document link,
+// e.g. "x1000c2r3b0" for "/fabric/switches/x1000c2r3b0"
+func LinkName(link string) string {
+	return path.Base(link)
+}
@@ -0,0 +1,247 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package fm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+const (
+	// DefaultCrawlWorkers is the number of switch and port documents fetched in parallel
+	DefaultCrawlWorkers = 8
+
+	// DefaultCrawlRateLimit is the number of requests per second sent by a crawl
+	DefaultCrawlRateLimit = 50
+)
+
+// SwitchError is the error of a switch that could not be crawled
+type SwitchError struct {
+	Switch string
+	Err    error
+}
+
+func (e *SwitchError) Error() string {
+	return fmt.Sprintf("switch %s: %v", e.Switch, e.Err)
+}
+
+func (e *SwitchError) Unwrap() error {
+	return e.Err
+}
+
+// CrawlError is returned along with the partial result of a crawl when some
+// switches could not be crawled
+type CrawlError struct {
+	Errors []*SwitchError
+}
+
+func (e *CrawlError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+
+	return fmt.Sprintf("cannot crawl %d switches: %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// CrawledSwitch is a switch document and the documents of its edge ports
+type CrawledSwitch struct {
+	Name   string
+	Switch models.SwitchResponse
+
+	// Ports are the edge port documents in the order of Switch.EdgePorts. They
+	// are not fetched when the switch was skipped
+	Ports []models.PortResponse
+
+	// Skipped is true when the edge ports were not fetched
+	Skipped bool
+}
+
+// Crawler fetches the switch and port documents of the fabric in parallel.
+// The crawler of a Client is shared by all its users, so the rate limit
+// applies to all the crawls sent to the Fabric Manager host.
+type Crawler struct {
+	client *Client
+
+	// Workers is the number of documents fetched in parallel
+	Workers int
+
+	limiter *rate.Limiter
+}
+
+func newCrawler(c *Client) *Crawler {
+	return &Crawler{
+		client:  c,
+		Workers: DefaultCrawlWorkers,
+		limiter: rate.NewLimiter(DefaultCrawlRateLimit, DefaultCrawlWorkers),
+	}
+}
+
+// SetRateLimit limits the crawls to requestsPerSecond. Zero disables the limit
+func (c *Crawler) SetRateLimit(requestsPerSecond float64) {
+	if requestsPerSecond <= 0 {
+		c.limiter.SetLimit(rate.Inf)
+		return
+	}
+
+	c.limiter.SetLimit(rate.Limit(requestsPerSecond))
+	c.limiter.SetBurst(c.workers())
+}
+
+func (c *Crawler) workers() int {
+	if c.Workers < 1 {
+		return 1
+	}
+
+	return c.Workers
+}
+
+// Crawl fetches every switch and the edge ports of the switches for which
+// skip returns false. Switches are returned in the order of the switch list.
+//
+// When some switches cannot be fetched, the other switches are returned with
+// a *CrawlError. Any other error, including the cancellation of ctx, fails the
+// whole crawl.
+func (c *Crawler) Crawl(ctx context.Context, skip func(name string, sw models.SwitchResponse) bool) ([]CrawledSwitch, error) {
+	names, err := c.client.Switches().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := make(chan struct{}, c.workers())
+	run := func(wg *sync.WaitGroup, fetch func() error, failed func(err error)) {
+		c.run(ctx, wg, workers, fetch, failed)
+	}
+
+	switches := make([]CrawledSwitch, len(names))
+	errs := make([]error, len(names))
+	var mu sync.Mutex
+	fail := func(i int) func(err error) {
+		return func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if errs[i] == nil {
+				errs[i] = err
+			}
+		}
+	}
+
+	//fetch the switches
+	var wg sync.WaitGroup
+	for i, name := range names {
+		i, name := i, name
+		switches[i].Name = name
+		run(&wg, func() error {
+			sw, err := c.client.Switches().Get(ctx, name)
+			switches[i].Switch = sw
+			return err
+		}, fail(i))
+	}
+	wg.Wait()
+
+	//fetch the edge ports of the switches
+	for i := range switches {
+		if errs[i] != nil {
+			continue
+		}
+		if skip != nil && skip(switches[i].Name, switches[i].Switch) {
+			switches[i].Skipped = true
+			continue
+		}
+
+		switches[i].Ports = make([]models.PortResponse, len(switches[i].Switch.EdgePorts))
+		for j, edgePort := range switches[i].Switch.EdgePorts {
+			i, j, portName := i, j, edgePort.ConnPort
+			run(&wg, func() error {
+				port, err := c.client.Ports().Get(ctx, portName)
+				switches[i].Ports[j] = port
+				return err
+			}, fail(i))
+		}
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var crawled []CrawledSwitch
+	var crawlErr CrawlError
+	for i := range switches {
+		if errs[i] != nil {
+			crawlErr.Errors = append(crawlErr.Errors, &SwitchError{Switch: switches[i].Name, Err: errs[i]})
+			continue
+		}
+		crawled = append(crawled, switches[i])
+	}
+
+	if len(crawlErr.Errors) > 0 {
+		return crawled, &crawlErr
+	}
+
+	return crawled, nil
+}
+
+// Ports fetches the port documents in parallel, in the order of portNames
+func (c *Crawler) Ports(ctx context.Context, portNames []string) ([]models.PortResponse, error) {
+	workers := make(chan struct{}, c.workers())
+	ports := make([]models.PortResponse, len(portNames))
+	var firstErr error
+	var mu sync.Mutex
+	failed := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, portName := range portNames {
+		i, portName := i, portName
+		c.run(ctx, &wg, workers, func() error {
+			port, err := c.client.Ports().Get(ctx, portName)
+			ports[i] = port
+			return err
+		}, failed)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return ports, nil
+}
+
+// run runs fetch on one of the workers once the rate limit allows it
+func (c *Crawler) run(ctx context.Context, wg *sync.WaitGroup, workers chan struct{}, fetch func() error, failed func(err error)) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+			failed(ctx.Err())
+			return
+		}
+		defer func() { <-workers }()
+
+		err := c.limiter.Wait(ctx)
+		if err == nil {
+			err = fetch()
+		}
+		if err != nil {
+			failed(err)
+		}
+	}()
+}
@@ -0,0 +1,149 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package fm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.hpe.com/hpe/sshot-net-operator/fm/fmtest"
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+// newCrawlServer starts a fake Fabric Manager with the given number of switches and edge ports per switch
+func newCrawlServer(t *testing.T, switches int, edgePorts int) *fmtest.Server {
+	t.Helper()
+
+	server := fmtest.NewServer()
+	t.Cleanup(server.Close)
+	for i := 0; i < switches; i++ {
+		switchName := fmt.Sprintf("x1000c%dr3b0", i)
+		server.AddSwitch(switchName, 1, i)
+		for j := 0; j < edgePorts; j++ {
+			err := server.AddEdgePort(switchName, j, fmt.Sprintf("x1000c%dr3j%dp0", i, j), fmt.Sprintf("x1000c%ds%db0n0h0", i, j))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	return server
+}
+
+func TestCrawl(t *testing.T) {
+	ctx := context.Background()
+	server := newCrawlServer(t, 4, 3)
+	client := NewClient(httpclient.NewClient(server.URL))
+	client.Crawler().Workers = 3
+	client.Crawler().SetRateLimit(0)
+
+	// one switch fails, the others are still returned
+	server.FailNext("GET", "/fabric/ports/x1000c2r3j1p0", http.StatusInternalServerError)
+	crawled, err := client.Crawler().Crawl(ctx, func(name string, sw models.SwitchResponse) bool {
+		return name == "x1000c3r3b0"
+	})
+
+	var crawlErr *CrawlError
+	if !errors.As(err, &crawlErr) || len(crawlErr.Errors) != 1 || crawlErr.Errors[0].Switch != "x1000c2r3b0" {
+		t.Fatalf("expected switch x1000c2r3b0 to fail, got %v", err)
+	}
+
+	var names []string
+	for _, sw := range crawled {
+		names = append(names, sw.Name)
+	}
+	if fmt.Sprint(names) != "[x1000c0r3b0 x1000c1r3b0 x1000c3r3b0]" {
+		t.Errorf("expected the switches that did not fail in list order, got %v", names)
+	}
+	if len(crawled[0].Ports) != 3 || crawled[0].Ports[2].ConnPort != "x1000c0r3j2p0" {
+		t.Errorf("expected the edge ports in switch order, got %+v", crawled[0].Ports)
+	}
+	if !crawled[2].Skipped || crawled[2].Ports != nil {
+		t.Errorf("expected the ports of the skipped switch not to be fetched, got %+v", crawled[2])
+	}
+}
+
+func TestCrawlCancel(t *testing.T) {
+	server := newCrawlServer(t, 2, 2)
+	client := NewClient(httpclient.NewClient(server.URL))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	requests := 0
+	_, err := client.Crawler().Crawl(ctx, func(name string, sw models.SwitchResponse) bool {
+		requests = len(server.Requests())
+		cancel()
+		return false
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the crawl to be cancelled, got %v", err)
+	}
+	if len(server.Requests()) != requests {
+		t.Errorf("expected no port to be fetched after cancellation, got %v", server.Requests()[requests:])
+	}
+}
+
+func TestCrawlPorts(t *testing.T) {
+	ctx := context.Background()
+	server := newCrawlServer(t, 2, 3)
+	client := NewClient(httpclient.NewClient(server.URL))
+	client.Crawler().Workers = 3
+	client.Crawler().SetRateLimit(0)
+
+	portNames := []string{"x1000c1r3j2p0", "x1000c0r3j0p0", "x1000c1r3j0p0"}
+	ports, err := client.Crawler().Ports(ctx, portNames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, port := range ports {
+		if LinkName(port.DocumentSelfLink) != portNames[i] {
+			t.Errorf("expected port %s at %d, got %+v", portNames[i], i, port)
+		}
+	}
+
+	server.FailNext("GET", "/fabric/ports/x1000c0r3j0p0", http.StatusInternalServerError)
+	_, err = client.Crawler().Ports(ctx, portNames)
+	if !errors.Is(err, httpclient.ErrServerError) {
+		t.Errorf("expected the failed port to be reported, got %v", err)
+	}
+}
+
+func TestTopologyPartialCrawl(t *testing.T) {
+	ctx := context.Background()
+	server := newCrawlServer(t, 2, 1)
+	client := NewClient(httpclient.NewClient(server.URL))
+	topology := client.Topology()
+
+	// a switch that was never crawled is reported
+	server.FailNext("GET", "/fabric/switches/x1000c1r3b0", http.StatusInternalServerError)
+	edgePorts, err := topology.EdgePorts(ctx, []string{"x1000c0s0b0n0", "x1000c1s0b0n0"})
+	var crawlErr *CrawlError
+	if !errors.As(err, &crawlErr) {
+		t.Fatalf("expected a crawl error, got %v", err)
+	}
+	if len(edgePorts) != 1 || edgePorts[0].Name != "x1000c0r3j0p0" {
+		t.Errorf("expected the edge port of the crawled switch, got %+v", edgePorts)
+	}
+
+	// the failed switch is crawled again on the next lookup
+	edgePorts, err = topology.EdgePorts(ctx, []string{"x1000c0s0b0n0", "x1000c1s0b0n0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edgePorts) != 2 {
+		t.Errorf("expected both edge ports, got %+v", edgePorts)
+	}
+
+	// a switch that fails after it was crawled keeps its previous state
+	topology.Invalidate()
+	server.FailNext("GET", "/fabric/switches/x1000c1r3b0", http.StatusInternalServerError)
+	edgePorts, err = topology.EdgePorts(ctx, []string{"x1000c1s0b0n0"})
+	if err != nil || len(edgePorts) != 1 {
+		t.Errorf("expected the cached edge port, got %+v %v", edgePorts, err)
+	}
+}
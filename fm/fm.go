@@ -2,86 +2,93 @@
 (C) Copyright Hewlett Packard Enterprise Development LP
 */
 
-// Package fm provides the functions to get the switches, and port details
+// Package fm provides a typed client for the Fabric Manager APIs
 package fm
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"strings"
 
-	"github.hpe.com/hpe/sshot-net-operator/httpclient"
 	"github.hpe.com/hpe/sshot-net-operator/models"
 )
 
-// GetAllSwitches gets all the switches
-func GetAllSwitches() ([]string, error) {
-	ctx := context.Background()
+// SwitchesService provides access to the switch documents
+type SwitchesService struct {
+	client *Client
+}
+
+// List gets the names of all the switches
+func (s *SwitchesService) List(ctx context.Context) ([]string, error) {
 	switches := []string{}
-	httpClient := httpclient.NewClient(models.BaseURL)
-	response, err := httpClient.SendRequest(ctx, "GET", "/fabric/switches", nil)
-	if err != nil {
-		return switches, fmt.Errorf("could not get all switches: %v", err)
-	}
 
-	var SwitchesResponse models.SwitchesResponse
-	err = json.Unmarshal(response, &SwitchesResponse)
+	var switchesResponse models.SwitchesResponse
+	err := s.client.do(ctx, "GET", switchesPath, nil, &switchesResponse)
 	if err != nil {
-		return switches, fmt.Errorf("cannot unmarshal switches response: %v", err)
+		return switches, fmt.Errorf("could not get all switches: %w", err)
 	}
 
-	for _, x := range SwitchesResponse.DocumentLinks {
-		splitDocumentLink := strings.Split(x, "/")
-		switches = append(switches, splitDocumentLink[len(splitDocumentLink)-1])
+	for _, x := range switchesResponse.DocumentLinks {
+		switches = append(switches, LinkName(x))
 	}
 
 	return switches, nil
 }
 
-// GetSwitch gets the ports for a switch
-func GetSwitch(switchName string) (models.DFAComponents, error) {
-	ctx := context.Background()
-	var DFAComponents models.DFAComponents
-	httpClient := httpclient.NewClient(models.BaseURL)
-	response, err := httpClient.SendRequest(ctx, "GET", models.OperatorConstFabric+models.OperatorConstSwitches+switchName, nil)
+// Get gets the switch document for a switch
+func (s *SwitchesService) Get(ctx context.Context, switchName string) (models.SwitchResponse, error) {
+	var switchResponse models.SwitchResponse
+	err := s.client.do(ctx, "GET", switchesPath+"/"+switchName, nil, &switchResponse)
 	if err != nil {
-		return DFAComponents, fmt.Errorf("could not get switch details: %v", err)
+		return switchResponse, fmt.Errorf("could not get switch details: %w", err)
 	}
 
-	var SwitchResponse models.SwitchResponse
-	err = json.Unmarshal(response, &SwitchResponse)
+	return switchResponse, nil
+}
+
+// DFAComponents gets the group, switch and edge port numbers of a switch
+func (s *SwitchesService) DFAComponents(ctx context.Context, switchName string) (models.DFAComponents, error) {
+	var dfaComponents models.DFAComponents
+
+	switchResponse, err := s.Get(ctx, switchName)
 	if err != nil {
-		return DFAComponents, fmt.Errorf("cannot unmarshal switch response: %v", err)
+		return dfaComponents, err
 	}
 
-	DFAComponents.GroupID = SwitchResponse.GrpID
-	DFAComponents.SwitchID = SwitchResponse.SwcNum
+	dfaComponents.GroupID = switchResponse.GrpID
+	dfaComponents.SwitchID = switchResponse.SwcNum
 
 	var edgePortInfo models.EdgePortsInfo
-	for _, x := range SwitchResponse.EdgePorts {
+	for _, x := range switchResponse.EdgePorts {
 		edgePortInfo.PortID = x.PortNum
 		edgePortInfo.EdgePort = x.ConnPort
-		DFAComponents.EdgePortsInfo = append(DFAComponents.EdgePortsInfo, edgePortInfo)
+		dfaComponents.EdgePortsInfo = append(dfaComponents.EdgePortsInfo, edgePortInfo)
 	}
 
-	return DFAComponents, nil
+	return dfaComponents, nil
 }
 
-// GetPort gets the port details for a port
-func GetPort(portName string) (models.PortResponse, error) {
-	ctx := context.Background()
-	var port models.PortResponse
+// PortsService provides access to the port documents
+type PortsService struct {
+	client *Client
+}
 
-	httpClient := httpclient.NewClient(models.BaseURL)
-	response, err := httpClient.SendRequest(ctx, "GET", models.OperatorConstFabric+models.OperatorConstPorts+portName, nil)
+// Get gets the port details for a port
+func (s *PortsService) Get(ctx context.Context, portName string) (models.PortResponse, error) {
+	var port models.PortResponse
+	err := s.client.do(ctx, "GET", portsPath+"/"+portName, nil, &port)
 	if err != nil {
-		return port, fmt.Errorf("could not get port details %+v", err)
+		return port, fmt.Errorf("could not get port details: %w", err)
 	}
 
-	err = json.Unmarshal(response, &port)
+	return port, nil
+}
+
+// Patch updates the port policies applied to a port
+func (s *PortsService) Patch(ctx context.Context, portName string, request models.PortPATCHRequest) (models.PortResponse, error) {
+	var port models.PortResponse
+	err := s.client.do(ctx, "PATCH", portsPath+"/"+portName, request, &port)
 	if err != nil {
-		return port, fmt.Errorf("cannot unmarshal port response: %+v", err)
+		return port, fmt.Errorf("could not update port %s: %w", portName, err)
 	}
 
 	return port, nil
@@ -0,0 +1,816 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+// Package fmtest provides an in-process fake of the Fabric Manager REST API for tests
+package fmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+const (
+	switchesPath         = "/fabric/switches"
+	portsPath            = "/fabric/ports"
+	vlansPath            = "/fabric/vlans"
+	portPoliciesPath     = "/fabric/port-policies"
+	vniPartitionsPath    = "/fabric/vni/partitions"
+	vniBlocksPath        = "/fabric/vni/blocks"
+	enforcementTasksPath = "/fabric/vni/enforcement-tasks"
+
+	// TokenPath is the path of the fake token endpoint
+	TokenPath = "/keycloak/realms/shasta/protocol/openid-connect/token"
+
+	// firstVNI is the first VNI handed out to partitions that only ask for a count
+	firstVNI = 1024
+)
+
+// Server is a stateful in-memory fake of the Fabric Manager REST API. Documents
+// created through the API are kept in memory and can be inspected by the test.
+type Server struct {
+	*httptest.Server
+
+	// AccessToken is the token returned by the token endpoint
+	AccessToken string
+	// TokenExpiresIn is the lifetime in seconds reported for AccessToken
+	TokenExpiresIn int
+	// RequireAuth rejects fabric requests that do not carry AccessToken with 401
+	RequireAuth bool
+	// EnforcementStage is the stage reported by new VNI block enforcement tasks
+	EnforcementStage string
+
+	mu               sync.Mutex
+	switches         map[string]*models.SwitchResponse
+	ports            map[string]*models.PortResponse
+	vlans            map[int]*models.VLANResponse
+	portPolicies     map[string]*models.PortPolicyResponse
+	vniPartitions    map[string]*models.VNIPartitionResponse
+	vniBlocks        map[string]*models.VNIBlockResponse
+	enforcementTasks map[string]*models.VniBlockEnforcementTaskServiceState
+	failures         []failure
+	requests         []string
+	nextVNI          int
+	nextTask         int
+}
+
+type failure struct {
+	method     string
+	path       string
+	statusCode int
+}
+
+// NewServer starts a fake Fabric Manager. The caller must call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		AccessToken:      "fmtest-token",
+		TokenExpiresIn:   300,
+		EnforcementStage: "FINISHED",
+		switches:         make(map[string]*models.SwitchResponse),
+		ports:            make(map[string]*models.PortResponse),
+		vlans:            make(map[int]*models.VLANResponse),
+		portPolicies:     make(map[string]*models.PortPolicyResponse),
+		vniPartitions:    make(map[string]*models.VNIPartitionResponse),
+		vniBlocks:        make(map[string]*models.VNIBlockResponse),
+		enforcementTasks: make(map[string]*models.VniBlockEnforcementTaskServiceState),
+		nextVNI:          firstVNI,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// TokenURL returns the URL of the token endpoint
+func (s *Server) TokenURL() string {
+	return s.URL + TokenPath
+}
+
+// SetAccessToken changes the token returned by the token endpoint. Fabric
+// requests that carry the previous token are rejected when RequireAuth is set
+func (s *Server) SetAccessToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.AccessToken = token
+}
+
+// AddSwitch adds a switch with no edge ports to the fabric
+func (s *Server) AddSwitch(name string, groupID int, switchNum int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sw := &models.SwitchResponse{
+		GrpID:         groupID,
+		SwcNum:        switchNum,
+		DisplayName:   name,
+		EdgePortLinks: []string{},
+		EdgePorts:     []models.EdgePort{},
+	}
+	touch(&sw.DocumentVersion, &sw.DocumentUpdateTimeMicros)
+	sw.DocumentKind = "com:hpe:fabric:switch"
+	sw.DocumentSelfLink = switchesPath + "/" + name
+	s.switches[name] = sw
+}
+
+// AddEdgePort adds an edge port to a switch. dstPort is the node side of the
+// link, e.g. "x1000c2s0b0n1h0" for the node "x1000c2s0b0n1"
+func (s *Server) AddEdgePort(switchName string, portNum int, portName string, dstPort string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sw, ok := s.switches[switchName]
+	if !ok {
+		return fmt.Errorf("switch %s not found", switchName)
+	}
+
+	sw.EdgePorts = append(sw.EdgePorts, models.EdgePort{PortNum: portNum, ConnPort: portName})
+	sw.EdgePortLinks = append(sw.EdgePortLinks, portsPath+"/"+portName)
+	touch(&sw.DocumentVersion, &sw.DocumentUpdateTimeMicros)
+
+	port := &models.PortResponse{
+		SwitchLink:      sw.DocumentSelfLink,
+		PortNumber:      portNum,
+		ConnPort:        portName,
+		DstPort:         dstPort,
+		PortPolicyLinks: []string{},
+	}
+	touch(&port.DocumentVersion, &port.DocumentUpdateTimeMicros)
+	port.DocumentKind = "com:hpe:fabric:port"
+	port.DocumentSelfLink = portsPath + "/" + portName
+	s.ports[portName] = port
+
+	return nil
+}
+
+// FailNext makes the next request matching method and path fail with statusCode
+func (s *Server) FailNext(method string, path string, statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = append(s.failures, failure{method: method, path: path, statusCode: statusCode})
+}
+
+// Requests returns the requests served so far as "METHOD path"
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+// Port returns a copy of a port document
+func (s *Server) Port(name string) (models.PortResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	port, ok := s.ports[name]
+	if !ok {
+		return models.PortResponse{}, false
+	}
+	return *port, true
+}
+
+// VLANs returns copies of all VLAN documents ordered by ID
+func (s *Server) VLANs() []models.VLANResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vlans := []models.VLANResponse{}
+	for _, vlan := range s.vlans {
+		vlans = append(vlans, *vlan)
+	}
+	sort.Slice(vlans, func(i, j int) bool { return vlans[i].VLANID < vlans[j].VLANID })
+
+	return vlans
+}
+
+// PortPolicy returns a copy of a port policy document
+func (s *Server) PortPolicy(name string) (models.PortPolicyResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	portPolicy, ok := s.portPolicies[name]
+	if !ok {
+		return models.PortPolicyResponse{}, false
+	}
+	return *portPolicy, true
+}
+
+// VNIPartition returns a copy of a VNI partition document
+func (s *Server) VNIPartition(name string) (models.VNIPartitionResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vniPartition, ok := s.vniPartitions[name]
+	if !ok {
+		return models.VNIPartitionResponse{}, false
+	}
+	return *vniPartition, true
+}
+
+// VNIBlock returns a copy of a VNI block document
+func (s *Server) VNIBlock(name string) (models.VNIBlockResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vniBlock, ok := s.vniBlocks[name]
+	if !ok {
+		return models.VNIBlockResponse{}, false
+	}
+	return *vniBlock, true
+}
+
+// SetEnforcementStage changes the stage of an existing enforcement task
+func (s *Server) SetEnforcementStage(link string, stage string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.enforcementTasks[link]
+	if !ok {
+		return fmt.Errorf("enforcement task %s not found", link)
+	}
+	task.TaskInfo.Stage = stage
+	touch(&task.DocumentVersion, &task.DocumentUpdateTimeMicros)
+
+	return nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+
+	for i, f := range s.failures {
+		if f.method == r.Method && f.path == r.URL.Path {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			writeError(w, f.statusCode, "injected failure")
+			return
+		}
+	}
+
+	if r.URL.Path == TokenPath {
+		s.serveToken(w, r)
+		return
+	}
+
+	if s.RequireAuth && r.Header.Get("Authorization") != "Bearer "+s.AccessToken {
+		writeError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+
+	collection, name := splitPath(r.URL.Path)
+	switch collection {
+	case switchesPath:
+		s.serveSwitches(w, r, name)
+	case portsPath:
+		s.servePorts(w, r, name)
+	case vlansPath:
+		s.serveVLANs(w, r, name)
+	case portPoliciesPath:
+		s.servePortPolicies(w, r, name)
+	case vniPartitionsPath:
+		s.serveVNIPartitions(w, r, name)
+	case vniBlocksPath:
+		s.serveVNIBlocks(w, r, name)
+	case enforcementTasksPath:
+		s.serveEnforcementTasks(w, r, name)
+	default:
+		writeError(w, http.StatusNotFound, "service not found: "+r.URL.Path)
+	}
+}
+
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+		writeError(w, http.StatusBadRequest, "invalid token request")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.TokenResponse{
+		AccessToken: s.AccessToken,
+		ExpiresIn:   s.TokenExpiresIn,
+		TokenType:   "Bearer",
+		Scope:       "openid",
+	})
+}
+
+func (s *Server) serveSwitches(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if name == "" {
+		links := sortedLinks(switchesPath, s.switches)
+		writeJSON(w, http.StatusOK, models.SwitchesResponse{TotalCount: len(links), DocumentLinks: links, DocumentCount: len(links)})
+		return
+	}
+
+	sw, ok := s.switches[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "switch not found: "+name)
+		return
+	}
+	writeJSON(w, http.StatusOK, sw)
+}
+
+func (s *Server) servePorts(w http.ResponseWriter, r *http.Request, name string) {
+	port, ok := s.ports[name]
+	if name == "" || !ok {
+		writeError(w, http.StatusNotFound, "port not found: "+name)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, port)
+	case http.MethodPatch:
+		var request models.PortPATCHRequest
+		if !readJSON(w, r, &request) {
+			return
+		}
+		if request.DocumentVersion != 0 && request.DocumentVersion != port.DocumentVersion {
+			writeError(w, http.StatusConflict, fmt.Sprintf("port %s is at version %d, not %d", name, port.DocumentVersion, request.DocumentVersion))
+			return
+		}
+		for _, link := range request.PortPolicyLinks {
+			if _, ok := s.portPolicies[linkName(link)]; !ok || !strings.HasPrefix(link, portPoliciesPath+"/") {
+				writeError(w, http.StatusBadRequest, "port policy not found: "+link)
+				return
+			}
+		}
+		port.PortPolicyLinks = append([]string{}, request.PortPolicyLinks...)
+		touch(&port.DocumentVersion, &port.DocumentUpdateTimeMicros)
+		port.DocumentUpdateAction = "PATCH"
+		writeJSON(w, http.StatusOK, port)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) serveVLANs(w http.ResponseWriter, r *http.Request, name string) {
+	if name == "" {
+		switch r.Method {
+		case http.MethodGet:
+			links := []string{}
+			for id := range s.vlans {
+				links = append(links, fmt.Sprintf("%s/%d", vlansPath, id))
+			}
+			sort.Strings(links)
+			writeJSON(w, http.StatusOK, models.VLANsResponse{DocumentLinks: links, DocumentCount: len(links)})
+		case http.MethodPost:
+			var request models.VLANRequestData
+			if !readJSON(w, r, &request) {
+				return
+			}
+			if request.VLANID < 1 || request.VLANID > 4094 {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid VLAN ID: %d", request.VLANID))
+				return
+			}
+			if _, ok := s.vlans[request.VLANID]; ok {
+				writeError(w, http.StatusConflict, fmt.Sprintf("VLAN already exists: %d", request.VLANID))
+				return
+			}
+			vlan := &models.VLANResponse{
+				VLANID:   request.VLANID,
+				VLANName: request.VLANName,
+				Status:   request.Status,
+			}
+			touch(&vlan.DocumentVersion, &vlan.DocumentUpdateTimeMicros)
+			vlan.DocumentKind = "com:hpe:fabric:vlan"
+			vlan.DocumentSelfLink = fmt.Sprintf("%s/%d", vlansPath, request.VLANID)
+			vlan.DocumentUpdateAction = "POST"
+			s.vlans[request.VLANID] = vlan
+			writeJSON(w, http.StatusOK, vlan)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+		return
+	}
+
+	id, err := strconv.Atoi(name)
+	vlan, ok := s.vlans[id]
+	if err != nil || !ok {
+		writeError(w, http.StatusNotFound, "VLAN not found: "+name)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, vlan)
+	case http.MethodPatch:
+		var request models.VLANPatchRequest
+		if !readJSON(w, r, &request) {
+			return
+		}
+		vlan.Status = request.Status
+		touch(&vlan.DocumentVersion, &vlan.DocumentUpdateTimeMicros)
+		vlan.DocumentUpdateAction = "PATCH"
+		writeJSON(w, http.StatusOK, vlan)
+	case http.MethodDelete:
+		delete(s.vlans, id)
+		vlan.DocumentUpdateAction = "DELETE"
+		writeJSON(w, http.StatusOK, vlan)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) servePortPolicies(w http.ResponseWriter, r *http.Request, name string) {
+	if name == "" {
+		switch r.Method {
+		case http.MethodGet:
+			links := sortedLinks(portPoliciesPath, s.portPolicies)
+			writeJSON(w, http.StatusOK, models.PortPoliciesResponse{DocumentLinks: links, DocumentCount: len(links)})
+		case http.MethodPost:
+			var request models.VLANPortPolicyRequest
+			if !readJSON(w, r, &request) {
+				return
+			}
+			policyName := linkName(request.DocumentSelfLink)
+			if policyName == "" {
+				writeError(w, http.StatusBadRequest, "documentSelfLink is required")
+				return
+			}
+			if _, ok := s.portPolicies[policyName]; ok {
+				writeError(w, http.StatusConflict, "port policy already exists: "+policyName)
+				return
+			}
+			portPolicy := &models.PortPolicyResponse{
+				AllowedVlans:      request.AllowedVlans,
+				NativeVlanID:      request.NativeVlanID,
+				IsUntaggedAllowed: request.IsUntaggedAllowed,
+			}
+			if request.AutoRetry != nil {
+				portPolicy.AutoRetry = *request.AutoRetry
+			}
+			if request.HeadShellReset != nil {
+				portPolicy.HeadShellReset = *request.HeadShellReset
+			}
+			touch(&portPolicy.DocumentVersion, &portPolicy.DocumentUpdateTimeMicros)
+			portPolicy.DocumentKind = "com:hpe:fabric:port-policy"
+			portPolicy.DocumentSelfLink = portPoliciesPath + "/" + policyName
+			portPolicy.DocumentUpdateAction = "POST"
+			s.portPolicies[policyName] = portPolicy
+			writeJSON(w, http.StatusOK, portPolicy)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+		return
+	}
+
+	portPolicy, ok := s.portPolicies[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "port policy not found: "+name)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, portPolicy)
+	case http.MethodPatch:
+		var request models.VLANPortPolicyPatchRequest
+		if !readJSON(w, r, &request) {
+			return
+		}
+		portPolicy.AllowedVlans = request.AllowedVlans
+		portPolicy.NativeVlanID = request.NativeVlanID
+		portPolicy.IsUntaggedAllowed = request.IsUntaggedAllowed
+		if request.AutoRetry != nil {
+			portPolicy.AutoRetry = *request.AutoRetry
+		}
+		if request.HeadShellReset != nil {
+			portPolicy.HeadShellReset = *request.HeadShellReset
+		}
+		touch(&portPolicy.DocumentVersion, &portPolicy.DocumentUpdateTimeMicros)
+		portPolicy.DocumentUpdateAction = "PATCH"
+		writeJSON(w, http.StatusOK, portPolicy)
+	case http.MethodDelete:
+		delete(s.portPolicies, name)
+		portPolicy.DocumentUpdateAction = "DELETE"
+		writeJSON(w, http.StatusOK, portPolicy)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) serveVNIPartitions(w http.ResponseWriter, r *http.Request, name string) {
+	if name == "" {
+		switch r.Method {
+		case http.MethodGet:
+			links := sortedLinks(vniPartitionsPath, s.vniPartitions)
+			writeJSON(w, http.StatusOK, models.AllVNIPartitionsResponse{DocumentLinks: links, DocumentCount: len(links)})
+		case http.MethodPost:
+			var request models.VNIRequestData
+			if !readJSON(w, r, &request) {
+				return
+			}
+			if request.PartitionName == "" {
+				writeError(w, http.StatusBadRequest, "partitionName is required")
+				return
+			}
+			if _, ok := s.vniPartitions[request.PartitionName]; ok {
+				writeError(w, http.StatusConflict, "VNI partition already exists: "+request.PartitionName)
+				return
+			}
+			vniPartition := &models.VNIPartitionResponse{
+				PartitionName: request.PartitionName,
+				EdgePortDFA:   request.EdgePortDFA,
+			}
+			s.setVNIs(vniPartition, request)
+			touch(&vniPartition.DocumentVersion, &vniPartition.DocumentUpdateTimeMicros)
+			vniPartition.DocumentKind = "com:hpe:fabric:vni-partition"
+			vniPartition.DocumentSelfLink = vniPartitionsPath + "/" + request.PartitionName
+			vniPartition.DocumentUpdateAction = "POST"
+			s.vniPartitions[request.PartitionName] = vniPartition
+			writeJSON(w, http.StatusOK, vniPartition)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+		return
+	}
+
+	vniPartition, ok := s.vniPartitions[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "VNI partition not found: "+name)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, vniPartition)
+	case http.MethodPatch:
+		var request models.VNIRequestData
+		if !readJSON(w, r, &request) {
+			return
+		}
+		if request.VNICount != 0 || len(request.VNIRange) != 0 {
+			s.setVNIs(vniPartition, request)
+		}
+		if request.EdgePortDFA != nil {
+			vniPartition.EdgePortDFA = request.EdgePortDFA
+		}
+		touch(&vniPartition.DocumentVersion, &vniPartition.DocumentUpdateTimeMicros)
+		vniPartition.DocumentUpdateAction = "PATCH"
+		writeJSON(w, http.StatusOK, vniPartition)
+	case http.MethodDelete:
+		for _, vniBlock := range s.vniBlocks {
+			if vniBlock.PartitionName == name {
+				writeError(w, http.StatusBadRequest, "VNI partition is in use by VNI block "+vniBlock.VNIBlockName)
+				return
+			}
+		}
+		delete(s.vniPartitions, name)
+		vniPartition.DocumentUpdateAction = "DELETE"
+		writeJSON(w, http.StatusOK, vniPartition)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// setVNIs sets the VNI ranges of a partition, handing out a range from
+// firstVNI upwards when only a count is requested
+func (s *Server) setVNIs(vniPartition *models.VNIPartitionResponse, request models.VNIRequestData) {
+	if len(request.VNIRange) != 0 {
+		vniPartition.VNIRange = request.VNIRange
+		vniPartition.VNICount = len(expandRanges(request.VNIRange))
+		return
+	}
+
+	vniPartition.VNIRange = []string{fmt.Sprintf("%d-%d", s.nextVNI, s.nextVNI+request.VNICount-1)}
+	vniPartition.VNICount = request.VNICount
+	s.nextVNI += request.VNICount
+}
+
+func (s *Server) serveVNIBlocks(w http.ResponseWriter, r *http.Request, name string) {
+	if name == "" {
+		switch r.Method {
+		case http.MethodGet:
+			links := sortedLinks(vniBlocksPath, s.vniBlocks)
+			writeJSON(w, http.StatusOK, models.AllVNIBlocksResponse{DocumentLinks: links, DocumentCount: len(links)})
+		case http.MethodPost:
+			var request models.VNIBlockRequestData
+			if !readJSON(w, r, &request) {
+				return
+			}
+			if request.VNIBlockName == "" {
+				writeError(w, http.StatusBadRequest, "vniBlockName is required")
+				return
+			}
+			if _, ok := s.vniPartitions[request.VNIPartitionName]; !ok {
+				writeError(w, http.StatusBadRequest, "VNI partition not found: "+request.VNIPartitionName)
+				return
+			}
+			if _, ok := s.vniBlocks[request.VNIBlockName]; ok {
+				writeError(w, http.StatusConflict, "VNI block already exists: "+request.VNIBlockName)
+				return
+			}
+			vniBlock := &models.VNIBlockResponse{
+				VNIBlockName:  request.VNIBlockName,
+				PartitionName: request.VNIPartitionName,
+				VNIBlockRange: request.VNIBlockRange,
+				PortDFAs:      request.PortDFAs,
+			}
+			touch(&vniBlock.DocumentVersion, &vniBlock.DocumentUpdateTimeMicros)
+			vniBlock.DocumentKind = "com:hpe:fabric:vni-block"
+			vniBlock.DocumentSelfLink = vniBlocksPath + "/" + request.VNIBlockName
+			vniBlock.DocumentUpdateAction = "POST"
+			vniBlock.EnforcementTaskServiceLink = s.startEnforcement(vniBlock, nil, nil)
+			s.vniBlocks[request.VNIBlockName] = vniBlock
+			writeJSON(w, http.StatusOK, vniBlock)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+		return
+	}
+
+	vniBlock, ok := s.vniBlocks[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "VNI block not found: "+name)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, vniBlock)
+	case http.MethodPatch:
+		var request models.VNIBlockPatchRequest
+		if !readJSON(w, r, &request) {
+			return
+		}
+		previousVNIs := expandRanges(vniBlock.VNIBlockRange)
+		previousDFAs := vniBlock.PortDFAs
+		if request.VNIBlockRange != nil {
+			vniBlock.VNIBlockRange = request.VNIBlockRange
+		}
+		if request.PortDFAs != nil {
+			vniBlock.PortDFAs = request.PortDFAs
+		}
+		touch(&vniBlock.DocumentVersion, &vniBlock.DocumentUpdateTimeMicros)
+		vniBlock.DocumentUpdateAction = "PATCH"
+		vniBlock.EnforcementTaskServiceLink = s.startEnforcement(vniBlock, previousVNIs, previousDFAs)
+		writeJSON(w, http.StatusOK, vniBlock)
+	case http.MethodDelete:
+		delete(s.vniBlocks, name)
+		vniBlock.DocumentUpdateAction = "DELETE"
+		writeJSON(w, http.StatusOK, vniBlock)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// startEnforcement records an enforcement task for a VNI block and returns its link
+func (s *Server) startEnforcement(vniBlock *models.VNIBlockResponse, previousVNIs []int, previousDFAs []int) string {
+	s.nextTask++
+	link := fmt.Sprintf("%s/%d", enforcementTasksPath, s.nextTask)
+
+	vnis := expandRanges(vniBlock.VNIBlockRange)
+	task := &models.VniBlockEnforcementTaskServiceState{
+		EdgePortDFAs:       vniBlock.PortDFAs,
+		RemoveEdgePortDFAs: difference(previousDFAs, vniBlock.PortDFAs),
+		VniList:            vnis,
+		AddVniList:         difference(vnis, previousVNIs),
+		RemoveVniList:      difference(previousVNIs, vnis),
+		SubStage:           "ENFORCE",
+		TaskInfo:           models.TaskInfo{Stage: s.EnforcementStage},
+	}
+	touch(&task.DocumentVersion, &task.DocumentUpdateTimeMicros)
+	task.DocumentKind = "com:hpe:fabric:vni-block-enforcement-task"
+	task.DocumentSelfLink = link
+	s.enforcementTasks[link] = task
+
+	return link
+}
+
+func (s *Server) serveEnforcementTasks(w http.ResponseWriter, r *http.Request, name string) {
+	task, ok := s.enforcementTasks[enforcementTasksPath+"/"+name]
+	if r.Method != http.MethodGet || !ok {
+		writeError(w, http.StatusNotFound, "enforcement task not found: "+name)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, task)
+}
+
+// splitPath splits a request path into its collection and document name
+func splitPath(requestPath string) (string, string) {
+	requestPath = strings.TrimSuffix(requestPath, "/")
+	for _, collection := range []string{switchesPath, portsPath, vlansPath, portPoliciesPath, vniPartitionsPath, vniBlocksPath, enforcementTasksPath} {
+		if requestPath == collection {
+			return collection, ""
+		}
+		if strings.HasPrefix(requestPath, collection+"/") {
+			name, err := url.PathUnescape(strings.TrimPrefix(requestPath, collection+"/"))
+			if err != nil || strings.Contains(name, "/") {
+				return "", ""
+			}
+			return collection, name
+		}
+	}
+
+	return "", ""
+}
+
+// linkName returns the document name of a link, accepting a bare name as well
+func linkName(link string) string {
+	return link[strings.LastIndex(link, "/")+1:]
+}
+
+func sortedLinks[T any](collection string, documents map[string]T) []string {
+	links := []string{}
+	for name := range documents {
+		links = append(links, collection+"/"+name)
+	}
+	sort.Strings(links)
+
+	return links
+}
+
+// expandRanges expands "start-end" and single VNI strings into a list of VNIs
+func expandRanges(ranges []string) []int {
+	vnis := []int{}
+	for _, r := range ranges {
+		bounds := strings.SplitN(r, "-", 2)
+		start, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			continue
+		}
+		end := start
+		if len(bounds) == 2 {
+			end, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+			if err != nil {
+				continue
+			}
+		}
+		for vni := start; vni <= end; vni++ {
+			vnis = append(vnis, vni)
+		}
+	}
+
+	return vnis
+}
+
+// difference returns the elements of a that are not in b
+func difference(a []int, b []int) []int {
+	in := make(map[int]bool, len(b))
+	for _, x := range b {
+		in[x] = true
+	}
+
+	diff := []int{}
+	for _, x := range a {
+		if !in[x] {
+			diff = append(diff, x)
+		}
+	}
+
+	return diff
+}
+
+func touch(documentVersion *int, documentUpdateTimeMicros *int) {
+	*documentVersion++
+	*documentUpdateTimeMicros = int(time.Now().UnixMicro())
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, v)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	writeJSON(w, statusCode, models.ErrorResponse{
+		Message:      message,
+		StatusCode:   statusCode,
+		DocumentKind: "com:vmware:xenon:common:ServiceErrorResponse",
+	})
+}
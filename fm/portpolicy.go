@@ -0,0 +1,143 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package fm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+// portPatchBackoff spaces the attempts to update a port that keeps being
+// changed by someone else
+var portPatchBackoff = wait.Backoff{
+	Steps:    8,
+	Duration: 10 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.5,
+}
+
+// PortPolicyManager updates the port policies applied to the edge ports. The
+// port policies of a port are shared by every tenant and by the operators of
+// the fabric, so a port is never overwritten blindly: the desired policies are
+// computed from the policies the port has now, and the PATCH carries the
+// documentVersion they were read at. When the port changed in between, Fabric
+// Manager answers 409 and the update is computed again.
+type PortPolicyManager struct {
+	client *Client
+}
+
+// Apply applies a port policy to the ports. A port that already has the
+// policy is not updated
+func (m *PortPolicyManager) Apply(ctx context.Context, portNames []string, policyLink string) error {
+	for _, portName := range portNames {
+		_, err := m.Update(ctx, portName, []string{policyLink}, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Remove removes a port policy from the ports. A port that does not have the
+// policy is not updated
+func (m *PortPolicyManager) Remove(ctx context.Context, portNames []string, policyLink string) error {
+	for _, portName := range portNames {
+		_, err := m.Update(ctx, portName, nil, []string{policyLink})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Update adds and removes port policies of a port, retrying when the port
+// was changed concurrently
+func (m *PortPolicyManager) Update(ctx context.Context, portName string, add []string, remove []string) (models.PortResponse, error) {
+	var port models.PortResponse
+	err := retry.OnError(portPatchBackoff, IsConflict, func() error {
+		var err error
+		port, err = m.client.Ports().Get(ctx, portName)
+		if err != nil {
+			return err
+		}
+
+		desired := DesiredPortPolicies(port.PortPolicyLinks, add, remove)
+		if equalLinks(desired, port.PortPolicyLinks) {
+			return nil
+		}
+
+		port, err = m.client.Ports().Patch(ctx, portName, models.PortPATCHRequest{
+			PortPolicyLinks: desired,
+			DocumentVersion: port.DocumentVersion,
+		})
+		if IsConflict(err) {
+			log.Printf("port %s was changed concurrently, updating its port policies again", portName)
+		}
+		return err
+	})
+	if err != nil {
+		return port, fmt.Errorf("could not update port policies of port %s: %w", portName, err)
+	}
+
+	return port, nil
+}
+
+// DesiredPortPolicies returns the port policies of a port once add is applied
+// and remove is removed. The policies the port does not have yet come first,
+// as they were always prepended; the policies of the port keep their order
+// and duplicates are dropped
+func DesiredPortPolicies(current []string, add []string, remove []string) []string {
+	skip := make(map[string]bool, len(current)+len(remove))
+	for _, link := range remove {
+		skip[link] = true
+	}
+
+	var kept []string
+	for _, link := range current {
+		if !skip[link] {
+			kept = append(kept, link)
+		}
+		skip[link] = true
+	}
+
+	desired := []string{}
+	for _, link := range add {
+		if !skip[link] {
+			desired = append(desired, link)
+		}
+		skip[link] = true
+	}
+
+	return append(desired, kept...)
+}
+
+// IsConflict checks if Fabric Manager rejected a request because the document
+// was changed since it was read
+func IsConflict(err error) bool {
+	return httpclient.IsConflict(err)
+}
+
+func equalLinks(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,160 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package fm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.hpe.com/hpe/sshot-net-operator/fm/fmtest"
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+// patchRequests counts the PATCH requests sent for a port
+func patchRequests(server *fmtest.Server, portName string) int {
+	var count int
+	for _, r := range server.Requests() {
+		if r == "PATCH "+portsPath+"/"+portName {
+			count++
+		}
+	}
+
+	return count
+}
+
+func newPortPolicyServer(t *testing.T, policies ...string) (*fmtest.Server, *Client) {
+	t.Helper()
+
+	server := fmtest.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSwitch("x1000c2r3b0", 1, 3)
+	if err := server.AddEdgePort("x1000c2r3b0", 100, "x1000c2r3j100p0", "x1000c2s0b0n0h0"); err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient(httpclient.NewClient(server.URL))
+	for _, policy := range policies {
+		_, err := client.PortPolicies().Create(context.Background(), models.VLANPortPolicyRequest{DocumentSelfLink: policy})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return server, client
+}
+
+func TestDesiredPortPolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  []string
+		add      []string
+		remove   []string
+		expected []string
+	}{
+		{name: "add", current: []string{"a", "b"}, add: []string{"c"}, expected: []string{"c", "a", "b"}},
+		{name: "already applied", current: []string{"a", "b"}, add: []string{"b"}, expected: []string{"a", "b"}},
+		{name: "duplicates", current: []string{"a", "b", "a"}, add: []string{"c", "c"}, expected: []string{"c", "a", "b"}},
+		{name: "remove", current: []string{"a", "b", "a"}, remove: []string{"a"}, expected: []string{"b"}},
+		{name: "remove all", current: []string{"a"}, remove: []string{"a", "b"}, expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desired := DesiredPortPolicies(tt.current, tt.add, tt.remove)
+			if !reflect.DeepEqual(desired, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, desired)
+			}
+		})
+	}
+}
+
+func TestPortPolicyManager(t *testing.T) {
+	ctx := context.Background()
+	server, client := newPortPolicyServer(t, "vcluster-blue", "vcluster-red")
+	blue, red := PortPolicyLink("vcluster-blue"), PortPolicyLink("vcluster-red")
+	ports := []string{"x1000c2r3j100p0"}
+
+	if err := client.PortPolicyManager().Apply(ctx, ports, blue); err != nil {
+		t.Fatal(err)
+	}
+	// applying a policy again does not duplicate it nor update the port
+	if err := client.PortPolicyManager().Apply(ctx, ports, blue); err != nil {
+		t.Fatal(err)
+	}
+	if patches := patchRequests(server, ports[0]); patches != 1 {
+		t.Errorf("expected 1 PATCH, got %d", patches)
+	}
+
+	// a conflicting update is computed again
+	server.FailNext(http.MethodPatch, portsPath+"/"+ports[0], http.StatusConflict)
+	if err := client.PortPolicyManager().Apply(ctx, ports, red); err != nil {
+		t.Fatal(err)
+	}
+	port, _ := server.Port(ports[0])
+	if !reflect.DeepEqual(port.PortPolicyLinks, []string{red, blue}) {
+		t.Errorf("unexpected port policies %v", port.PortPolicyLinks)
+	}
+
+	if err := client.PortPolicyManager().Remove(ctx, ports, blue); err != nil {
+		t.Fatal(err)
+	}
+	port, _ = server.Port(ports[0])
+	if !reflect.DeepEqual(port.PortPolicyLinks, []string{red}) {
+		t.Errorf("unexpected port policies %v", port.PortPolicyLinks)
+	}
+
+	// other errors are not retried
+	server.FailNext(http.MethodPatch, portsPath+"/"+ports[0], http.StatusInternalServerError)
+	err := client.PortPolicyManager().Apply(ctx, ports, blue)
+	if err == nil || IsConflict(err) || !strings.Contains(err.Error(), "could not update port policies of port x1000c2r3j100p0") {
+		t.Errorf("expected the update to fail, got %v", err)
+	}
+}
+
+func TestPortPolicyManagerConcurrent(t *testing.T) {
+	ctx := context.Background()
+	var policies []string
+	for i := 0; i < 4; i++ {
+		policies = append(policies, fmt.Sprintf("vcluster-%d", i))
+	}
+	server, _ := newPortPolicyServer(t, policies...)
+
+	// each replica has its own client, only the documentVersion protects the port
+	var wg sync.WaitGroup
+	errs := make(chan error, len(policies))
+	for _, policy := range policies {
+		wg.Add(1)
+		go func(policy string) {
+			defer wg.Done()
+			client := NewClient(httpclient.NewClient(server.URL))
+			errs <- client.PortPolicyManager().Apply(ctx, []string{"x1000c2r3j100p0"}, PortPolicyLink(policy))
+		}(policy)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	port, _ := server.Port("x1000c2r3j100p0")
+	links := append([]string{}, port.PortPolicyLinks...)
+	sort.Strings(links)
+	var expected []string
+	for _, policy := range policies {
+		expected = append(expected, PortPolicyLink(policy))
+	}
+	if !reflect.DeepEqual(links, expected) {
+		t.Errorf("expected port policies %v, got %v", expected, links)
+	}
+}
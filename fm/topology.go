@@ -0,0 +1,243 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package fm
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+// DefaultTopologyMaxAge is how long the topology is used before it is refreshed
+const DefaultTopologyMaxAge = time.Minute
+
+// EdgePort is an edge port of the fabric and the node it is connected to
+type EdgePort struct {
+	// Name is the name of the port, e.g. "x1000c2r3j100p0"
+	Name string
+
+	// Switch is the name of the switch the port belongs to
+	Switch string
+
+	GroupID  int
+	SwitchID int
+	PortID   int
+
+	// DFA is the destination fabric address of the port
+	DFA int
+
+	// DstPort is the node side of the link, e.g. "x1000c2s0b0n1h0"
+	DstPort string
+
+	// Xname is the node the port is connected to, e.g. "x1000c2s0b0n1"
+	Xname string
+}
+
+// EdgePortDFA returns the DFA of an edge port
+func EdgePortDFA(groupID int, switchID int, portID int) int {
+	return (groupID << 23) | (switchID << 18) | (portID << 12)
+}
+
+// Topology caches the edge ports of the fabric, indexed by the xname of the
+// node they are connected to. It is shared by all the users of a Client.
+//
+// The topology is built on first use and refreshed once it is older than
+// MaxAge. A refresh lists the switches and only fetches the edge ports of the
+// switches whose documentUpdateTimeMicros changed since the last refresh.
+type Topology struct {
+	client *Client
+
+	// MaxAge is how long the topology is used before it is refreshed
+	MaxAge time.Duration
+
+	mu        sync.Mutex
+	switches  map[string]*cachedSwitch
+	byXname   map[string][]EdgePort
+	refreshed time.Time
+	now       func() time.Time
+}
+
+type cachedSwitch struct {
+	updateTimeMicros int
+	// order is the position of the switch in the switch list
+	order     int
+	edgePorts []EdgePort
+}
+
+func newTopology(c *Client) *Topology {
+	return &Topology{
+		client:   c,
+		MaxAge:   DefaultTopologyMaxAge,
+		switches: make(map[string]*cachedSwitch),
+		byXname:  make(map[string][]EdgePort),
+		now:      time.Now,
+	}
+}
+
+// EdgePorts returns the edge ports connected to the nodes, in the order of
+// the switches and of the ports on each switch. When some switches have never
+// been crawled, the edge ports found are returned with a *CrawlError
+func (t *Topology) EdgePorts(ctx context.Context, xnames []string) ([]EdgePort, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	err := t.refresh(ctx)
+	var crawlErr *CrawlError
+	if err != nil && !errors.As(err, &crawlErr) {
+		return nil, err
+	}
+
+	var edgePorts []EdgePort
+	seen := make(map[string]bool)
+	for _, xname := range xnames {
+		if seen[xname] {
+			continue
+		}
+		seen[xname] = true
+		edgePorts = append(edgePorts, t.byXname[xname]...)
+	}
+	t.sort(edgePorts)
+
+	return edgePorts, err
+}
+
+// AllEdgePorts returns all the edge ports of the fabric. Like EdgePorts, it
+// returns the edge ports found along with a *CrawlError
+func (t *Topology) AllEdgePorts(ctx context.Context) ([]EdgePort, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	err := t.refresh(ctx)
+	var crawlErr *CrawlError
+	if err != nil && !errors.As(err, &crawlErr) {
+		return nil, err
+	}
+
+	var edgePorts []EdgePort
+	for _, sw := range t.switches {
+		edgePorts = append(edgePorts, sw.edgePorts...)
+	}
+	t.sort(edgePorts)
+
+	return edgePorts, err
+}
+
+// Invalidate makes the next lookup refresh the topology
+func (t *Topology) Invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.refreshed = time.Time{}
+}
+
+// refresh updates the switches that changed since the last refresh. When
+// some switches cannot be crawled, their previous state is kept and the
+// returned *CrawlError only lists the switches that have no previous state
+func (t *Topology) refresh(ctx context.Context) error {
+	if !t.refreshed.IsZero() && t.now().Sub(t.refreshed) < t.MaxAge {
+		return nil
+	}
+
+	crawled, err := t.client.Crawler().Crawl(ctx, func(name string, sw models.SwitchResponse) bool {
+		cached, ok := t.switches[name]
+		return ok && cached.updateTimeMicros == sw.DocumentUpdateTimeMicros
+	})
+	var crawlErr *CrawlError
+	if err != nil && !errors.As(err, &crawlErr) {
+		return err
+	}
+
+	switches := make(map[string]*cachedSwitch, len(crawled))
+	for i, c := range crawled {
+		cached := t.switches[c.Name]
+		if !c.Skipped {
+			cached = &cachedSwitch{updateTimeMicros: c.Switch.DocumentUpdateTimeMicros}
+			for j, p := range c.Switch.EdgePorts {
+				port := c.Ports[j]
+				cached.edgePorts = append(cached.edgePorts, EdgePort{
+					Name:     p.ConnPort,
+					Switch:   c.Name,
+					GroupID:  c.Switch.GrpID,
+					SwitchID: c.Switch.SwcNum,
+					PortID:   p.PortNum,
+					DFA:      EdgePortDFA(c.Switch.GrpID, c.Switch.SwcNum, p.PortNum),
+					DstPort:  port.DstPort,
+					Xname:    nodeXname(port.DstPort),
+				})
+			}
+		}
+		cached.order = i
+		switches[c.Name] = cached
+	}
+
+	//keep the previous state of the switches that could not be crawled
+	var missing CrawlError
+	if crawlErr != nil {
+		for _, switchErr := range crawlErr.Errors {
+			if cached, ok := t.switches[switchErr.Switch]; ok {
+				cached.order = len(switches)
+				switches[switchErr.Switch] = cached
+				continue
+			}
+			missing.Errors = append(missing.Errors, switchErr)
+		}
+	}
+
+	byXname := make(map[string][]EdgePort)
+	for _, sw := range switches {
+		for _, edgePort := range sw.edgePorts {
+			if edgePort.Xname != "" {
+				byXname[edgePort.Xname] = append(byXname[edgePort.Xname], edgePort)
+			}
+		}
+	}
+
+	t.switches = switches
+	t.byXname = byXname
+
+	//retry the switches that failed on the next lookup
+	if crawlErr != nil {
+		t.refreshed = time.Time{}
+		if len(missing.Errors) > 0 {
+			return &missing
+		}
+		return nil
+	}
+	t.refreshed = t.now()
+
+	return nil
+}
+
+// sort orders edge ports by switch and by position on the switch
+func (t *Topology) sort(edgePorts []EdgePort) {
+	position := make(map[string]int)
+	for _, sw := range t.switches {
+		for i, edgePort := range sw.edgePorts {
+			position[edgePort.Name] = i
+		}
+	}
+
+	sort.SliceStable(edgePorts, func(i, j int) bool {
+		a, b := edgePorts[i], edgePorts[j]
+		if a.Switch != b.Switch {
+			return t.switches[a.Switch].order < t.switches[b.Switch].order
+		}
+		return position[a.Name] < position[b.Name]
+	})
+}
+
+// nodeXname returns the node of the node side of a link, e.g. "x1000c2s0b0n1"
+// for "x1000c2s0b0n1h0"
+func nodeXname(dstPort string) string {
+	if len(dstPort) < 2 {
+		return ""
+	}
+
+	return dstPort[:len(dstPort)-2]
+}
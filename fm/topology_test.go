@@ -0,0 +1,116 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package fm
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.hpe.com/hpe/sshot-net-operator/fm/fmtest"
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+)
+
+// portRequests counts the port documents fetched from the fake server
+func portRequests(server *fmtest.Server) int {
+	var count int
+	for _, r := range server.Requests() {
+		if strings.HasPrefix(r, "GET "+portsPath+"/") {
+			count++
+		}
+	}
+
+	return count
+}
+
+func TestTopology(t *testing.T) {
+	ctx := context.Background()
+
+	server := fmtest.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSwitch("x1000c2r3b0", 1, 3)
+	server.AddSwitch("x1000c2r5b0", 1, 5)
+	for _, p := range []struct {
+		switchName string
+		portNum    int
+		portName   string
+		dstPort    string
+	}{
+		{"x1000c2r3b0", 100, "x1000c2r3j100p0", "x1000c2s0b0n0h0"},
+		{"x1000c2r3b0", 101, "x1000c2r3j101p0", "x1000c2s0b0n1h0"},
+		{"x1000c2r5b0", 100, "x1000c2r5j100p0", "x1000c2s0b0n0h1"},
+	} {
+		if err := server.AddEdgePort(p.switchName, p.portNum, p.portName, p.dstPort); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := NewClient(httpclient.NewClient(server.URL))
+	topology := client.Topology()
+	now := time.Now()
+	topology.now = func() time.Time { return now }
+
+	edgePorts, err := topology.EdgePorts(ctx, []string{"x1000c2s0b0n0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []EdgePort{
+		{Name: "x1000c2r3j100p0", Switch: "x1000c2r3b0", GroupID: 1, SwitchID: 3, PortID: 100,
+			DFA: EdgePortDFA(1, 3, 100), DstPort: "x1000c2s0b0n0h0", Xname: "x1000c2s0b0n0"},
+		{Name: "x1000c2r5j100p0", Switch: "x1000c2r5b0", GroupID: 1, SwitchID: 5, PortID: 100,
+			DFA: EdgePortDFA(1, 5, 100), DstPort: "x1000c2s0b0n0h1", Xname: "x1000c2s0b0n0"},
+	}
+	if !reflect.DeepEqual(edgePorts, expected) {
+		t.Errorf("expected edge ports %+v, got %+v", expected, edgePorts)
+	}
+	if portRequests(server) != 3 {
+		t.Errorf("expected every port to be fetched once, got %d requests", portRequests(server))
+	}
+
+	// lookups within MaxAge do not reach Fabric Manager
+	requests := len(server.Requests())
+	edgePorts, err = topology.EdgePorts(ctx, []string{"x1000c2s0b0n1", "x1000c2s0b0n1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edgePorts) != 1 || edgePorts[0].Name != "x1000c2r3j101p0" {
+		t.Errorf("expected the edge port of x1000c2s0b0n1, got %+v", edgePorts)
+	}
+	if len(server.Requests()) != requests {
+		t.Errorf("expected the cached topology to be used, got %v", server.Requests()[requests:])
+	}
+
+	// only the ports of the switch that changed are fetched again
+	time.Sleep(time.Millisecond)
+	if err := server.AddEdgePort("x1000c2r5b0", 101, "x1000c2r5j101p0", "x1000c2s1b0n0h0"); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(DefaultTopologyMaxAge)
+	edgePorts, err = topology.EdgePorts(ctx, []string{"x1000c2s1b0n0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edgePorts) != 1 || edgePorts[0].DFA != EdgePortDFA(1, 5, 101) {
+		t.Errorf("expected the new edge port, got %+v", edgePorts)
+	}
+	if portRequests(server) != 5 {
+		t.Errorf("expected the two ports of the changed switch to be fetched, got %d requests", portRequests(server)-3)
+	}
+
+	all, err := topology.AllEdgePorts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, edgePort := range all {
+		names = append(names, edgePort.Name)
+	}
+	expectedNames := []string{"x1000c2r3j100p0", "x1000c2r3j101p0", "x1000c2r5j100p0", "x1000c2r5j101p0"}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("expected all edge ports %v, got %v", expectedNames, names)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package fm
+
+import (
+	"context"
+	"fmt"
+
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+// VLANsService provides access to the VLAN documents
+type VLANsService struct {
+	client *Client
+}
+
+// List gets the links of all the VLANs
+func (s *VLANsService) List(ctx context.Context) (models.VLANsResponse, error) {
+	var vlans models.VLANsResponse
+	err := s.client.do(ctx, "GET", vlansPath, nil, &vlans)
+	if err != nil {
+		return vlans, fmt.Errorf("could not get VLANs: %w", err)
+	}
+
+	return vlans, nil
+}
+
+// Get gets a VLAN by its ID
+func (s *VLANsService) Get(ctx context.Context, vlanID int) (models.VLANResponse, error) {
+	var vlan models.VLANResponse
+	err := s.client.do(ctx, "GET", VLANLink(vlanID), nil, &vlan)
+	if err != nil {
+		return vlan, fmt.Errorf("could not get VLAN %d: %w", vlanID, err)
+	}
+
+	return vlan, nil
+}
+
+// Create creates a VLAN
+func (s *VLANsService) Create(ctx context.Context, request models.VLANRequestData) (models.VLANResponse, error) {
+	var vlan models.VLANResponse
+	err := s.client.do(ctx, "POST", vlansPath, request, &vlan)
+	if err != nil {
+		return vlan, fmt.Errorf("could not create VLAN %s: %w", request.VLANName, err)
+	}
+
+	return vlan, nil
+}
+
+// Patch updates the status of a VLAN
+func (s *VLANsService) Patch(ctx context.Context, vlanID int, request models.VLANPatchRequest) (models.VLANResponse, error) {
+	var vlan models.VLANResponse
+	err := s.client.do(ctx, "PATCH", VLANLink(vlanID), request, &vlan)
+	if err != nil {
+		return vlan, fmt.Errorf("could not update VLAN %d: %w", vlanID, err)
+	}
+
+	return vlan, nil
+}
+
+// Delete deletes a VLAN by its ID
+func (s *VLANsService) Delete(ctx context.Context, vlanID int) error {
+	err := s.client.do(ctx, "DELETE", VLANLink(vlanID), nil, nil)
+	if err != nil {
+		return fmt.Errorf("could not delete VLAN %d: %w", vlanID, err)
+	}
+
+	return nil
+}
+
+// VLANLink returns the document link of a VLAN
+func VLANLink(vlanID int) string {
+	return fmt.Sprintf("%s/%d", vlansPath, vlanID)
+}
+
+// PortPoliciesService provides access to the port policy documents
+type PortPoliciesService struct {
+	client *Client
+}
+
+// List gets the links of all the port policies
+func (s *PortPoliciesService) List(ctx context.Context) (models.PortPoliciesResponse, error) {
+	var portPolicies models.PortPoliciesResponse
+	err := s.client.do(ctx, "GET", portPoliciesPath, nil, &portPolicies)
+	if err != nil {
+		return portPolicies, fmt.Errorf("could not get port policies: %w", err)
+	}
+
+	return portPolicies, nil
+}
+
+// Get gets a port policy by its name
+func (s *PortPoliciesService) Get(ctx context.Context, name string) (models.PortPolicyResponse, error) {
+	var portPolicy models.PortPolicyResponse
+	err := s.client.do(ctx, "GET", PortPolicyLink(name), nil, &portPolicy)
+	if err != nil {
+		return portPolicy, fmt.Errorf("could not get port policy %s: %w", name, err)
+	}
+
+	return portPolicy, nil
+}
+
+// Create creates a port policy. The policy name is taken from request.DocumentSelfLink
+func (s *PortPoliciesService) Create(ctx context.Context, request models.VLANPortPolicyRequest) (models.VLANPortPolicyResponse, error) {
+	var portPolicy models.VLANPortPolicyResponse
+	err := s.client.do(ctx, "POST", portPoliciesPath, request, &portPolicy)
+	if err != nil {
+		return portPolicy, fmt.Errorf("could not create port policy %s: %w", request.DocumentSelfLink, err)
+	}
+
+	return portPolicy, nil
+}
+
+// Patch updates the VLANs of a port policy
+func (s *PortPoliciesService) Patch(ctx context.Context, name string, request models.VLANPortPolicyPatchRequest) (models.PortPolicyResponse, error) {
+	var portPolicy models.PortPolicyResponse
+	err := s.client.do(ctx, "PATCH", PortPolicyLink(name), request, &portPolicy)
+	if err != nil {
+		return portPolicy, fmt.Errorf("could not update port policy %s: %w", name, err)
+	}
+
+	return portPolicy, nil
+}
+
+// Delete deletes a port policy by its name
+func (s *PortPoliciesService) Delete(ctx context.Context, name string) error {
+	err := s.client.do(ctx, "DELETE", PortPolicyLink(name), nil, nil)
+	if err != nil {
+		return fmt.Errorf("could not delete port policy %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// PortPolicyLink returns the document link of a port policy
+func PortPolicyLink(name string) string {
+	return portPoliciesPath + "/" + name
+}
@@ -0,0 +1,142 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package fm
+
+import (
+	"context"
+	"fmt"
+
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+// VNIPartitionsService provides access to the VNI partition documents
+type VNIPartitionsService struct {
+	client *Client
+}
+
+// List gets the links of all the VNI partitions
+func (s *VNIPartitionsService) List(ctx context.Context) (models.AllVNIPartitionsResponse, error) {
+	var vniPartitions models.AllVNIPartitionsResponse
+	err := s.client.do(ctx, "GET", vniPartitionsPath, nil, &vniPartitions)
+	if err != nil {
+		return vniPartitions, fmt.Errorf("could not get all VNI partitions: %w", err)
+	}
+
+	return vniPartitions, nil
+}
+
+// Get gets a VNI partition by its name
+func (s *VNIPartitionsService) Get(ctx context.Context, name string) (models.VNIPartitionResponse, error) {
+	var vniPartition models.VNIPartitionResponse
+	err := s.client.do(ctx, "GET", vniPartitionsPath+"/"+name, nil, &vniPartition)
+	if err != nil {
+		return vniPartition, fmt.Errorf("could not get VNI partition %s: %w", name, err)
+	}
+
+	return vniPartition, nil
+}
+
+// Create creates a VNI partition
+func (s *VNIPartitionsService) Create(ctx context.Context, request models.VNIRequestData) (models.VNIPartitionResponse, error) {
+	var vniPartition models.VNIPartitionResponse
+	err := s.client.do(ctx, "POST", vniPartitionsPath, request, &vniPartition)
+	if err != nil {
+		return vniPartition, fmt.Errorf("could not create VNI partition %s: %w", request.PartitionName, err)
+	}
+
+	return vniPartition, nil
+}
+
+// Update updates a VNI partition
+func (s *VNIPartitionsService) Update(ctx context.Context, name string, request models.VNIRequestData) (models.VNIPartitionResponse, error) {
+	var vniPartition models.VNIPartitionResponse
+	err := s.client.do(ctx, "PATCH", vniPartitionsPath+"/"+name, request, &vniPartition)
+	if err != nil {
+		return vniPartition, fmt.Errorf("could not update VNI partition %s: %w", name, err)
+	}
+
+	return vniPartition, nil
+}
+
+// Delete deletes a VNI partition by its name
+func (s *VNIPartitionsService) Delete(ctx context.Context, name string) error {
+	err := s.client.do(ctx, "DELETE", vniPartitionsPath+"/"+name, nil, nil)
+	if err != nil {
+		return fmt.Errorf("could not delete VNI partition %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// VNIBlocksService provides access to the VNI block documents
+type VNIBlocksService struct {
+	client *Client
+}
+
+// List gets the links of all the VNI blocks
+func (s *VNIBlocksService) List(ctx context.Context) (models.AllVNIBlocksResponse, error) {
+	var vniBlocks models.AllVNIBlocksResponse
+	err := s.client.do(ctx, "GET", vniBlocksPath, nil, &vniBlocks)
+	if err != nil {
+		return vniBlocks, fmt.Errorf("could not get all VNI blocks: %w", err)
+	}
+
+	return vniBlocks, nil
+}
+
+// Get gets a VNI block by its name
+func (s *VNIBlocksService) Get(ctx context.Context, name string) (models.VNIBlockResponse, error) {
+	var vniBlock models.VNIBlockResponse
+	err := s.client.do(ctx, "GET", vniBlocksPath+"/"+name, nil, &vniBlock)
+	if err != nil {
+		return vniBlock, fmt.Errorf("could not get VNI block %s: %w", name, err)
+	}
+
+	return vniBlock, nil
+}
+
+// Create creates a VNI block
+func (s *VNIBlocksService) Create(ctx context.Context, request models.VNIBlockRequestData) (models.VNIBlockResponse, error) {
+	var vniBlock models.VNIBlockResponse
+	err := s.client.do(ctx, "POST", vniBlocksPath, request, &vniBlock)
+	if err != nil {
+		return vniBlock, fmt.Errorf("could not create VNI block %s: %w", request.VNIBlockName, err)
+	}
+
+	return vniBlock, nil
+}
+
+// Patch updates the VNI ranges and port DFAs of a VNI block
+func (s *VNIBlocksService) Patch(ctx context.Context, name string, request models.VNIBlockPatchRequest) (models.VNIBlockResponse, error) {
+	var vniBlock models.VNIBlockResponse
+	err := s.client.do(ctx, "PATCH", vniBlocksPath+"/"+name, request, &vniBlock)
+	if err != nil {
+		return vniBlock, fmt.Errorf("could not update VNI block %s: %w", name, err)
+	}
+
+	return vniBlock, nil
+}
+
+// Delete deletes a VNI block by its name
+func (s *VNIBlocksService) Delete(ctx context.Context, name string) error {
+	err := s.client.do(ctx, "DELETE", vniBlocksPath+"/"+name, nil, nil)
+	if err != nil {
+		return fmt.Errorf("could not delete VNI block %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// EnforcementTask gets the state of a VNI block enforcement task. The link is
+// the EnforcementTaskServiceLink returned when the VNI block is created or updated
+func (s *VNIBlocksService) EnforcementTask(ctx context.Context, link string) (models.VniBlockEnforcementTaskServiceState, error) {
+	var state models.VniBlockEnforcementTaskServiceState
+	err := s.client.do(ctx, "GET", link, nil, &state)
+	if err != nil {
+		return state, fmt.Errorf("could not get VNI block enforcement task %s: %w", link, err)
+	}
+
+	return state, nil
+}
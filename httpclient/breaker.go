@@ -0,0 +1,156 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	//DefaultBreakerThreshold is the number of consecutive failures that open the circuit
+	DefaultBreakerThreshold = 5
+
+	//DefaultBreakerCooldown is how long the circuit stays open before a request is tried again
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// CircuitOpenError is returned without sending the request while the circuit is open
+type CircuitOpenError struct {
+	Failures int
+	Until    time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker is open after %d consecutive failures, requests are stopped until %s",
+		e.Failures, e.Until.Format(time.RFC3339))
+}
+
+// IsCircuitOpen checks if a request was not sent because the circuit is open
+func IsCircuitOpen(err error) bool {
+	var openErr *CircuitOpenError
+	return errors.As(err, &openErr)
+}
+
+// CircuitBreaker stops sending requests to a server that keeps failing. After
+// Threshold consecutive failures the circuit opens and requests fail without
+// being sent. Once Cooldown has passed a single request is let through: the
+// circuit closes when it succeeds and stays open for another Cooldown when it fails
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+	now      func() time.Time
+}
+
+// NewCircuitBreaker returns a closed circuit breaker
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Allow checks if a request can be sent. Every allowed request must be
+// followed by a call to Record or Cancel. A nil breaker allows every request
+func (b *CircuitBreaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openedAt.IsZero() {
+		return nil
+	}
+	until := b.openedAt.Add(b.Cooldown)
+	if b.probing || b.now().Before(until) {
+		return &CircuitOpenError{Failures: b.failures, Until: until}
+	}
+	b.probing = true
+
+	return nil
+}
+
+// Record records the outcome of a request that was allowed
+func (b *CircuitBreaker) Record(ok bool) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if ok {
+		if !b.openedAt.IsZero() {
+			log.Printf("circuit breaker closed after %d consecutive failures", b.failures)
+		}
+		b.failures = 0
+		b.openedAt = time.Time{}
+		setCircuitOpen(false)
+		return
+	}
+
+	b.failures++
+	if !b.openedAt.IsZero() || b.failures >= b.Threshold {
+		if b.openedAt.IsZero() {
+			log.Printf("circuit breaker opened after %d consecutive failures", b.failures)
+		}
+		b.openedAt = b.now()
+		setCircuitOpen(true)
+	}
+}
+
+// Cancel releases a request that was allowed but canceled before its outcome
+// was known, without counting it as a failure
+func (b *CircuitBreaker) Cancel() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// Open checks if the circuit is open, including while a request is tried again
+func (b *CircuitBreaker) Open() bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return !b.openedAt.IsZero()
+}
+
+// Check is a readiness check failing while the circuit is open
+func (b *CircuitBreaker) Check(_ *http.Request) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openedAt.IsZero() {
+		return nil
+	}
+
+	return &CircuitOpenError{Failures: b.failures, Until: b.openedAt.Add(b.Cooldown)}
+}
@@ -0,0 +1,90 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	server, requests := newFlakyServer(t, 3, http.StatusServiceUnavailable, nil)
+	client := NewClient(server.URL)
+	client.Breaker = NewCircuitBreaker(2, time.Minute)
+	now := time.Now()
+	client.Breaker.now = func() time.Time { return now }
+
+	// the circuit opens after the threshold of consecutive failures
+	for i := 0; i < 2; i++ {
+		_, err := client.SendRequest(ctx, "GET", "/fabric/vlans", nil)
+		if err == nil || IsCircuitOpen(err) {
+			t.Fatalf("expected the request to fail, got %v", err)
+		}
+	}
+	if !client.Breaker.Open() || client.Breaker.Check(nil) == nil || testutil.ToFloat64(circuitOpen) != 1 {
+		t.Fatal("expected the circuit to be open")
+	}
+
+	// requests are not sent while the circuit is open
+	_, err := client.SendRequest(ctx, "GET", "/fabric/vlans", nil)
+	if !IsCircuitOpen(err) || *requests != 2 {
+		t.Fatalf("expected the request not to be sent, got %v after %d requests", err, *requests)
+	}
+
+	// after the cooldown, a failed request keeps the circuit open
+	now = now.Add(time.Minute + time.Second)
+	_, err = client.SendRequest(ctx, "GET", "/fabric/vlans", nil)
+	if err == nil || IsCircuitOpen(err) || *requests != 3 || !client.Breaker.Open() {
+		t.Fatalf("expected the request to be tried again and fail, got %v after %d requests", err, *requests)
+	}
+	_, err = client.SendRequest(ctx, "GET", "/fabric/vlans", nil)
+	if !IsCircuitOpen(err) {
+		t.Fatalf("expected the circuit to stay open, got %v", err)
+	}
+
+	// and a successful one closes it
+	now = now.Add(time.Minute + time.Second)
+	_, err = client.SendRequest(ctx, "GET", "/fabric/vlans", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Breaker.Open() || client.Breaker.Check(nil) != nil || testutil.ToFloat64(circuitOpen) != 0 {
+		t.Error("expected the circuit to be closed")
+	}
+}
+
+func TestCircuitBreakerCanceled(t *testing.T) {
+	server, _ := newFlakyServer(t, 0, 0, nil)
+	client := NewClient(server.URL)
+	client.Breaker = NewCircuitBreaker(1, time.Minute)
+
+	// a canceled request is not a failure of the server
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := client.SendRequest(ctx, "GET", "/fabric/vlans", nil)
+	if err == nil {
+		t.Fatal("expected the canceled request to fail")
+	}
+	if client.Breaker.Open() {
+		t.Error("expected the circuit to stay closed")
+	}
+}
+
+func TestNilCircuitBreaker(t *testing.T) {
+	var breaker *CircuitBreaker
+	breaker.Record(false)
+	breaker.Cancel()
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("expected a nil circuit breaker to allow requests, got %v", err)
+	}
+	if breaker.Open() || breaker.Check(nil) != nil {
+		t.Error("expected a nil circuit breaker to be closed")
+	}
+}
@@ -0,0 +1,165 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package httpclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+// The kinds of failed requests, matched with errors.Is against the errors
+// returned by SendRequest
+var (
+	// ErrNotFound is a 404 response: the document does not exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrConflict is a 409 or 412 response: the document already exists or
+	// was changed since it was read
+	ErrConflict = errors.New("conflict")
+
+	// ErrUnauthorized is a 401 response, after the access token was renewed
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrForbidden is a 403 response
+	ErrForbidden = errors.New("forbidden")
+
+	// ErrRateLimited is a 429 response
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrServerError is a 5xx response
+	ErrServerError = errors.New("server error")
+
+	// ErrDecode is a response body that cannot be decoded
+	ErrDecode = errors.New("cannot decode response")
+)
+
+// maxMessageLength is the longest message taken from an error response body
+// that is not JSON, e.g. the HTML page of a proxy
+const maxMessageLength = 256
+
+// StatusError is returned when Fabric Manager answers with an error status code
+type StatusError struct {
+	StatusCode int
+
+	// Message is the message of the error response, or its body when it is not JSON
+	Message string
+
+	// ErrorCode is the Fabric Manager error code of the error response
+	ErrorCode int
+
+	// Body is the raw body of the error response
+	Body []byte
+}
+
+// newStatusError returns the error of a response with statusCode and body
+func newStatusError(statusCode int, body []byte) *StatusError {
+	statusErr := &StatusError{StatusCode: statusCode, Body: body}
+
+	var errorResponse models.ErrorResponse
+	if err := json.Unmarshal(body, &errorResponse); err == nil {
+		statusErr.Message = errorResponse.Message
+		statusErr.ErrorCode = errorResponse.ErrorCode
+	} else {
+		statusErr.Message = strings.TrimSpace(string(body))
+		if len(statusErr.Message) > maxMessageLength {
+			statusErr.Message = statusErr.Message[:maxMessageLength] + "..."
+		}
+	}
+	if statusErr.Message == "" {
+		statusErr.Message = http.StatusText(statusCode)
+	}
+
+	return statusErr
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("could not complete request, status %d: %s", e.StatusCode, e.Message)
+}
+
+// Is matches the kind of the failed request
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict || e.StatusCode == http.StatusPreconditionFailed
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServerError:
+		return e.StatusCode >= 500
+	}
+
+	return false
+}
+
+// DecodeError is returned when a response body cannot be decoded
+type DecodeError struct {
+	// Body is the raw body of the response
+	Body []byte
+
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches ErrDecode
+func (e *DecodeError) Is(target error) bool {
+	return target == ErrDecode
+}
+
+// TokenError is returned when no access token could be obtained for a request
+type TokenError struct {
+	Err error
+}
+
+func (e *TokenError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TokenError) Unwrap() error {
+	return e.Err
+}
+
+// IsTokenError checks if a request failed because no access token could be obtained
+func IsTokenError(err error) bool {
+	var tokenErr *TokenError
+	return errors.As(err, &tokenErr)
+}
+
+// IsNotFound checks if a request failed because the document does not exist
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsConflict checks if a request failed because the document already exists
+// or was changed since it was read
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
+
+// IgnoreNotFound returns nil when a request failed because the document does
+// not exist, e.g. when it was already deleted
+func IgnoreNotFound(err error) error {
+	if IsNotFound(err) {
+		return nil
+	}
+
+	return err
+}
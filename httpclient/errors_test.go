@@ -0,0 +1,84 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStatusErrors(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		kind       error
+		message    string
+		errorCode  int
+	}{
+		{name: "not found", statusCode: http.StatusNotFound, body: `{"message": "VLAN not found: 100", "errorCode": 3}`, kind: ErrNotFound, message: "VLAN not found: 100", errorCode: 3},
+		{name: "conflict", statusCode: http.StatusConflict, body: `{"message": "VLAN already exists: 100"}`, kind: ErrConflict, message: "VLAN already exists: 100"},
+		{name: "precondition failed", statusCode: http.StatusPreconditionFailed, body: `{}`, kind: ErrConflict, message: "Precondition Failed"},
+		{name: "unauthorized", statusCode: http.StatusUnauthorized, body: `{"message": "invalid token"}`, kind: ErrUnauthorized, message: "invalid token"},
+		{name: "forbidden", statusCode: http.StatusForbidden, body: "", kind: ErrForbidden, message: "Forbidden"},
+		{name: "rate limited", statusCode: http.StatusTooManyRequests, body: "slow down\n", kind: ErrRateLimited, message: "slow down"},
+		{name: "server error", statusCode: http.StatusBadGateway, body: "<html><body>Bad Gateway</body></html>", kind: ErrServerError, message: "<html><body>Bad Gateway</body></html>"},
+		{name: "long body", statusCode: http.StatusInternalServerError, body: strings.Repeat("x", 1000), kind: ErrServerError, message: strings.Repeat("x", maxMessageLength) + "..."},
+	}
+
+	kinds := []error{ErrNotFound, ErrConflict, ErrUnauthorized, ErrForbidden, ErrRateLimited, ErrServerError, ErrDecode}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := NewClient(server.URL).SendRequest(ctx, "GET", "/fabric/vlans/100", nil)
+			err = fmt.Errorf("cannot get VLAN: %w", err)
+
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("expected a status error, got %v", err)
+			}
+			if statusErr.StatusCode != tt.statusCode || statusErr.Message != tt.message || statusErr.ErrorCode != tt.errorCode ||
+				string(statusErr.Body) != tt.body {
+				t.Errorf("unexpected status error %+v", statusErr)
+			}
+			for _, kind := range kinds {
+				if errors.Is(err, kind) != (kind == tt.kind) {
+					t.Errorf("expected %q to match %v only, got a match with %v", err, tt.kind, kind)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorHelpers(t *testing.T) {
+	notFound := fmt.Errorf("cannot delete VLAN: %w", &StatusError{StatusCode: http.StatusNotFound})
+	conflict := &StatusError{StatusCode: http.StatusConflict}
+
+	if !IsNotFound(notFound) || IsNotFound(conflict) || !IsConflict(conflict) || IsConflict(notFound) {
+		t.Error("unexpected kind of status errors")
+	}
+	if err := IgnoreNotFound(notFound); err != nil {
+		t.Errorf("expected not found to be ignored, got %v", err)
+	}
+	if err := IgnoreNotFound(conflict); err != conflict {
+		t.Errorf("expected conflict not to be ignored, got %v", err)
+	}
+
+	decodeErr := fmt.Errorf("cannot get VLAN: %w", &DecodeError{Body: []byte("<html>"), Err: errors.New("invalid character")})
+	if !errors.Is(decodeErr, ErrDecode) || errors.Is(decodeErr, ErrServerError) {
+		t.Errorf("expected a decode error, got %v", decodeErr)
+	}
+}
@@ -8,48 +8,78 @@ package httpclient
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"time"
-
-	"github.hpe.com/hpe/sshot-net-operator/models"
 )
 
 // Client describe the BaseURL for Fabric Manager
 type Client struct {
 	BaseURL string
+
+	// Transport keeps the connections to the server open between requests
+	// and holds the TLS settings. When nil, a transport shared by the clients
+	// without one verifies the server with the system roots
+	Transport *Transport
+
+	// Timeout is the deadline for a single request
+	Timeout time.Duration
+
+	// TokenSource provides the bearer token sent with the requests. When the
+	// server answers 401 the token is invalidated and the request is retried once
+	TokenSource TokenSource
+
+	// Retries is how many times an idempotent request that failed with a
+	// transient error is sent again. Zero disables the retries
+	Retries int
+
+	// RetryBaseDelay is the delay before the first retry, doubled for each retry
+	RetryBaseDelay time.Duration
+
+	// RetryMaxDelay is the longest delay between two retries
+	RetryMaxDelay time.Duration
+
+	// Breaker stops sending requests while the server keeps failing. When
+	// nil, every request is sent
+	Breaker *CircuitBreaker
+}
+
+// TokenSource provides an access token
+type TokenSource interface {
+	// Token returns a valid access token
+	Token(ctx context.Context) (string, error)
+
+	// Invalidate drops the current access token
+	Invalidate()
 }
 
 const (
-	//ContextDeadline is the context deadline
-	ContextDeadline = 30 * time.Second
+	//DefaultTimeout is the default deadline for a single request
+	DefaultTimeout = 30 * time.Second
 )
 
 // NewClient returns a client
 func NewClient(baseURL string) *Client {
 	return &Client{
-		BaseURL: baseURL,
+		BaseURL:        baseURL,
+		Timeout:        DefaultTimeout,
+		RetryBaseDelay: DefaultRetryBaseDelay,
+		RetryMaxDelay:  DefaultRetryMaxDelay,
 	}
 }
 
-// SendRequest method implements logic to communicate with Fabric Manager's APIs
+// SendRequest method implements logic to communicate with Fabric Manager's APIs.
+// Idempotent requests that fail with a transient error are sent again, after
+// the delay asked by the server or a jittered exponential backoff
 func (c *Client) SendRequest(ctx context.Context, method string, path string, data interface{}) ([]byte, error) {
-	var err error
-	req := &http.Request{}
-	tlsConfig := &tls.Config{}
-
-	//create context with timeout
-	ctx, cancel := context.WithTimeout(ctx, ContextDeadline)
-	defer cancel()
-
-	if strings.Contains(path, "token") {
+	var body []byte
+	var contentType string
+	isTokenRequest := strings.Contains(path, "token")
+	if isTokenRequest {
 		formData, ok := data.(map[string]string)
 		if !ok {
 			return nil, fmt.Errorf("invalid data type for token request")
@@ -61,82 +91,145 @@ func (c *Client) SendRequest(ctx context.Context, method string, path string, da
 		formdata.Set("client_secret", formData["client_secret"])
 		formdata.Set("scope", formData["scope"])
 
-		req, err = http.NewRequestWithContext(ctx, method, c.BaseURL+path, strings.NewReader(formdata.Encode()))
-		if err != nil {
-			return nil, err
-		}
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-		req.Header.Set("Accept", "application/json")
+		body = []byte(formdata.Encode())
+		contentType = "application/x-www-form-urlencoded"
 	} else {
 		jsonData, err := json.Marshal(data)
 		if err != nil {
 			return nil, err
 		}
-		req, err = http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewBuffer(jsonData))
-		if err != nil {
-			return nil, err
-		}
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Accept", "application/json")
-		req.Header.Set("Authorization", "Bearer "+models.AccessToken)
+
+		body = jsonData
+		contentType = "application/json"
 	}
 
-	if models.SkipTLSVerify == "true" {
-		tlsConfig.InsecureSkipVerify = true
+	client, err := c.transport().Client()
+	if err != nil {
+		return nil, err
 	}
 
-	if models.SkipTLSVerify == "false" {
-		// Path to the CA certificate
-		caCertPath := models.CAPublicKey
+	authorize := !isTokenRequest && c.TokenSource != nil
+	reauthorized := false
+	for retries := 0; ; {
+		var accessToken string
+		if authorize {
+			accessToken, err = c.TokenSource.Token(ctx)
+			if err != nil {
+				return nil, &TokenError{Err: err}
+			}
+		}
+
+		err = c.Breaker.Allow()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.send(ctx, client, method, path, body, contentType, accessToken, isTokenRequest)
+		if ctx.Err() != nil {
+			//a canceled request says nothing about the server
+			c.Breaker.Cancel()
+		} else {
+			c.Breaker.Record(err == nil && resp.statusCode < 500 && !transient(resp.statusCode))
+		}
 
-		// Read the CA certificate
-		caCert, err := os.ReadFile(caCertPath)
+		//transient failures of idempotent requests are retried
+		if (err != nil || transient(resp.statusCode)) && ctx.Err() == nil && idempotent(method) && retries < c.Retries {
+			delay := c.backoff(retries)
+			retry := true
+			if err == nil {
+				if after, ok := retryAfter(resp.statusCode, resp.header, time.Now()); ok {
+					//fail fast when the server asks to wait longer than a retry may
+					delay = after
+					retry = after <= c.RetryMaxDelay
+				}
+			}
+			if retry && !isTokenRequest {
+				observeRetry(method, path)
+			}
+			if retry && sleep(ctx, delay) == nil {
+				retries++
+				continue
+			}
+		}
 		if err != nil {
-			fmt.Errorf("failed to read CA certificate: %v", err)
+			return nil, err
+		}
+
+		if resp.statusCode == http.StatusUnauthorized && authorize && !reauthorized {
+			// the token may have been revoked or expired early, get a new one and retry
+			c.TokenSource.Invalidate()
+			reauthorized = true
+			continue
 		}
 
-		// Create a new CA certificate pool and add the CA certificate to it
-		caCertPool := x509.NewCertPool()
-		if ok := caCertPool.AppendCertsFromPEM(caCert); !ok {
-			return nil, fmt.Errorf("failed to append CA certificate")
+		if !succeeded(resp.statusCode) {
+			return nil, newStatusError(resp.statusCode, resp.body)
 		}
 
-		// Create a new TLS configuration with the CA certificate pool
-		tlsConfig.RootCAs = caCertPool
+		return resp.body, nil
 	}
+}
+
+// response is the status code, headers and body of a response
+type response struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+}
 
-	transport := &http.Transport{
-		TLSClientConfig: tlsConfig,
+// send sends a single request, with its own deadline, and reads its response
+func (c *Client) send(ctx context.Context, client *http.Client, method string, path string, body []byte, contentType string,
+	accessToken string, isTokenRequest bool) (response, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
 	}
 
-	client := &http.Client{
-		Transport: transport,
+	//create context with timeout
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return response{}, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
 	}
 
+	start := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		c.observe(isTokenRequest, method, path, 0, start, err)
+		return response{}, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 && resp.StatusCode != 202 {
-		var errorResponse models.ErrorResponse
-		responseBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
+	responseBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	c.observe(isTokenRequest, method, path, resp.StatusCode, start, err)
+	if err != nil {
+		return response{}, err
+	}
 
-		err = json.Unmarshal(responseBody, &errorResponse)
-		if err != nil {
-			return nil, err
-		}
-		return nil, fmt.Errorf("could not complete request %+v", errorResponse.Message)
+	return response{statusCode: resp.StatusCode, header: resp.Header, body: responseBody}, nil
+}
+
+// observe records the metrics of a request that started at start. Requests
+// for an access token are only counted as token fetches
+func (c *Client) observe(isTokenRequest bool, method string, path string, statusCode int, start time.Time, err error) {
+	if isTokenRequest {
+		observeTokenFetch(err == nil && succeeded(statusCode))
+		return
 	}
+	observeRequest(method, path, statusCode, time.Since(start), err)
+}
 
-	responseBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+// transport returns the transport of the client
+func (c *Client) transport() *Transport {
+	if c.Transport == nil {
+		return defaultTransport
 	}
 
-	return responseBody, nil
+	return c.Transport
 }
@@ -0,0 +1,137 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package httpclient
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// metricsNamespace prefixes the names of the metrics of the operator
+	metricsNamespace = "sshot_net_operator"
+
+	// codeError is the code label of a request that got no response
+	codeError = "error"
+
+	// endpointOther is the endpoint label of a path outside of /fabric
+	endpointOther = "other"
+)
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "fabric_requests_total",
+		Help:      "Number of requests sent to Fabric Manager, by method, endpoint and status code.",
+	}, []string{"method", "endpoint", "code"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "fabric_request_duration_seconds",
+		Help:      "Latency of the requests sent to Fabric Manager, by method, endpoint and status code.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"method", "endpoint", "code"})
+
+	requestErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "fabric_request_errors_total",
+		Help:      "Number of requests to Fabric Manager that failed or got an error status code, by method, endpoint and status code.",
+	}, []string{"method", "endpoint", "code"})
+
+	requestRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "fabric_request_retries_total",
+		Help:      "Number of requests to Fabric Manager sent again after a transient failure, by method and endpoint.",
+	}, []string{"method", "endpoint"})
+
+	circuitOpen = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "fabric_circuit_open",
+		Help:      "Whether the circuit breaker stopped sending requests to Fabric Manager.",
+	})
+
+	tokenFetchesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "token_fetches_total",
+		Help:      "Number of access tokens requested from the token endpoint, by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(requestsTotal, requestDuration, requestErrorsTotal, requestRetriesTotal, circuitOpen, tokenFetchesTotal)
+}
+
+// observeRequest records a request to Fabric Manager that took duration and
+// was answered with statusCode, or got no response when err is set
+func observeRequest(method string, path string, statusCode int, duration time.Duration, err error) {
+	code := codeError
+	if err == nil {
+		code = strconv.Itoa(statusCode)
+	}
+	endpoint := Endpoint(path)
+
+	requestsTotal.WithLabelValues(method, endpoint, code).Inc()
+	requestDuration.WithLabelValues(method, endpoint, code).Observe(duration.Seconds())
+	if err != nil || !succeeded(statusCode) {
+		requestErrorsTotal.WithLabelValues(method, endpoint, code).Inc()
+	}
+}
+
+// observeRetry records a request to Fabric Manager that is sent again
+func observeRetry(method string, path string) {
+	requestRetriesTotal.WithLabelValues(method, Endpoint(path)).Inc()
+}
+
+// setCircuitOpen records whether the circuit breaker is open
+func setCircuitOpen(open bool) {
+	value := 0.0
+	if open {
+		value = 1
+	}
+	circuitOpen.Set(value)
+}
+
+// observeTokenFetch records a request for an access token that succeeded or failed
+func observeTokenFetch(ok bool) {
+	result := "success"
+	if !ok {
+		result = "failure"
+	}
+	tokenFetchesTotal.WithLabelValues(result).Inc()
+}
+
+// Endpoint returns the endpoint of a Fabric Manager path, with the name of
+// the document replaced by a placeholder to bound the number of label values,
+// e.g. "/fabric/vni/blocks/{name}" for "/fabric/vni/blocks/vcluster-blue-block"
+func Endpoint(path string) string {
+	if u, err := url.Parse(path); err == nil {
+		path = u.Path
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if segments[0] != "fabric" || len(segments) < 2 {
+		return endpointOther
+	}
+
+	//the VNI collections are nested under /fabric/vni
+	collection := 2
+	if segments[1] == "vni" {
+		collection = 3
+	}
+	if len(segments) <= collection {
+		return "/" + strings.Join(segments, "/")
+	}
+
+	return "/" + strings.Join(segments[:collection], "/") + "/{name}"
+}
+
+// succeeded checks if a status code is one Fabric Manager answers on success
+func succeeded(statusCode int) bool {
+	return statusCode == 200 || statusCode == 202
+}
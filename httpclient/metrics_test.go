@@ -0,0 +1,87 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestEndpoint(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{path: "/fabric/vni/blocks", expected: "/fabric/vni/blocks"},
+		{path: "/fabric/vni/blocks/vcluster-blue-block", expected: "/fabric/vni/blocks/{name}"},
+		{path: "/fabric/vni/partitions/vcluster-blue", expected: "/fabric/vni/partitions/{name}"},
+		{path: "/fabric/vni/enforcement-tasks/3", expected: "/fabric/vni/enforcement-tasks/{name}"},
+		{path: "/fabric/vlans/100", expected: "/fabric/vlans/{name}"},
+		{path: "/fabric/ports/x1000c2r3j100p0?expand=true", expected: "/fabric/ports/{name}"},
+		{path: "/fabric/switches", expected: "/fabric/switches"},
+		{path: "/apis/keycloak/token", expected: endpointOther},
+		{path: "", expected: endpointOther},
+	}
+
+	for _, tt := range tests {
+		if endpoint := Endpoint(tt.path); endpoint != tt.expected {
+			t.Errorf("expected endpoint %s for %q, got %s", tt.expected, tt.path, endpoint)
+		}
+	}
+}
+
+func TestRequestMetrics(t *testing.T) {
+	requestsTotal.Reset()
+	requestDuration.Reset()
+	requestErrorsTotal.Reset()
+	tokenFetchesTotal.Reset()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			http.Error(w, `{"message": "invalid client"}`, http.StatusUnauthorized)
+		case "/fabric/vlans/100":
+			http.Error(w, `{"message": "not found"}`, http.StatusNotFound)
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+	client := NewClient(server.URL)
+	ctx := context.Background()
+
+	_, err := client.SendRequest(ctx, "GET", "/fabric/vlans/200", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.SendRequest(ctx, "GET", "/fabric/vlans/100", nil)
+	if err == nil {
+		t.Fatal("expected the request to fail")
+	}
+	_, err = client.SendRequest(ctx, "POST", "/token", map[string]string{})
+	if err == nil {
+		t.Fatal("expected the token request to fail")
+	}
+
+	if count := testutil.ToFloat64(requestsTotal.WithLabelValues("GET", "/fabric/vlans/{name}", "200")); count != 1 {
+		t.Errorf("expected 1 successful request, got %v", count)
+	}
+	if count := testutil.ToFloat64(requestErrorsTotal.WithLabelValues("GET", "/fabric/vlans/{name}", "404")); count != 1 {
+		t.Errorf("expected 1 failed request, got %v", count)
+	}
+	if count := testutil.ToFloat64(requestErrorsTotal.WithLabelValues("GET", "/fabric/vlans/{name}", "200")); count != 0 {
+		t.Errorf("expected no error for the successful request, got %v", count)
+	}
+	if count := testutil.CollectAndCount(requestDuration); count != 2 {
+		t.Errorf("expected the latency of 2 endpoints and codes, got %d", count)
+	}
+	if count := testutil.ToFloat64(tokenFetchesTotal.WithLabelValues("failure")); count != 1 {
+		t.Errorf("expected 1 failed token fetch, got %v", count)
+	}
+}
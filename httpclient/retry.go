@@ -0,0 +1,101 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package httpclient
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	//DefaultRetries is how many times a failed idempotent request is sent again
+	DefaultRetries = 3
+
+	//DefaultRetryBaseDelay is the delay before the first retry, doubled for each retry
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+
+	//DefaultRetryMaxDelay is the longest delay between two retries
+	DefaultRetryMaxDelay = 10 * time.Second
+)
+
+// idempotent checks if a request with method can be sent again without
+// changing its outcome. PATCH is not retried: patching a VNI block starts a
+// new enforcement task
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// transient checks if a status code reports a failure that may not happen again
+func transient(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// backoff returns the jittered delay before the retry following attempt:
+// a random delay between half and all of the base delay doubled for each
+// attempt, up to the maximum delay
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.RetryBaseDelay
+	for i := 0; i < attempt && delay < c.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > c.RetryMaxDelay {
+		delay = c.RetryMaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// retryAfter returns the delay asked by the Retry-After header of a 429 or
+// 503 response, given either in seconds or as an HTTP date
+func retryAfter(statusCode int, header http.Header, now time.Time) (time.Duration, bool) {
+	if statusCode != http.StatusTooManyRequests && statusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// sleep waits for delay unless ctx is done first
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,135 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFlakyServer returns a server failing the first failures requests with
+// statusCode, or by closing the connection when statusCode is zero
+func newFlakyServer(t *testing.T, failures int32, statusCode int, header http.Header) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > failures {
+			w.Write([]byte(`{}`))
+			return
+		}
+		if statusCode == 0 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		http.Error(w, `{"message": "unavailable"}`, statusCode)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func newRetryingClient(baseURL string) *Client {
+	client := NewClient(baseURL)
+	client.Retries = 2
+	client.RetryBaseDelay = time.Millisecond
+	client.RetryMaxDelay = 4 * time.Millisecond
+
+	return client
+}
+
+func TestRetries(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name       string
+		method     string
+		failures   int32
+		statusCode int
+		header     http.Header
+		requests   int32
+		fails      bool
+	}{
+		{name: "transient status", method: "GET", failures: 2, statusCode: http.StatusBadGateway, requests: 3},
+		{name: "retry after", method: "DELETE", failures: 1, statusCode: http.StatusTooManyRequests, header: http.Header{"Retry-After": {"0"}}, requests: 2},
+		{name: "retry after too long", method: "GET", failures: 1, statusCode: http.StatusServiceUnavailable, header: http.Header{"Retry-After": {"60"}}, requests: 1, fails: true},
+		{name: "connection closed", method: "GET", failures: 1, requests: 2},
+		{name: "retries exhausted", method: "GET", failures: 3, statusCode: http.StatusServiceUnavailable, requests: 3, fails: true},
+		{name: "not transient", method: "GET", failures: 1, statusCode: http.StatusInternalServerError, requests: 1, fails: true},
+		{name: "not idempotent", method: "POST", failures: 1, statusCode: http.StatusServiceUnavailable, requests: 1, fails: true},
+		{name: "patch", method: "PATCH", failures: 1, statusCode: http.StatusGatewayTimeout, requests: 1, fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newFlakyServer(t, tt.failures, tt.statusCode, tt.header)
+			client := newRetryingClient(server.URL)
+
+			_, err := client.SendRequest(ctx, tt.method, "/fabric/vlans/100", nil)
+			if (err != nil) != tt.fails {
+				t.Errorf("expected failure %t, got %v", tt.fails, err)
+			}
+			if *requests != tt.requests {
+				t.Errorf("expected %d requests, got %d", tt.requests, *requests)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		statusCode int
+		value      string
+		expected   time.Duration
+		ok         bool
+	}{
+		{name: "seconds", statusCode: http.StatusServiceUnavailable, value: "7", expected: 7 * time.Second, ok: true},
+		{name: "date", statusCode: http.StatusTooManyRequests, value: now.Add(time.Minute).Format(http.TimeFormat), expected: time.Minute, ok: true},
+		{name: "past date", statusCode: http.StatusTooManyRequests, value: now.Add(-time.Minute).Format(http.TimeFormat), ok: true},
+		{name: "invalid", statusCode: http.StatusServiceUnavailable, value: "soon"},
+		{name: "missing", statusCode: http.StatusServiceUnavailable},
+		{name: "other status", statusCode: http.StatusBadGateway, value: "7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.value != "" {
+				header.Set("Retry-After", tt.value)
+			}
+			delay, ok := retryAfter(tt.statusCode, header, now)
+			if delay != tt.expected || ok != tt.ok {
+				t.Errorf("expected %s %t, got %s %t", tt.expected, tt.ok, delay, ok)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	client := NewClient("")
+	client.RetryBaseDelay = 100 * time.Millisecond
+	client.RetryMaxDelay = time.Second
+
+	for attempt, expected := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for i := 0; i < 10; i++ {
+			if delay := client.backoff(attempt); delay < expected/2 || delay > expected {
+				t.Errorf("expected the delay of attempt %d to be between %s and %s, got %s", attempt, expected/2, expected, delay)
+			}
+		}
+	}
+}
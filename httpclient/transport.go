@@ -0,0 +1,240 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	//DefaultMaxIdleConns is the number of idle connections kept open to all the servers
+	DefaultMaxIdleConns = 100
+
+	//DefaultMaxIdleConnsPerHost is the number of idle connections kept open to a single server
+	DefaultMaxIdleConnsPerHost = 10
+
+	//DefaultIdleConnTimeout is how long an idle connection is kept open
+	DefaultIdleConnTimeout = 90 * time.Second
+
+	//DefaultCAReloadInterval is the interval at which the CA certificate is read again
+	DefaultCAReloadInterval = time.Minute
+)
+
+// defaultTransport is used by the clients without a transport
+var defaultTransport = NewTransport("", false)
+
+// Transport is a long-lived HTTP transport keeping the connections to the
+// servers open between requests. It can be shared by several clients. The CA
+// certificate is read once and read again every CAReloadInterval by Start,
+// so a certificate rotated in the mounted ConfigMap is used without a restart
+type Transport struct {
+	// CACertPath is the path to the CA certificate used to verify the server.
+	// When empty, the system roots are used
+	CACertPath string
+
+	// SkipTLSVerify disables verification of the server certificate
+	SkipTLSVerify bool
+
+	// MaxIdleConns is the number of idle connections kept open to all the
+	// servers. Zero means no limit
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost is the number of idle connections kept open to a single server
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost limits the connections to a single server, whether
+	// active or idle. Zero means no limit
+	MaxConnsPerHost int
+
+	// IdleConnTimeout is how long an idle connection is kept open. Zero means no limit
+	IdleConnTimeout time.Duration
+
+	// CAReloadInterval is the interval at which the CA certificate is read
+	// again. Zero disables the reloads
+	CAReloadInterval time.Duration
+
+	mu        sync.RWMutex
+	transport *http.Transport
+	caCert    []byte
+	client    *http.Client
+}
+
+// NewTransport returns a transport verifying the servers with the CA
+// certificate at caCertPath. Connections are only opened on the first request
+func NewTransport(caCertPath string, skipTLSVerify bool) *Transport {
+	return &Transport{
+		CACertPath:          caCertPath,
+		SkipTLSVerify:       skipTLSVerify,
+		MaxIdleConns:        DefaultMaxIdleConns,
+		MaxIdleConnsPerHost: DefaultMaxIdleConnsPerHost,
+		IdleConnTimeout:     DefaultIdleConnTimeout,
+		CAReloadInterval:    DefaultCAReloadInterval,
+	}
+}
+
+// Client returns the HTTP client sending requests through the transport. It
+// fails when the CA certificate cannot be loaded
+func (t *Transport) Client() (*http.Client, error) {
+	t.mu.RLock()
+	client := t.client
+	t.mu.RUnlock()
+	if client != nil {
+		return client, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.client == nil {
+		caCert, err := t.readCACert()
+		if err != nil {
+			return nil, err
+		}
+		transport, err := t.newTransport(caCert)
+		if err != nil {
+			return nil, err
+		}
+		t.transport = transport
+		t.caCert = caCert
+		t.client = &http.Client{Transport: t}
+	}
+
+	return t.client, nil
+}
+
+// RoundTrip sends a request through the current connections
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.RLock()
+	transport := t.transport
+	t.mu.RUnlock()
+	if transport == nil {
+		return nil, fmt.Errorf("transport is not initialized")
+	}
+
+	return transport.RoundTrip(req)
+}
+
+// ReloadCA reads the CA certificate again and, when it changed, verifies the
+// new connections with it. Open connections are closed once idle. The
+// current certificate is kept when the new one cannot be loaded
+func (t *Transport) ReloadCA() (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.transport == nil {
+		//not used yet, the certificate is read on the first request
+		return false, nil
+	}
+
+	caCert, err := t.readCACert()
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(caCert, t.caCert) {
+		return false, nil
+	}
+
+	transport, err := t.newTransport(caCert)
+	if err != nil {
+		return false, err
+	}
+	t.transport.CloseIdleConnections()
+	t.transport = transport
+	t.caCert = caCert
+
+	return true, nil
+}
+
+// CloseIdleConnections closes the connections that are not in use
+func (t *Transport) CloseIdleConnections() {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.transport != nil {
+		t.transport.CloseIdleConnections()
+	}
+}
+
+// Start reloads the CA certificate every CAReloadInterval until ctx is done
+func (t *Transport) Start(ctx context.Context) error {
+	defer t.CloseIdleConnections()
+
+	if t.CACertPath == "" || t.SkipTLSVerify || t.CAReloadInterval <= 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(t.CAReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			reloaded, err := t.ReloadCA()
+			if err != nil {
+				log.Printf("cannot reload CA certificate: %+v", err)
+				continue
+			}
+			if reloaded {
+				log.Printf("reloaded CA certificate %s", t.CACertPath)
+			}
+		}
+	}
+}
+
+// NeedLeaderElection makes the CA certificate reload on every replica
+func (t *Transport) NeedLeaderElection() bool {
+	return false
+}
+
+// readCACert reads the CA certificate, or returns nil when the servers are
+// verified with the system roots or not verified
+func (t *Transport) readCACert() ([]byte, error) {
+	if t.SkipTLSVerify || t.CACertPath == "" {
+		return nil, nil
+	}
+
+	caCert, err := os.ReadFile(t.CACertPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read CA certificate %s: %w", t.CACertPath, err)
+	}
+
+	return caCert, nil
+}
+
+// newTransport returns an HTTP transport verifying the servers with caCert
+func (t *Transport) newTransport(caCert []byte) (*http.Transport, error) {
+	tlsConfig := &tls.Config{}
+	if t.SkipTLSVerify {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if caCert != nil {
+		caCertPool := x509.NewCertPool()
+		if ok := caCertPool.AppendCertsFromPEM(caCert); !ok {
+			return nil, fmt.Errorf("no certificate found in CA certificate %s", t.CACertPath)
+		}
+		tlsConfig.RootCAs = caCertPool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.MaxIdleConns = t.MaxIdleConns
+	transport.MaxIdleConnsPerHost = t.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = t.MaxConnsPerHost
+	transport.IdleConnTimeout = t.IdleConnTimeout
+
+	return transport, nil
+}
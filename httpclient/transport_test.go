@@ -0,0 +1,206 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package httpclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/fs"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTLSServer returns a TLS server counting the connections opened to it
+func newTLSServer(t *testing.T) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var connections int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server, &connections
+}
+
+// writeCACert writes the PEM encoded certificate to a file and returns its path
+func writeCACert(t *testing.T, path string, cert *x509.Certificate) string {
+	t.Helper()
+
+	if path == "" {
+		path = filepath.Join(t.TempDir(), "ca-public-key.pem")
+	}
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// newOtherCert returns a self-signed certificate that did not sign the test server certificate
+func newOtherCert(t *testing.T) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "other CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func TestTransportReusesConnections(t *testing.T) {
+	ctx := context.Background()
+	server, connections := newTLSServer(t)
+	transport := NewTransport(writeCACert(t, "", server.Certificate()), false)
+	fabric := NewClient(server.URL)
+	fabric.Transport = transport
+	token := NewClient(server.URL)
+	token.Transport = transport
+
+	for i := 0; i < 3; i++ {
+		_, err := fabric.SendRequest(ctx, "GET", "/fabric/vlans", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = token.SendRequest(ctx, "GET", "/fabric/switches", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if *connections != 1 {
+		t.Errorf("expected the requests to share 1 connection, got %d", *connections)
+	}
+}
+
+func TestTransportCACert(t *testing.T) {
+	ctx := context.Background()
+	server, _ := newTLSServer(t)
+
+	// a missing CA certificate fails the requests without sending them
+	transport := NewTransport(filepath.Join(t.TempDir(), "missing.pem"), false)
+	client := NewClient(server.URL)
+	client.Transport = transport
+	_, err := client.SendRequest(ctx, "GET", "/fabric/vlans", nil)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the missing CA certificate to be reported, got %v", err)
+	}
+
+	// a CA certificate without any certificate is reported as well
+	path := filepath.Join(t.TempDir(), "ca-public-key.pem")
+	err = os.WriteFile(path, []byte("not a certificate"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Transport = NewTransport(path, false)
+	_, err = client.SendRequest(ctx, "GET", "/fabric/vlans", nil)
+	if err == nil {
+		t.Error("expected the invalid CA certificate to be reported")
+	}
+
+	// the server is not trusted until it is verified with the right CA certificate
+	writeCACert(t, path, newOtherCert(t))
+	client.Transport = NewTransport(path, false)
+	_, err = client.SendRequest(ctx, "GET", "/fabric/vlans", nil)
+	if err == nil {
+		t.Fatal("expected the server certificate to be rejected")
+	}
+	reloaded, err := client.Transport.ReloadCA()
+	if reloaded || err != nil {
+		t.Errorf("expected the unchanged CA certificate not to be reloaded, got %t %v", reloaded, err)
+	}
+
+	// skipping the verification does not need the CA certificate
+	client.Transport = NewTransport(path, true)
+	_, err = client.SendRequest(ctx, "GET", "/fabric/vlans", nil)
+	if err != nil {
+		t.Errorf("expected the server certificate not to be verified, got %v", err)
+	}
+}
+
+func TestTransportReloadCA(t *testing.T) {
+	ctx := context.Background()
+	server, _ := newTLSServer(t)
+	path := writeCACert(t, "", newOtherCert(t))
+	client := NewClient(server.URL)
+	client.Transport = NewTransport(path, false)
+	client.Transport.CAReloadInterval = 10 * time.Millisecond
+
+	_, err := client.SendRequest(ctx, "GET", "/fabric/vlans", nil)
+	if err == nil {
+		t.Fatal("expected the server certificate to be rejected")
+	}
+
+	// a CA certificate that cannot be read keeps the current one
+	err = os.Remove(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Transport.ReloadCA()
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the missing CA certificate to be reported, got %v", err)
+	}
+
+	// the rotated CA certificate is used without recreating the client
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() {
+		done <- client.Transport.Start(ctx)
+	}()
+	writeCACert(t, path, server.Certificate())
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err = client.SendRequest(ctx, "GET", "/fabric/vlans", nil)
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Errorf("expected the reloaded CA certificate to verify the server, got %v", err)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,717 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+// Package config loads the operator configuration from a config file,
+// environment variables and command line flags
+package config
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	slingshotv1alpha1 "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+	"github.hpe.com/hpe/sshot-net-operator/fm"
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+	"github.hpe.com/hpe/sshot-net-operator/internal/ipam"
+)
+
+const (
+	//DefaultFabricManagerURL is the base URL for Fabric Manager
+	DefaultFabricManagerURL = "https://api-gw-service-nmn.local/apis/fabric-manager"
+
+	//DefaultCACertPath is the path to the CA certificate
+	DefaultCACertPath = "/var/run/configmap/ca-public-key.pem"
+
+	//DefaultRequestTimeout is the deadline for a single Fabric Manager request
+	DefaultRequestTimeout = 30 * time.Second
+
+	//DefaultReconciliationTime is the interval at which tenants are reconciled again
+	DefaultReconciliationTime = 60 * time.Second
+
+	//DefaultEnforcementTimeout is how long a VNI block enforcement task may run before it is retried
+	DefaultEnforcementTimeout = 10 * time.Minute
+
+	//DefaultEnforcementRetries is how many times a failed VNI block enforcement is retried
+	DefaultEnforcementRetries = 3
+
+	//DefaultGCInterval is the interval at which orphaned fabric resources are collected
+	DefaultGCInterval = 10 * time.Minute
+
+	//DefaultGCGracePeriod is how long a fabric resource must be orphaned before it is deleted
+	DefaultGCGracePeriod = time.Hour
+
+	//DefaultInventoryNamespace is the namespace of the inventory ConfigMap
+	DefaultInventoryNamespace = "sshot-net-operator"
+
+	//DefaultInventoryName is the name of the inventory ConfigMap
+	DefaultInventoryName = "sshot-net-operator-inventory"
+
+	//DefaultVNIBlockName is the VNI block name of a SlingshotTenant that does not set one
+	DefaultVNIBlockName = "vniblock"
+
+	//DefaultTenantVersion is the TAPMS Tenant version of a SlingshotTenant that does not set one
+	DefaultTenantVersion = "v1alpha2"
+
+	//DefaultVNICount is the number of VNIs of a SlingshotTenant that sets neither a count nor ranges
+	DefaultVNICount = 1000
+
+	//DefaultVNIAllocationsName is the name of the VNI allocation ConfigMap
+	DefaultVNIAllocationsName = "sshot-net-operator-vni-allocations"
+
+	//DefaultVLANPool is the range of VLAN IDs allocated to the tenants
+	DefaultVLANPool = "1-4094"
+
+	//DefaultVLANAllocationsName is the name of the VLAN allocation ConfigMap
+	DefaultVLANAllocationsName = "sshot-net-operator-vlan-allocations"
+)
+
+// Config is the operator configuration. Values are applied in the order
+// defaults, config file, environment variables and command line flags, so a
+// flag always wins over the same setting in the environment or config file.
+type Config struct {
+	// FabricManagerURL is the base URL of the Fabric Manager API
+	FabricManagerURL string `json:"fabricManagerURL,omitempty"`
+
+	// CACertPath is the path to the CA certificate of the API gateway.
+	// When empty, the system roots are used
+	CACertPath string `json:"caCertPath,omitempty"`
+
+	// SkipTLSVerify disables verification of the API gateway certificate
+	SkipTLSVerify bool `json:"skipTLSVerify,omitempty"`
+
+	// CAReloadInterval is the interval at which the CA certificate is read
+	// again, so a rotated certificate is used without a restart. Zero
+	// disables the reloads
+	CAReloadInterval metav1.Duration `json:"caReloadInterval"`
+
+	// MaxIdleConns is the number of idle connections kept open to the API
+	// gateway. Zero means no limit
+	MaxIdleConns int `json:"maxIdleConns"`
+
+	// MaxIdleConnsPerHost is the number of idle connections kept open to a single host
+	MaxIdleConnsPerHost int `json:"maxIdleConnsPerHost,omitempty"`
+
+	// MaxConnsPerHost limits the connections to a single host, whether
+	// active or idle. Zero means no limit
+	MaxConnsPerHost int `json:"maxConnsPerHost"`
+
+	// IdleConnTimeout is how long an idle connection is kept open. Zero means no limit
+	IdleConnTimeout metav1.Duration `json:"idleConnTimeout"`
+
+	// RequestTimeout is the deadline for a single Fabric Manager request
+	RequestTimeout metav1.Duration `json:"requestTimeout,omitempty"`
+
+	// RequestRetries is how many times an idempotent Fabric Manager request
+	// that failed with a transient error is sent again. Zero disables the retries
+	RequestRetries int `json:"requestRetries"`
+
+	// RetryBaseDelay is the delay before the first retry, doubled for each retry
+	RetryBaseDelay metav1.Duration `json:"retryBaseDelay,omitempty"`
+
+	// RetryMaxDelay is the longest delay between two retries
+	RetryMaxDelay metav1.Duration `json:"retryMaxDelay,omitempty"`
+
+	// CircuitBreakerThreshold is the number of consecutive failed Fabric
+	// Manager requests that stop the requests. Zero disables the circuit breaker
+	CircuitBreakerThreshold int `json:"circuitBreakerThreshold"`
+
+	// CircuitBreakerCooldown is how long the requests are stopped before one is tried again
+	CircuitBreakerCooldown metav1.Duration `json:"circuitBreakerCooldown,omitempty"`
+
+	// ReconciliationTime is the interval at which tenants are reconciled again
+	ReconciliationTime metav1.Duration `json:"reconciliationTime,omitempty"`
+
+	// EnforcementTimeout is how long a VNI block enforcement task may run
+	// before it is considered hung and retried
+	EnforcementTimeout metav1.Duration `json:"enforcementTimeout,omitempty"`
+
+	// EnforcementRetries is how many times a VNI block enforcement that failed
+	// or timed out is retried. Zero disables the retries
+	EnforcementRetries int `json:"enforcementRetries"`
+
+	// ClientID is the client ID used to request an access token
+	ClientID string `json:"clientID,omitempty"`
+
+	// ClientSecretNamespace is the namespace of the client secret
+	ClientSecretNamespace string `json:"clientSecretNamespace,omitempty"`
+
+	// ClientSecretName is the name of the secret holding the client secret and token endpoint
+	ClientSecretName string `json:"clientSecretName,omitempty"`
+
+	// GCInterval is the interval at which orphaned fabric resources are
+	// collected. Zero disables the garbage collector
+	GCInterval metav1.Duration `json:"gcInterval,omitempty"`
+
+	// GCGracePeriod is how long a fabric resource must be orphaned before it is deleted
+	GCGracePeriod metav1.Duration `json:"gcGracePeriod,omitempty"`
+
+	// GCDryRun only reports the orphaned fabric resources instead of deleting them
+	GCDryRun bool `json:"gcDryRun"`
+
+	// InventoryNamespace is the namespace of the ConfigMap recording the
+	// Fabric Manager documents owned by the operator
+	InventoryNamespace string `json:"inventoryNamespace,omitempty"`
+
+	// InventoryName is the name of the ConfigMap recording the Fabric Manager
+	// documents owned by the operator
+	InventoryName string `json:"inventoryName,omitempty"`
+
+	// CrawlWorkers is the number of switch and port documents fetched in
+	// parallel when the fabric topology is crawled
+	CrawlWorkers int `json:"crawlWorkers,omitempty"`
+
+	// CrawlRateLimit is the number of requests per second sent to Fabric
+	// Manager when the fabric topology is crawled. Zero disables the limit
+	CrawlRateLimit float64 `json:"crawlRateLimit"`
+
+	// EnableWebhooks serves the SlingshotTenant admission webhooks. The
+	// webhook server certificate must be mounted when enabled
+	EnableWebhooks bool `json:"enableWebhooks"`
+
+	// DefaultVNIBlockName is set by the webhook on SlingshotTenants without a VNI block name
+	DefaultVNIBlockName string `json:"defaultVNIBlockName,omitempty"`
+
+	// DefaultTenantVersion is set by the webhook on SlingshotTenants without a tenant version
+	DefaultTenantVersion string `json:"defaultTenantVersion,omitempty"`
+
+	// DefaultVNICount is set by the webhook on SlingshotTenants that set
+	// neither a VNI count nor VNI ranges
+	DefaultVNICount int `json:"defaultVNICount,omitempty"`
+
+	// VNIPool is the list of VNI ranges the operator allocates the VNIs of
+	// the SlingshotTenants that only set a VNI count from. When empty, Fabric
+	// Manager allocates them
+	VNIPool []string `json:"vniPool,omitempty"`
+
+	// VNIAllocationsName is the name of the ConfigMap recording the VNIs of
+	// each tenant, in the inventory namespace
+	VNIAllocationsName string `json:"vniAllocationsName,omitempty"`
+
+	// VLANPool is the list of VLAN ID ranges the VLAN IDs of the tenants are
+	// allocated from, e.g. "1-4094"
+	VLANPool []string `json:"vlanPool,omitempty"`
+
+	// VLANExclude is the list of VLAN IDs and VLAN ID ranges of the pool that
+	// are never allocated
+	VLANExclude []string `json:"vlanExclude,omitempty"`
+
+	// VLANAllocationsName is the name of the ConfigMap recording the VLAN ID
+	// of each tenant, in the inventory namespace
+	VLANAllocationsName string `json:"vlanAllocationsName,omitempty"`
+}
+
+// Default returns the default configuration
+func Default() Config {
+	return Config{
+		FabricManagerURL:        DefaultFabricManagerURL,
+		CACertPath:              DefaultCACertPath,
+		CAReloadInterval:        metav1.Duration{Duration: httpclient.DefaultCAReloadInterval},
+		MaxIdleConns:            httpclient.DefaultMaxIdleConns,
+		MaxIdleConnsPerHost:     httpclient.DefaultMaxIdleConnsPerHost,
+		IdleConnTimeout:         metav1.Duration{Duration: httpclient.DefaultIdleConnTimeout},
+		RequestTimeout:          metav1.Duration{Duration: DefaultRequestTimeout},
+		RequestRetries:          httpclient.DefaultRetries,
+		RetryBaseDelay:          metav1.Duration{Duration: httpclient.DefaultRetryBaseDelay},
+		RetryMaxDelay:           metav1.Duration{Duration: httpclient.DefaultRetryMaxDelay},
+		CircuitBreakerThreshold: httpclient.DefaultBreakerThreshold,
+		CircuitBreakerCooldown:  metav1.Duration{Duration: httpclient.DefaultBreakerCooldown},
+		ReconciliationTime:      metav1.Duration{Duration: DefaultReconciliationTime},
+		EnforcementTimeout:      metav1.Duration{Duration: DefaultEnforcementTimeout},
+		EnforcementRetries:      DefaultEnforcementRetries,
+		GCInterval:              metav1.Duration{Duration: DefaultGCInterval},
+		GCGracePeriod:           metav1.Duration{Duration: DefaultGCGracePeriod},
+		GCDryRun:                true,
+		InventoryNamespace:      DefaultInventoryNamespace,
+		InventoryName:           DefaultInventoryName,
+		CrawlWorkers:            fm.DefaultCrawlWorkers,
+		CrawlRateLimit:          fm.DefaultCrawlRateLimit,
+		DefaultVNIBlockName:     DefaultVNIBlockName,
+		DefaultTenantVersion:    DefaultTenantVersion,
+		DefaultVNICount:         DefaultVNICount,
+		VNIAllocationsName:      DefaultVNIAllocationsName,
+		VLANPool:                []string{DefaultVLANPool},
+		VLANAllocationsName:     DefaultVLANAllocationsName,
+	}
+}
+
+// LoadFile overrides the configuration with the settings present in a YAML
+// or JSON file, typically mounted from a ConfigMap
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read config file: %w", err)
+	}
+
+	err = yaml.UnmarshalStrict(data, c)
+	if err != nil {
+		return fmt.Errorf("cannot parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// LoadEnv overrides the configuration with the environment variables that are set
+func (c *Config) LoadEnv(lookupEnv func(string) (string, bool)) error {
+	if v, ok := lookupEnv("FABRIC_MANAGER_URL"); ok {
+		c.FabricManagerURL = v
+	}
+	if v, ok := lookupEnv("CA_CERT_PATH"); ok {
+		c.CACertPath = v
+	}
+	if v, ok := lookupEnv("CLIENT_ID"); ok {
+		c.ClientID = v
+	}
+	if v, ok := lookupEnv("NAMESPACE"); ok {
+		c.ClientSecretNamespace = v
+	}
+	if v, ok := lookupEnv("SECRET_NAME"); ok {
+		c.ClientSecretName = v
+	}
+	if v, ok := lookupEnv("INVENTORY_NAMESPACE"); ok && v != "" {
+		c.InventoryNamespace = v
+	}
+	if v, ok := lookupEnv("INVENTORY_NAME"); ok && v != "" {
+		c.InventoryName = v
+	}
+
+	if v, ok := lookupEnv("DEFAULT_VNI_BLOCK_NAME"); ok && v != "" {
+		c.DefaultVNIBlockName = v
+	}
+	if v, ok := lookupEnv("DEFAULT_TENANT_VERSION"); ok && v != "" {
+		c.DefaultTenantVersion = v
+	}
+	if v, ok := lookupEnv("VNI_POOL"); ok {
+		c.VNIPool = splitList(v)
+	}
+	if v, ok := lookupEnv("VNI_ALLOCATIONS_NAME"); ok && v != "" {
+		c.VNIAllocationsName = v
+	}
+	if v, ok := lookupEnv("VLAN_POOL"); ok && v != "" {
+		c.VLANPool = splitList(v)
+	}
+	if v, ok := lookupEnv("VLAN_EXCLUDE"); ok {
+		c.VLANExclude = splitList(v)
+	}
+	if v, ok := lookupEnv("VLAN_ALLOCATIONS_NAME"); ok && v != "" {
+		c.VLANAllocationsName = v
+	}
+
+	if v, ok := lookupEnv("CRAWL_WORKERS"); ok && v != "" {
+		workers, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("CRAWL_WORKERS is invalid: %s", v)
+		}
+		c.CrawlWorkers = workers
+	}
+	if v, ok := lookupEnv("CRAWL_RATE_LIMIT"); ok && v != "" {
+		rateLimit, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("CRAWL_RATE_LIMIT is invalid: %s", v)
+		}
+		c.CrawlRateLimit = rateLimit
+	}
+	if v, ok := lookupEnv("ENFORCEMENT_RETRIES"); ok && v != "" {
+		retries, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("ENFORCEMENT_RETRIES is invalid: %s", v)
+		}
+		c.EnforcementRetries = retries
+	}
+	if v, ok := lookupEnv("REQUEST_RETRIES"); ok && v != "" {
+		retries, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("REQUEST_RETRIES is invalid: %s", v)
+		}
+		c.RequestRetries = retries
+	}
+	if v, ok := lookupEnv("CIRCUIT_BREAKER_THRESHOLD"); ok && v != "" {
+		threshold, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("CIRCUIT_BREAKER_THRESHOLD is invalid: %s", v)
+		}
+		c.CircuitBreakerThreshold = threshold
+	}
+	for name, n := range map[string]*int{
+		"MAX_IDLE_CONNS":          &c.MaxIdleConns,
+		"MAX_IDLE_CONNS_PER_HOST": &c.MaxIdleConnsPerHost,
+		"MAX_CONNS_PER_HOST":      &c.MaxConnsPerHost,
+	} {
+		if v, ok := lookupEnv(name); ok && v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s is invalid: %s", name, v)
+			}
+			*n = parsed
+		}
+	}
+	if v, ok := lookupEnv("DEFAULT_VNI_COUNT"); ok && v != "" {
+		vniCount, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("DEFAULT_VNI_COUNT is invalid: %s", v)
+		}
+		c.DefaultVNICount = vniCount
+	}
+
+	for name, b := range map[string]*bool{
+		"SKIP_TLS_VERIFY": &c.SkipTLSVerify,
+		"GC_DRY_RUN":      &c.GCDryRun,
+		"ENABLE_WEBHOOKS": &c.EnableWebhooks,
+	} {
+		if v, ok := lookupEnv(name); ok && v != "" {
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s is invalid: %s", name, v)
+			}
+			*b = parsed
+		}
+	}
+
+	for name, d := range map[string]*time.Duration{
+		"CA_RELOAD_INTERVAL":       &c.CAReloadInterval.Duration,
+		"IDLE_CONN_TIMEOUT":        &c.IdleConnTimeout.Duration,
+		"REQUEST_TIMEOUT":          &c.RequestTimeout.Duration,
+		"RETRY_BASE_DELAY":         &c.RetryBaseDelay.Duration,
+		"RETRY_MAX_DELAY":          &c.RetryMaxDelay.Duration,
+		"CIRCUIT_BREAKER_COOLDOWN": &c.CircuitBreakerCooldown.Duration,
+		"RECONCILIATION_TIME":      &c.ReconciliationTime.Duration,
+		"ENFORCEMENT_TIMEOUT":      &c.EnforcementTimeout.Duration,
+		"GC_INTERVAL":              &c.GCInterval.Duration,
+		"GC_GRACE_PERIOD":          &c.GCGracePeriod.Duration,
+	} {
+		if v, ok := lookupEnv(name); ok && v != "" {
+			parsed, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s is invalid: %s", name, v)
+			}
+			*d = parsed
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that the configuration can be used
+func (c *Config) Validate() error {
+	u, err := url.Parse(c.FabricManagerURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("fabric manager URL is invalid: %q", c.FabricManagerURL)
+	}
+	if c.CAReloadInterval.Duration < 0 {
+		return fmt.Errorf("CA certificate reload interval must not be negative: %s", c.CAReloadInterval.Duration)
+	}
+	if c.MaxIdleConns < 0 || c.MaxConnsPerHost < 0 {
+		return fmt.Errorf("connection limits must not be negative: %d, %d", c.MaxIdleConns, c.MaxConnsPerHost)
+	}
+	if c.MaxIdleConnsPerHost <= 0 {
+		return fmt.Errorf("idle connections per host must be positive: %d", c.MaxIdleConnsPerHost)
+	}
+	if c.IdleConnTimeout.Duration < 0 {
+		return fmt.Errorf("idle connection timeout must not be negative: %s", c.IdleConnTimeout.Duration)
+	}
+	if c.RequestTimeout.Duration <= 0 {
+		return fmt.Errorf("request timeout must be positive: %s", c.RequestTimeout.Duration)
+	}
+	if c.RequestRetries < 0 {
+		return fmt.Errorf("request retries must not be negative: %d", c.RequestRetries)
+	}
+	if c.RetryBaseDelay.Duration <= 0 || c.RetryMaxDelay.Duration < c.RetryBaseDelay.Duration {
+		return fmt.Errorf("retry delays must be positive, with the maximum delay not shorter than the base delay: %s, %s",
+			c.RetryBaseDelay.Duration, c.RetryMaxDelay.Duration)
+	}
+	if c.CircuitBreakerThreshold < 0 {
+		return fmt.Errorf("circuit breaker threshold must not be negative: %d", c.CircuitBreakerThreshold)
+	}
+	if c.CircuitBreakerThreshold > 0 && c.CircuitBreakerCooldown.Duration <= 0 {
+		return fmt.Errorf("circuit breaker cooldown must be positive: %s", c.CircuitBreakerCooldown.Duration)
+	}
+	if c.ReconciliationTime.Duration <= 0 {
+		return fmt.Errorf("reconciliation time must be positive: %s", c.ReconciliationTime.Duration)
+	}
+	if c.EnforcementTimeout.Duration <= 0 {
+		return fmt.Errorf("enforcement timeout must be positive: %s", c.EnforcementTimeout.Duration)
+	}
+	if c.EnforcementRetries < 0 {
+		return fmt.Errorf("enforcement retries must not be negative: %d", c.EnforcementRetries)
+	}
+	if c.GCInterval.Duration < 0 {
+		return fmt.Errorf("garbage collection interval must not be negative: %s", c.GCInterval.Duration)
+	}
+	if c.GCGracePeriod.Duration < 0 {
+		return fmt.Errorf("garbage collection grace period must not be negative: %s", c.GCGracePeriod.Duration)
+	}
+	if c.CrawlWorkers <= 0 {
+		return fmt.Errorf("crawl workers must be positive: %d", c.CrawlWorkers)
+	}
+	if c.CrawlRateLimit < 0 {
+		return fmt.Errorf("crawl rate limit must not be negative: %g", c.CrawlRateLimit)
+	}
+	if c.InventoryNamespace == "" || c.InventoryName == "" {
+		return fmt.Errorf("inventory namespace and name must be set: %q/%q", c.InventoryNamespace, c.InventoryName)
+	}
+	if c.DefaultVNIBlockName == "" || c.DefaultTenantVersion == "" {
+		return fmt.Errorf("default VNI block name and tenant version must be set: %q, %q", c.DefaultVNIBlockName, c.DefaultTenantVersion)
+	}
+	if c.DefaultVNICount <= 0 || c.DefaultVNICount > slingshotv1alpha1.MaxVNICount {
+		return fmt.Errorf("default VNI count must be between 1 and %d: %d", slingshotv1alpha1.MaxVNICount, c.DefaultVNICount)
+	}
+	if _, err := ipam.ParsePool(c.VNIPool); err != nil {
+		return fmt.Errorf("VNI pool is invalid: %w", err)
+	}
+	if len(c.VNIPool) > 0 && c.VNIAllocationsName == "" {
+		return fmt.Errorf("VNI allocations name must be set when the VNI pool is set")
+	}
+	if _, err := ipam.VLANPool(c.VLANPool, c.VLANExclude); err != nil {
+		return fmt.Errorf("VLAN pool is invalid: %w", err)
+	}
+	if c.VLANAllocationsName == "" {
+		return fmt.Errorf("VLAN allocations name must be set")
+	}
+
+	return nil
+}
+
+// Flags holds the command line flags that override the configuration
+type Flags struct {
+	fs          *flag.FlagSet
+	configFile  string
+	vniPool     string
+	vlanPool    string
+	vlanExclude string
+	values      Config
+}
+
+// BindFlags registers the configuration flags on fs
+func BindFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{fs: fs}
+	d := Default()
+
+	fs.StringVar(&f.configFile, "config", "",
+		"Path to the operator config file. Defaults to $CONFIG_FILE when set.")
+	fs.StringVar(&f.values.FabricManagerURL, "fabric-manager-url", d.FabricManagerURL,
+		"The base URL of the Fabric Manager API. Overrides $FABRIC_MANAGER_URL.")
+	fs.StringVar(&f.values.CACertPath, "ca-cert-path", d.CACertPath,
+		"Path to the CA certificate of the API gateway. Empty uses the system roots. Overrides $CA_CERT_PATH.")
+	fs.BoolVar(&f.values.SkipTLSVerify, "skip-tls-verify", d.SkipTLSVerify,
+		"Skip verification of the API gateway certificate. Overrides $SKIP_TLS_VERIFY.")
+	fs.DurationVar(&f.values.CAReloadInterval.Duration, "ca-reload-interval", d.CAReloadInterval.Duration,
+		"The interval at which the CA certificate is read again. Zero disables the reloads. Overrides $CA_RELOAD_INTERVAL.")
+	fs.IntVar(&f.values.MaxIdleConns, "max-idle-conns", d.MaxIdleConns,
+		"The number of idle connections kept open to the API gateway. Zero means no limit. Overrides $MAX_IDLE_CONNS.")
+	fs.IntVar(&f.values.MaxIdleConnsPerHost, "max-idle-conns-per-host", d.MaxIdleConnsPerHost,
+		"The number of idle connections kept open to a single host. Overrides $MAX_IDLE_CONNS_PER_HOST.")
+	fs.IntVar(&f.values.MaxConnsPerHost, "max-conns-per-host", d.MaxConnsPerHost,
+		"The limit of active and idle connections to a single host. Zero means no limit. Overrides $MAX_CONNS_PER_HOST.")
+	fs.DurationVar(&f.values.IdleConnTimeout.Duration, "idle-conn-timeout", d.IdleConnTimeout.Duration,
+		"How long an idle connection is kept open. Zero means no limit. Overrides $IDLE_CONN_TIMEOUT.")
+	fs.DurationVar(&f.values.RequestTimeout.Duration, "request-timeout", d.RequestTimeout.Duration,
+		"The deadline for a single Fabric Manager request. Overrides $REQUEST_TIMEOUT.")
+	fs.IntVar(&f.values.RequestRetries, "request-retries", d.RequestRetries,
+		"How many times an idempotent Fabric Manager request that failed with a transient error is sent again. Overrides $REQUEST_RETRIES.")
+	fs.DurationVar(&f.values.RetryBaseDelay.Duration, "retry-base-delay", d.RetryBaseDelay.Duration,
+		"The delay before the first retry of a Fabric Manager request, doubled for each retry. Overrides $RETRY_BASE_DELAY.")
+	fs.DurationVar(&f.values.RetryMaxDelay.Duration, "retry-max-delay", d.RetryMaxDelay.Duration,
+		"The longest delay between two retries of a Fabric Manager request. Overrides $RETRY_MAX_DELAY.")
+	fs.IntVar(&f.values.CircuitBreakerThreshold, "circuit-breaker-threshold", d.CircuitBreakerThreshold,
+		"The number of consecutive failed Fabric Manager requests that stop the requests. Zero disables the circuit breaker. Overrides $CIRCUIT_BREAKER_THRESHOLD.")
+	fs.DurationVar(&f.values.CircuitBreakerCooldown.Duration, "circuit-breaker-cooldown", d.CircuitBreakerCooldown.Duration,
+		"How long the Fabric Manager requests are stopped before one is tried again. Overrides $CIRCUIT_BREAKER_COOLDOWN.")
+	fs.DurationVar(&f.values.ReconciliationTime.Duration, "reconciliation-time", d.ReconciliationTime.Duration,
+		"The interval at which tenants are reconciled again. Overrides $RECONCILIATION_TIME.")
+	fs.DurationVar(&f.values.EnforcementTimeout.Duration, "enforcement-timeout", d.EnforcementTimeout.Duration,
+		"How long a VNI block enforcement task may run before it is retried. Overrides $ENFORCEMENT_TIMEOUT.")
+	fs.IntVar(&f.values.EnforcementRetries, "enforcement-retries", d.EnforcementRetries,
+		"How many times a failed or timed out VNI block enforcement is retried. Overrides $ENFORCEMENT_RETRIES.")
+	fs.DurationVar(&f.values.GCInterval.Duration, "gc-interval", d.GCInterval.Duration,
+		"The interval at which orphaned fabric resources are collected. Zero disables the collector. Overrides $GC_INTERVAL.")
+	fs.DurationVar(&f.values.GCGracePeriod.Duration, "gc-grace-period", d.GCGracePeriod.Duration,
+		"How long a fabric resource must be orphaned before it is deleted. Overrides $GC_GRACE_PERIOD.")
+	fs.BoolVar(&f.values.GCDryRun, "gc-dry-run", d.GCDryRun,
+		"Only report orphaned fabric resources instead of deleting them. Overrides $GC_DRY_RUN.")
+	fs.StringVar(&f.values.InventoryNamespace, "inventory-namespace", d.InventoryNamespace,
+		"The namespace of the ConfigMap recording the fabric resources owned by the operator. Overrides $INVENTORY_NAMESPACE.")
+	fs.StringVar(&f.values.InventoryName, "inventory-name", d.InventoryName,
+		"The name of the ConfigMap recording the fabric resources owned by the operator. Overrides $INVENTORY_NAME.")
+	fs.IntVar(&f.values.CrawlWorkers, "crawl-workers", d.CrawlWorkers,
+		"The number of switch and port documents fetched in parallel. Overrides $CRAWL_WORKERS.")
+	fs.Float64Var(&f.values.CrawlRateLimit, "crawl-rate-limit", d.CrawlRateLimit,
+		"The requests per second sent to Fabric Manager when crawling the topology. Zero disables the limit. Overrides $CRAWL_RATE_LIMIT.")
+	fs.BoolVar(&f.values.EnableWebhooks, "enable-webhooks", d.EnableWebhooks,
+		"Serve the SlingshotTenant admission webhooks. Overrides $ENABLE_WEBHOOKS.")
+	fs.StringVar(&f.values.DefaultVNIBlockName, "default-vni-block-name", d.DefaultVNIBlockName,
+		"The VNI block name set on SlingshotTenants without one. Overrides $DEFAULT_VNI_BLOCK_NAME.")
+	fs.StringVar(&f.values.DefaultTenantVersion, "default-tenant-version", d.DefaultTenantVersion,
+		"The tenant version set on SlingshotTenants without one. Overrides $DEFAULT_TENANT_VERSION.")
+	fs.IntVar(&f.values.DefaultVNICount, "default-vni-count", d.DefaultVNICount,
+		"The VNI count set on SlingshotTenants without a VNI count or ranges. Overrides $DEFAULT_VNI_COUNT.")
+	fs.StringVar(&f.vniPool, "vni-pool", strings.Join(d.VNIPool, ","),
+		"Comma separated VNI ranges the operator allocates tenant VNIs from. Empty lets Fabric Manager allocate them. Overrides $VNI_POOL.")
+	fs.StringVar(&f.values.VNIAllocationsName, "vni-allocations-name", d.VNIAllocationsName,
+		"The name of the ConfigMap recording the VNIs of each tenant. Overrides $VNI_ALLOCATIONS_NAME.")
+	fs.StringVar(&f.vlanPool, "vlan-pool", strings.Join(d.VLANPool, ","),
+		"Comma separated VLAN ID ranges the VLAN IDs of the tenants are allocated from. Overrides $VLAN_POOL.")
+	fs.StringVar(&f.vlanExclude, "vlan-exclude", strings.Join(d.VLANExclude, ","),
+		"Comma separated VLAN IDs and ranges of the pool that are never allocated. Overrides $VLAN_EXCLUDE.")
+	fs.StringVar(&f.values.VLANAllocationsName, "vlan-allocations-name", d.VLANAllocationsName,
+		"The name of the ConfigMap recording the VLAN ID of each tenant. Overrides $VLAN_ALLOCATIONS_NAME.")
+
+	return f
+}
+
+// Load builds the configuration from the defaults, the config file, the
+// environment and the flags that were set on the command line
+func (f *Flags) Load() (Config, error) {
+	c := Default()
+
+	configFile := f.configFile
+	if configFile == "" {
+		configFile = os.Getenv("CONFIG_FILE")
+	}
+	if configFile != "" {
+		if err := c.LoadFile(configFile); err != nil {
+			return c, err
+		}
+	}
+
+	if err := c.LoadEnv(os.LookupEnv); err != nil {
+		return c, err
+	}
+
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "fabric-manager-url":
+			c.FabricManagerURL = f.values.FabricManagerURL
+		case "ca-cert-path":
+			c.CACertPath = f.values.CACertPath
+		case "skip-tls-verify":
+			c.SkipTLSVerify = f.values.SkipTLSVerify
+		case "ca-reload-interval":
+			c.CAReloadInterval = f.values.CAReloadInterval
+		case "max-idle-conns":
+			c.MaxIdleConns = f.values.MaxIdleConns
+		case "max-idle-conns-per-host":
+			c.MaxIdleConnsPerHost = f.values.MaxIdleConnsPerHost
+		case "max-conns-per-host":
+			c.MaxConnsPerHost = f.values.MaxConnsPerHost
+		case "idle-conn-timeout":
+			c.IdleConnTimeout = f.values.IdleConnTimeout
+		case "request-timeout":
+			c.RequestTimeout = f.values.RequestTimeout
+		case "request-retries":
+			c.RequestRetries = f.values.RequestRetries
+		case "retry-base-delay":
+			c.RetryBaseDelay = f.values.RetryBaseDelay
+		case "retry-max-delay":
+			c.RetryMaxDelay = f.values.RetryMaxDelay
+		case "circuit-breaker-threshold":
+			c.CircuitBreakerThreshold = f.values.CircuitBreakerThreshold
+		case "circuit-breaker-cooldown":
+			c.CircuitBreakerCooldown = f.values.CircuitBreakerCooldown
+		case "reconciliation-time":
+			c.ReconciliationTime = f.values.ReconciliationTime
+		case "enforcement-timeout":
+			c.EnforcementTimeout = f.values.EnforcementTimeout
+		case "enforcement-retries":
+			c.EnforcementRetries = f.values.EnforcementRetries
+		case "gc-interval":
+			c.GCInterval = f.values.GCInterval
+		case "gc-grace-period":
+			c.GCGracePeriod = f.values.GCGracePeriod
+		case "gc-dry-run":
+			c.GCDryRun = f.values.GCDryRun
+		case "inventory-namespace":
+			c.InventoryNamespace = f.values.InventoryNamespace
+		case "inventory-name":
+			c.InventoryName = f.values.InventoryName
+		case "crawl-workers":
+			c.CrawlWorkers = f.values.CrawlWorkers
+		case "crawl-rate-limit":
+			c.CrawlRateLimit = f.values.CrawlRateLimit
+		case "enable-webhooks":
+			c.EnableWebhooks = f.values.EnableWebhooks
+		case "default-vni-block-name":
+			c.DefaultVNIBlockName = f.values.DefaultVNIBlockName
+		case "default-tenant-version":
+			c.DefaultTenantVersion = f.values.DefaultTenantVersion
+		case "default-vni-count":
+			c.DefaultVNICount = f.values.DefaultVNICount
+		case "vni-pool":
+			c.VNIPool = splitList(f.vniPool)
+		case "vni-allocations-name":
+			c.VNIAllocationsName = f.values.VNIAllocationsName
+		case "vlan-pool":
+			c.VLANPool = splitList(f.vlanPool)
+		case "vlan-exclude":
+			c.VLANExclude = splitList(f.vlanExclude)
+		case "vlan-allocations-name":
+			c.VLANAllocationsName = f.values.VLANAllocationsName
+		}
+	})
+
+	return c, c.Validate()
+}
+
+// Transport returns a transport with the TLS settings and connection limits
+// of the configuration, to be shared by the clients
+func (c *Config) Transport() *httpclient.Transport {
+	transport := httpclient.NewTransport(c.CACertPath, c.SkipTLSVerify)
+	transport.CAReloadInterval = c.CAReloadInterval.Duration
+	transport.MaxIdleConns = c.MaxIdleConns
+	transport.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = c.MaxConnsPerHost
+	transport.IdleConnTimeout = c.IdleConnTimeout.Duration
+
+	return transport
+}
+
+// HTTPClient returns a client for baseURL that sends its requests through
+// transport, with the request timeout and retries of the configuration
+func (c *Config) HTTPClient(baseURL string, transport *httpclient.Transport) *httpclient.Client {
+	httpClient := httpclient.NewClient(baseURL)
+	httpClient.Transport = transport
+	httpClient.Timeout = c.RequestTimeout.Duration
+	httpClient.Retries = c.RequestRetries
+	httpClient.RetryBaseDelay = c.RetryBaseDelay.Duration
+	httpClient.RetryMaxDelay = c.RetryMaxDelay.Duration
+
+	return httpClient
+}
+
+// CircuitBreaker returns the circuit breaker of the Fabric Manager requests,
+// or nil when it is disabled
+func (c *Config) CircuitBreaker() *httpclient.CircuitBreaker {
+	if c.CircuitBreakerThreshold == 0 {
+		return nil
+	}
+
+	return httpclient.NewCircuitBreaker(c.CircuitBreakerThreshold, c.CircuitBreakerCooldown.Duration)
+}
+
+// SlingshotTenantDefaults returns the defaults set by the SlingshotTenant webhook
+func (c *Config) SlingshotTenantDefaults() slingshotv1alpha1.SlingshotTenantDefaults {
+	return slingshotv1alpha1.SlingshotTenantDefaults{
+		VNIBlockName:  c.DefaultVNIBlockName,
+		TenantVersion: c.DefaultTenantVersion,
+		VNICount:      c.DefaultVNICount,
+	}
+}
+
+// splitList splits a comma separated list, ignoring the empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,149 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+)
+
+func TestLoad(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(configFile, []byte(`
+fabricManagerURL: https://file.example.com/apis/fabric-manager
+requestTimeout: 10s
+reconciliationTime: 2m
+clientID: file-client
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CONFIG_FILE", configFile)
+	t.Setenv("REQUEST_TIMEOUT", "20s")
+	t.Setenv("SKIP_TLS_VERIFY", "true")
+	t.Setenv("GC_DRY_RUN", "false")
+	t.Setenv("INVENTORY_NAMESPACE", "services")
+	t.Setenv("CRAWL_RATE_LIMIT", "0")
+	t.Setenv("ENABLE_WEBHOOKS", "true")
+	t.Setenv("DEFAULT_VNI_COUNT", "64")
+	t.Setenv("VNI_POOL", "1000-1999")
+	t.Setenv("VLAN_EXCLUDE", "1, 4000-4094")
+	t.Setenv("ENFORCEMENT_TIMEOUT", "15m")
+	t.Setenv("REQUEST_RETRIES", "5")
+	t.Setenv("CIRCUIT_BREAKER_COOLDOWN", "1m")
+	t.Setenv("MAX_CONNS_PER_HOST", "20")
+	t.Setenv("CA_RELOAD_INTERVAL", "0")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := BindFlags(fs)
+	err = fs.Parse([]string{"--reconciliation-time=5m", "--gc-interval=0", "--crawl-workers=16", "--default-vni-block-name=block", "--vni-pool=1024-4095, 8192-9999", "--vlan-pool=1-2000,3000-4094", "--enforcement-retries=0", "--circuit-breaker-threshold=0", "--idle-conn-timeout=2m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := flags.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.FabricManagerURL != "https://file.example.com/apis/fabric-manager" {
+		t.Errorf("expected fabric manager URL from the config file, got %s", c.FabricManagerURL)
+	}
+	if c.ClientID != "file-client" {
+		t.Errorf("expected client ID from the config file, got %s", c.ClientID)
+	}
+	if c.RequestTimeout.Duration != 20*time.Second {
+		t.Errorf("expected request timeout from the environment, got %s", c.RequestTimeout.Duration)
+	}
+	if !c.SkipTLSVerify {
+		t.Error("expected TLS verification to be skipped")
+	}
+	if c.ReconciliationTime.Duration != 5*time.Minute {
+		t.Errorf("expected reconciliation time from the flag, got %s", c.ReconciliationTime.Duration)
+	}
+	if c.GCDryRun || c.GCInterval.Duration != 0 || c.GCGracePeriod.Duration != DefaultGCGracePeriod {
+		t.Errorf("unexpected garbage collection settings %+v", c)
+	}
+	if c.InventoryNamespace != "services" || c.InventoryName != DefaultInventoryName {
+		t.Errorf("unexpected inventory %s/%s", c.InventoryNamespace, c.InventoryName)
+	}
+	if c.CrawlWorkers != 16 || c.CrawlRateLimit != 0 {
+		t.Errorf("unexpected crawl settings %d workers, %g requests per second", c.CrawlWorkers, c.CrawlRateLimit)
+	}
+	if !c.EnableWebhooks {
+		t.Error("expected webhooks to be enabled from the environment")
+	}
+	defaults := c.SlingshotTenantDefaults()
+	if defaults.VNIBlockName != "block" || defaults.TenantVersion != DefaultTenantVersion || defaults.VNICount != 64 {
+		t.Errorf("unexpected slingshot tenant defaults %+v", defaults)
+	}
+	if !reflect.DeepEqual(c.VNIPool, []string{"1024-4095", "8192-9999"}) || c.VNIAllocationsName != DefaultVNIAllocationsName {
+		t.Errorf("unexpected VNI pool %v in %s", c.VNIPool, c.VNIAllocationsName)
+	}
+	if !reflect.DeepEqual(c.VLANPool, []string{"1-2000", "3000-4094"}) || !reflect.DeepEqual(c.VLANExclude, []string{"1", "4000-4094"}) {
+		t.Errorf("unexpected VLAN pool %v excluding %v", c.VLANPool, c.VLANExclude)
+	}
+	if c.EnforcementTimeout.Duration != 15*time.Minute || c.EnforcementRetries != 0 {
+		t.Errorf("unexpected enforcement settings %s timeout, %d retries", c.EnforcementTimeout.Duration, c.EnforcementRetries)
+	}
+	if c.RequestRetries != 5 || c.RetryBaseDelay.Duration != httpclient.DefaultRetryBaseDelay || c.RetryMaxDelay.Duration != httpclient.DefaultRetryMaxDelay {
+		t.Errorf("unexpected retry settings %d retries, %s to %s", c.RequestRetries, c.RetryBaseDelay.Duration, c.RetryMaxDelay.Duration)
+	}
+	if c.MaxConnsPerHost != 20 || c.MaxIdleConns != httpclient.DefaultMaxIdleConns || c.IdleConnTimeout.Duration != 2*time.Minute {
+		t.Errorf("unexpected connection settings %d connections per host, %d idle, %s idle timeout", c.MaxConnsPerHost, c.MaxIdleConns, c.IdleConnTimeout.Duration)
+	}
+	if transport := c.Transport(); transport.CAReloadInterval != 0 || transport.MaxConnsPerHost != 20 || transport.CACertPath != c.CACertPath {
+		t.Errorf("unexpected transport %+v", transport)
+	}
+	if c.CircuitBreakerThreshold != 0 || c.CircuitBreakerCooldown.Duration != time.Minute || c.CircuitBreaker() != nil {
+		t.Errorf("expected the circuit breaker to be disabled, got threshold %d, cooldown %s", c.CircuitBreakerThreshold, c.CircuitBreakerCooldown.Duration)
+	}
+	if c.CACertPath != DefaultCACertPath {
+		t.Errorf("expected default CA certificate path, got %s", c.CACertPath)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+	}{
+		{name: "missing scheme", modify: func(c *Config) { c.FabricManagerURL = "api-gw-service-nmn.local" }},
+		{name: "zero request timeout", modify: func(c *Config) { c.RequestTimeout.Duration = 0 }},
+		{name: "negative reconciliation time", modify: func(c *Config) { c.ReconciliationTime.Duration = -time.Second }},
+		{name: "zero enforcement timeout", modify: func(c *Config) { c.EnforcementTimeout.Duration = 0 }},
+		{name: "negative enforcement retries", modify: func(c *Config) { c.EnforcementRetries = -1 }},
+		{name: "negative request retries", modify: func(c *Config) { c.RequestRetries = -1 }},
+		{name: "negative CA reload interval", modify: func(c *Config) { c.CAReloadInterval.Duration = -time.Second }},
+		{name: "negative connection limit", modify: func(c *Config) { c.MaxConnsPerHost = -1 }},
+		{name: "zero idle connections per host", modify: func(c *Config) { c.MaxIdleConnsPerHost = 0 }},
+		{name: "retry max delay shorter than base delay", modify: func(c *Config) { c.RetryMaxDelay.Duration = time.Millisecond }},
+		{name: "zero circuit breaker cooldown", modify: func(c *Config) { c.CircuitBreakerCooldown.Duration = 0 }},
+		{name: "missing inventory name", modify: func(c *Config) { c.InventoryName = "" }},
+		{name: "no crawl workers", modify: func(c *Config) { c.CrawlWorkers = 0 }},
+		{name: "missing default VNI block name", modify: func(c *Config) { c.DefaultVNIBlockName = "" }},
+		{name: "default VNI count too large", modify: func(c *Config) { c.DefaultVNICount = 65536 }},
+		{name: "invalid VNI pool", modify: func(c *Config) { c.VNIPool = []string{"2000-1000"} }},
+		{name: "VLAN pool out of range", modify: func(c *Config) { c.VLANPool = []string{"1-4095"} }},
+		{name: "VLAN pool excluded", modify: func(c *Config) { c.VLANExclude = []string{"1-4094"} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			tt.modify(&c)
+			if err := c.Validate(); err == nil {
+				t.Error("expected an error, got none")
+			}
+		})
+	}
+}
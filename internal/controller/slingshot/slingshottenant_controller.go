@@ -7,21 +7,26 @@ package slingshot
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
+	"time"
 
+	"github.hpe.com/hpe/sshot-net-operator/fm"
 	"github.hpe.com/hpe/sshot-net-operator/httpclient"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
 	tapmsapi "github.hpe.com/hpe/sshot-net-operator/api/tapms/v1alpha2"
 	tapms "github.hpe.com/hpe/sshot-net-operator/internal/controller/tapms"
+	"github.hpe.com/hpe/sshot-net-operator/internal/inventory"
+	"github.hpe.com/hpe/sshot-net-operator/internal/ipam"
 	"github.hpe.com/hpe/sshot-net-operator/models"
 )
 
@@ -29,10 +34,29 @@ import (
 type SlingshotTenantReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Fabric is the Fabric Manager client
+	Fabric *fm.Client
+
+	// Inventory records the Fabric Manager documents owned by the operator
+	Inventory *inventory.Inventory
+
+	// VNIs allocates the VNIs of the tenants that only request a count. When
+	// nil, Fabric Manager allocates them
+	VNIs *ipam.Allocator
+
+	// VLANs allocates the VLAN IDs of the tenants. When nil, the lowest VLAN
+	// ID that does not exist in Fabric Manager is used
+	VLANs *ipam.VLANAllocator
+
+	// ReconciliationTime is the interval at which slingshot tenants are reconciled again
+	ReconciliationTime time.Duration
+
+	// Recorder records the events of the fabric operations on the tenants
+	Recorder record.EventRecorder
 }
 
 var (
-	httpClient                   = httpclient.NewClient(models.BaseURL)
 	slingshotTenantGenerationMap = make(map[string]int64)
 	slingshotTenantList          slingshot.SlingshotTenantList
 	tenant                       tapmsapi.Tenant
@@ -58,6 +82,16 @@ func (r *SlingshotTenantReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, nil
 	}
 
+	//tear down the network of a deleted slingshot tenant before its finalizer is removed
+	if !sshotTenant.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalize(ctx, &sshotTenant)
+	}
+
+	if err := tapms.EnsureFinalizer(ctx, r.Client, &sshotTenant); err != nil {
+		log.Printf("cannot add finalizer to slingshot tenant: %+v", err)
+		return ctrl.Result{}, err
+	}
+
 	var tenants tapmsapi.TenantList
 	if err := r.List(ctx, &tenants); err != nil {
 		log.Printf("cannot get tenant: %+v", err)
@@ -98,19 +132,23 @@ func (r *SlingshotTenantReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 
 	if !tenantFound {
 		log.Printf("tenant not found: %s", sshotTenant.Spec.TenantName)
-		return ctrl.Result{}, nil
-	}
-
-	if models.AccessToken == "" {
-		log.Println("access token not found")
+		err := tapms.UpdateStatus(ctx, r.Client, r.Fabric, r.Inventory, &sshotTenant, fmt.Errorf("tenant %s not found", sshotTenant.Spec.TenantName))
+		if err != nil {
+			log.Printf("cannot update slingshot tenant status: %+v", err)
+		}
 		return ctrl.Result{}, nil
 	}
 
 	//handle update event
 	if sshotTenant.Generation != slingshotTenantGenerationMap[sshotTenant.Name] {
-		err := r.handleUpdate(ctx, &sshotTenant, tenantXnames, httpClient)
+		tenantCtx := tapms.WithEvents(ctx, r.Recorder, &sshotTenant, tenant.DeepCopy())
+		err := r.handleUpdate(tenantCtx, &sshotTenant, tenantXnames)
+		if statusErr := tapms.UpdateStatus(tenantCtx, r.Client, r.Fabric, r.Inventory, &sshotTenant, err); statusErr != nil {
+			log.Printf("cannot update slingshot tenant status: %+v", statusErr)
+		}
 		if err != nil {
 			log.Printf("cannot update tenant: %s", err)
+			tapms.RecordTokenFailure(tenantCtx, err)
 			return ctrl.Result{}, nil
 		}
 
@@ -118,11 +156,15 @@ func (r *SlingshotTenantReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 
 	}
 
-	return ctrl.Result{RequeueAfter: models.ReconciliationTime}, nil
+	return ctrl.Result{RequeueAfter: r.ReconciliationTime}, nil
 }
 
 var predicateFunctions = predicate.Funcs{
 	UpdateFunc: func(e event.UpdateEvent) bool {
+		if !e.ObjectNew.GetDeletionTimestamp().IsZero() {
+			log.Printf("delete event detected for %s/%s", e.ObjectNew.GetNamespace(), e.ObjectNew.GetName())
+			return true
+		}
 		if e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() {
 			log.Printf("update event detected for %s/%s", e.ObjectNew.GetNamespace(), e.ObjectNew.GetName())
 			return true
@@ -139,119 +181,141 @@ func (r *SlingshotTenantReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Complete(r)
 }
 
-func (r *SlingshotTenantReconciler) handleUpdate(ctx context.Context, instance *slingshot.SlingshotTenant, tenantXnames []string, httpClient *httpclient.Client) error {
-	// to update VNI partition and VNI block, delete the VNI block and VNI partition and create them again
+// finalize tears down the network of a deleted slingshot tenant and removes its finalizer
+func (r *SlingshotTenantReconciler) finalize(ctx context.Context, sshotTenant *slingshot.SlingshotTenant) error {
+	if !controllerutil.ContainsFinalizer(sshotTenant, tapms.Finalizer) {
+		return nil
+	}
 
-	log.Println("handling VNI update event for", instance.Spec.TenantName)
+	ctx = tapms.WithEvents(ctx, r.Recorder, sshotTenant)
 
-	//check if vni partition exists
-	_, err := GetVNIPartition(instance.Spec.TenantName)
+	log.Printf("slingshot tenant %s is deleted. deleting VNI block, partition and VLAN", sshotTenant.Name)
+	err := tapms.TeardownTenant(ctx, r.Client, r.Fabric, r.Inventory, r.VNIs, r.VLANs, sshotTenant.Spec.TenantName, sshotTenant)
 	if err != nil {
-		log.Printf("cannot find VNI partition: %s", instance.Spec.TenantName)
+		log.Printf("cannot delete network of slingshot tenant %s: %+v", sshotTenant.Name, err)
+		tapms.RecordTokenFailure(ctx, err)
 		return err
 	}
 
-	var vniRequestData models.VNIRequestData
-	vniRequestData.PartitionName = instance.Spec.TenantName
-	vniRequestData.VNICount = instance.Spec.VNIPartition.VNICount
-	vniRequestData.VNIRange = instance.Spec.VNIPartition.VNIRange
-
-	// Validate the VNI request data
-	err = tapms.ValidateVNIRequestData(vniRequestData)
+	err = tapms.RemoveFinalizer(ctx, r.Client, sshotTenant)
 	if err != nil {
+		log.Printf("cannot remove finalizer from slingshot tenant: %+v", err)
 		return err
 	}
+	delete(slingshotTenantGenerationMap, sshotTenant.Name)
 
-	vniBlockName := fmt.Sprintf("%s-%s", instance.Spec.TenantName, instance.Spec.VNIBlockName)
+	return nil
+}
 
-	err = tapms.HandleDelete(ctx, instance.Spec.TenantName, vniBlockName)
+func (r *SlingshotTenantReconciler) handleUpdate(ctx context.Context, instance *slingshot.SlingshotTenant, tenantXnames []string) error {
+	// to update VNI partition and VNI block, delete the VNI block and VNI partition and create them again.
+	// changes to the VLAN spec are applied by the tenant reconciler
+
+	log.Println("handling VNI update event for", instance.Spec.TenantName)
+
+	//check if vni partition exists
+	_, err := r.Fabric.VNIPartitions().Get(ctx, instance.Spec.TenantName)
 	if err != nil {
-		log.Printf("cannot delete VNI partition and VNI block: %+v", err)
+		log.Printf("cannot find VNI partition: %s", instance.Spec.TenantName)
 		return err
 	}
 
-	//recreate VNI partition and VNI block
-	err = createVNIPartition(ctx, instance, tenantXnames, httpClient)
+	//only recreate the VNI partition and VNI block when their spec changed
+	changed, err := tapms.VNISpecChanged(ctx, r.Fabric, *instance)
 	if err != nil {
-		log.Printf("cannot create VNI partition: %+v", err)
+		log.Printf("cannot check VNI spec: %+v", err)
 		return err
 	}
+	if !changed {
+		log.Printf("VNI spec of %s is unchanged", instance.Spec.TenantName)
+		return tapms.RecordAppliedVNI(ctx, r.Client, instance)
+	}
 
-	VNIBlock, err := tapms.CreateVNIBlock(ctx, tenant, *instance)
+	//only a VNI partition created by the operator is recreated
+	owns, err := r.Inventory.Owns(ctx, inventory.VNIPartition, instance.Spec.TenantName)
 	if err != nil {
-		log.Printf("cannot create VNI block: %+v", err)
+		log.Printf("cannot load inventory: %+v", err)
 		return err
 	}
+	if !owns {
+		return fmt.Errorf("VNI partition %s %w", instance.Spec.TenantName, tapms.ErrNotOwned)
+	}
 
-	log.Printf("updated VNI block %s for the tenant %s", VNIBlock.DocumentSelfLink, tenant.Spec.TenantName)
-
-	//Check the stage of VniBlockEnforceTaskServiceState, keep checking until it is "FINISHED" or "FAILED"
-	stage, err := tapms.CheckVniBlockEnforceTaskServiceState(ctx, VNIBlock.EnforcementTaskServiceLink)
+	// Validate the VNI request data
+	err = tapms.ValidateVNIRequestData(models.VNIRequestData{
+		PartitionName: instance.Spec.TenantName,
+		VNICount:      instance.Spec.VNIPartition.VNICount,
+		VNIRange:      instance.Spec.VNIPartition.VNIRange,
+	})
 	if err != nil {
-		log.Printf("cannot check VniBlockEnforceTaskServiceState: %+v", err)
 		return err
 	}
 
-	if stage {
-		log.Printf("enforcement for VNI block %s is completed", VNIBlock.DocumentSelfLink)
-	} else {
-		log.Printf("enforcement for VNI block %s is failed", VNIBlock.DocumentSelfLink)
-	}
-
-	return nil
-
-}
+	vniBlockName := fmt.Sprintf("%s-%s", instance.Spec.TenantName, instance.Spec.VNIBlockName)
 
-// GetVNIPartition gets the VNI partition
-func GetVNIPartition(partition string) (models.VNIPartitionResponse, error) {
-	ctx := context.Background()
+	err = tapms.HandleDelete(ctx, r.Fabric, r.Inventory, instance.Spec.TenantName, vniBlockName)
+	if err != nil {
+		log.Printf("cannot delete VNI partition and VNI block: %+v", err)
+		return err
+	}
 
-	var vniPartition models.VNIPartitionResponse
-	httpClient := httpclient.NewClient(models.BaseURL)
-	responseBody, err := httpClient.SendRequest(ctx, "GET", "/fabric/vni/partitions/"+partition, models.VNIRequestData{})
+	//recreate VNI partition and VNI block
+	err = createVNIPartition(ctx, r.Fabric, r.Inventory, r.VNIs, instance, tenantXnames)
 	if err != nil {
-		log.Printf("cannot get VNI partition: %+v %+v", partition, err)
-		return vniPartition, err
+		log.Printf("cannot create VNI partition: %+v", err)
+		return err
 	}
 
-	err = json.Unmarshal(responseBody, &vniPartition)
+	VNIBlock, err := tapms.CreateVNIBlock(ctx, r.Fabric, r.Inventory, tenant, *instance)
 	if err != nil {
-		log.Printf("cannot unmarshal all VNI partitions: %+v %+v", responseBody, err)
-		return vniPartition, err
+		log.Printf("cannot create VNI block: %+v", err)
+		return err
 	}
 
-	return vniPartition, nil
+	//the enforcement task is tracked by the tenant reconciler, without waiting for it here
+	log.Printf("updated VNI block %s for the tenant %s, enforcement task %s started", VNIBlock.DocumentSelfLink, tenant.Spec.TenantName,
+		VNIBlock.EnforcementTaskServiceLink)
+
+	return tapms.RecordAppliedVNI(ctx, r.Client, instance)
 
 }
 
 // createVNIPartition creates the VNI partition
-func createVNIPartition(ctx context.Context, instance *slingshot.SlingshotTenant, tenantXnames []string, httpClient *httpclient.Client) error {
-	var vniRequestData models.VNIRequestData
-	vniRequestData.PartitionName = instance.Spec.TenantName
-	vniRequestData.VNICount = instance.Spec.VNIPartition.VNICount
-	vniRequestData.VNIRange = instance.Spec.VNIPartition.VNIRange
-
-	// Validate the VNI request data
-	err := tapms.ValidateVNIRequestData(vniRequestData)
+func createVNIPartition(ctx context.Context, fabric *fm.Client, inv *inventory.Inventory, vnis *ipam.Allocator, instance *slingshot.SlingshotTenant, tenantXnames []string) error {
+	vniRequestData, err := tapms.NewVNIRequestData(ctx, vnis, instance.Spec.TenantName, *instance)
 	if err != nil {
 		return err
 	}
 
 	//Get edgePortDFAs for the tenant
-	edgePortDFAList, _, err := tapms.GetEdgePortDFAList(tenantXnames)
+	edgePortDFAList, _, err := tapms.GetEdgePortDFAList(ctx, fabric, tenantXnames)
 	if err != nil {
 		log.Printf("cannot get edge ports for tenant: %+v", err)
 		return err
 	}
 	vniRequestData.EdgePortDFA = edgePortDFAList
 
+	//Record the VNI partition before creating it, so that it is never left unowned
+	forget, err := tapms.RecordCreate(ctx, inv, inventory.VNIPartition, instance.Spec.TenantName, instance.Spec.TenantName)
+	if err != nil {
+		log.Printf("cannot record VNI partition: %+v", err)
+		return err
+	}
+
 	// Send the request
-	_, err = httpClient.SendRequest(ctx, "POST", "/fabric/vni/partitions", vniRequestData)
+	_, err = fabric.VNIPartitions().Create(ctx, vniRequestData)
+	if httpclient.IsConflict(err) {
+		//the VNI partition may have been created by a request whose response was lost
+		_, err = tapms.AdoptVNIPartition(ctx, fabric, vniRequestData, err)
+	}
 	if err != nil {
 		log.Printf("cannot create VNI partition: %+v", err)
+		forget(err)
+		tapms.WarningEvent(ctx, tapms.EventVNIPartitionCreateFailed, "cannot create VNI partition %s: %v", vniRequestData.PartitionName, err)
 		return err
 	}
 
 	log.Println("VNI partition created", vniRequestData.PartitionName)
+	tapms.NormalEvent(ctx, tapms.EventVNIPartitionCreated, "created VNI partition %s", vniRequestData.PartitionName)
 	return nil
 }
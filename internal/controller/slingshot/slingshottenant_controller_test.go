@@ -14,3 +14,127 @@ limitations under the License.
 */
 
 package slingshot
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+	tapmsapi "github.hpe.com/hpe/sshot-net-operator/api/tapms/v1alpha2"
+	"github.hpe.com/hpe/sshot-net-operator/fm"
+	"github.hpe.com/hpe/sshot-net-operator/fm/fmtest"
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+	tapms "github.hpe.com/hpe/sshot-net-operator/internal/controller/tapms"
+	"github.hpe.com/hpe/sshot-net-operator/internal/inventory"
+)
+
+// vniWrites returns the requests that changed a VNI partition or VNI block
+func vniWrites(server *fmtest.Server) []string {
+	var writes []string
+	for _, r := range server.Requests() {
+		if !strings.HasPrefix(r, "GET") && strings.Contains(r, "/fabric/vni/") {
+			writes = append(writes, r)
+		}
+	}
+
+	return writes
+}
+
+func TestHandleUpdate(t *testing.T) {
+	ctx := context.Background()
+	server := fmtest.NewServer()
+	defer server.Close()
+	server.AddSwitch("x1000c2r3b0", 1, 3)
+	err := server.AddEdgePort("x1000c2r3b0", 100, "x1000c2r3j100p0", "x1000c2s0b0n0h0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fabric := fm.NewClient(httpclient.NewClient(server.URL))
+
+	tenant = tapmsapi.Tenant{
+		ObjectMeta: metav1.ObjectMeta{Name: "vcluster-blue", Generation: 1},
+		Spec: tapmsapi.TenantSpec{
+			TenantName:      "vcluster-blue",
+			TenantResources: []tapmsapi.TenantResources{{Type: "compute", XNames: []string{"x1000c2s0b0n0"}}},
+		},
+	}
+	sshotTenant := &slingshot.SlingshotTenant{
+		ObjectMeta: metav1.ObjectMeta{Name: "vcluster-blue-slingshot", Generation: 1},
+		Spec: slingshot.SlingshotTenantSpec{
+			TenantName:   "vcluster-blue",
+			VNIBlockName: "block",
+			VNIPartition: slingshot.VNIPartition{VNIRange: []string{"2000-2009"}},
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	if err := core.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := slingshot.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(sshotTenant).
+		WithStatusSubresource(&slingshot.SlingshotTenant{}).
+		Build()
+	r := &SlingshotTenantReconciler{
+		Client:    c,
+		Fabric:    fabric,
+		Inventory: inventory.New(c, "sshot-net-operator", "sshot-net-operator-inventory", nil),
+	}
+
+	err = tapms.HandleCreate(ctx, fabric, r.Inventory, nil, &tenant, *sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tapms.CreateVNIBlock(ctx, fabric, r.Inventory, tenant, *sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writes := len(vniWrites(server))
+
+	// a VLAN-only change leaves the VNI partition and VNI block untouched
+	sshotTenant.Spec.VLAN.ID = 100
+	err = r.handleUpdate(ctx, sshotTenant, []string{"x1000c2s0b0n0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := vniWrites(server)[writes:]; len(changes) != 0 {
+		t.Errorf("expected the VNI partition and VNI block not to change, got %v", changes)
+	}
+	expected := &slingshot.AppliedVNISpec{VNIPartition: sshotTenant.Spec.VNIPartition, VNIBlockName: "block"}
+	var updated slingshot.SlingshotTenant
+	if err := c.Get(ctx, client.ObjectKeyFromObject(sshotTenant), &updated); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(updated.Status.AppliedVNI, expected) {
+		t.Errorf("expected applied VNI %+v, got %+v", expected, updated.Status.AppliedVNI)
+	}
+
+	// a VNI range change recreates them
+	sshotTenant.Spec.VNIPartition.VNIRange = []string{"3000-3009"}
+	err = r.handleUpdate(ctx, sshotTenant, []string{"x1000c2s0b0n0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vniPartition, _ := server.VNIPartition("vcluster-blue")
+	if !reflect.DeepEqual(vniPartition.VNIRange, []string{"3000-3009"}) {
+		t.Errorf("expected the VNI partition to be recreated with the new range, got %v", vniPartition.VNIRange)
+	}
+	if _, ok := server.VNIBlock("vcluster-blue-block"); !ok {
+		t.Error("expected the VNI block to be recreated")
+	}
+	if !reflect.DeepEqual(sshotTenant.Status.AppliedVNI.VNIPartition.VNIRange, []string{"3000-3009"}) {
+		t.Errorf("expected the new range to be recorded as applied, got %+v", sshotTenant.Status.AppliedVNI)
+	}
+}
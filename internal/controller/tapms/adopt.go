@@ -0,0 +1,316 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package tapms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+	tapms "github.hpe.com/hpe/sshot-net-operator/api/tapms/v1alpha2"
+	"github.hpe.com/hpe/sshot-net-operator/fm"
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+	"github.hpe.com/hpe/sshot-net-operator/internal/inventory"
+	"github.hpe.com/hpe/sshot-net-operator/internal/ipam"
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+// AdoptExisting returns the seed of the inventory. It adopts the VNI
+// partitions, VNI blocks, VLANs and port policies that follow the naming the
+// operator used before the inventory existed, for the tenants that have both
+// a Tenant and a SlingshotTenant
+func AdoptExisting(c client.Reader, fabric *fm.Client) inventory.SeedFunc {
+	return func(ctx context.Context) ([]inventory.Entry, error) {
+		var entries []inventory.Entry
+
+		var tenants tapms.TenantList
+		if err := c.List(ctx, &tenants); err != nil {
+			return entries, fmt.Errorf("cannot list tenants: %w", err)
+		}
+
+		var sshotTenants slingshot.SlingshotTenantList
+		if err := c.List(ctx, &sshotTenants); err != nil {
+			return entries, fmt.Errorf("cannot list slingshot tenants: %w", err)
+		}
+
+		//tenant name to VNI block name
+		vniBlockNames := make(map[string]string)
+		for _, tenant := range tenants.Items {
+			for _, sshotTenant := range sshotTenants.Items {
+				if sshotTenant.Spec.TenantName == tenant.Spec.TenantName {
+					vniBlockNames[tenant.Spec.TenantName] = fmt.Sprintf("%s-%s", tenant.Spec.TenantName, sshotTenant.Spec.VNIBlockName)
+				}
+			}
+		}
+		if len(vniBlockNames) == 0 {
+			return entries, nil
+		}
+
+		vniPartitions, err := fabric.VNIPartitions().List(ctx)
+		if err != nil {
+			return entries, err
+		}
+		for _, link := range vniPartitions.DocumentLinks {
+			if _, ok := vniBlockNames[fm.LinkName(link)]; ok {
+				entries = append(entries, inventory.Entry{Kind: inventory.VNIPartition, Name: fm.LinkName(link), Tenant: fm.LinkName(link)})
+			}
+		}
+
+		vniBlocks, err := fabric.VNIBlocks().List(ctx)
+		if err != nil {
+			return entries, err
+		}
+		for tenantName, vniBlockName := range vniBlockNames {
+			if containsLink(vniBlocks.DocumentLinks, vniBlockName) {
+				entries = append(entries, inventory.Entry{Kind: inventory.VNIBlock, Name: vniBlockName, Tenant: tenantName})
+			}
+		}
+
+		portPolicies, err := fabric.PortPolicies().List(ctx)
+		if err != nil {
+			return entries, err
+		}
+		for _, link := range portPolicies.DocumentLinks {
+			if _, ok := vniBlockNames[fm.LinkName(link)]; ok {
+				entries = append(entries, inventory.Entry{Kind: inventory.PortPolicy, Name: fm.LinkName(link), Tenant: fm.LinkName(link)})
+			}
+		}
+
+		vlans, err := fabric.VLANs().List(ctx)
+		if err != nil {
+			return entries, err
+		}
+		for _, link := range vlans.DocumentLinks {
+			vlanID, err := strconv.Atoi(fm.LinkName(link))
+			if err != nil {
+				return entries, fmt.Errorf("cannot convert VLAN ID to integer: %w", err)
+			}
+
+			vlan, err := fabric.VLANs().Get(ctx, vlanID)
+			if err != nil {
+				return entries, err
+			}
+			if _, ok := vniBlockNames[vlan.VLANName]; ok {
+				entries = append(entries, inventory.Entry{Kind: inventory.VLAN, Name: strconv.Itoa(vlanID), Tenant: vlan.VLANName})
+			}
+		}
+
+		return entries, nil
+	}
+}
+
+// AdoptVNIAllocations returns the seed of the VNI allocator. It adopts the
+// VNI ranges of the VNI partitions owned by the operator, so that the tenants
+// keep the VNIs Fabric Manager allocated before the allocator existed
+func AdoptVNIAllocations(fabric *fm.Client, inv *inventory.Inventory) ipam.SeedFunc {
+	return func(ctx context.Context) (map[string][]string, error) {
+		owned, err := inv.Load(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		allocations := make(map[string][]string)
+		for _, entry := range owned.Entries() {
+			if entry.Kind != inventory.VNIPartition {
+				continue
+			}
+
+			vniPartition, err := fabric.VNIPartitions().Get(ctx, entry.Name)
+			if err != nil {
+				return nil, err
+			}
+			if len(vniPartition.VNIRange) > 0 {
+				allocations[entry.Tenant] = vniPartition.VNIRange
+			}
+		}
+
+		return allocations, nil
+	}
+}
+
+// AdoptVLANAllocations returns the seed of the VLAN allocator. It adopts the
+// VLANs owned by the operator, so that the tenants keep their VLAN ID
+func AdoptVLANAllocations(inv *inventory.Inventory) ipam.VLANSeedFunc {
+	return func(ctx context.Context) (map[string]int, error) {
+		owned, err := inv.Load(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		allocations := make(map[string]int)
+		for _, entry := range owned.Entries() {
+			if entry.Kind != inventory.VLAN {
+				continue
+			}
+
+			vlanID, err := strconv.Atoi(entry.Name)
+			if err != nil {
+				return nil, fmt.Errorf("cannot convert VLAN ID to integer: %w", err)
+			}
+			if _, ok := allocations[entry.Tenant]; !ok {
+				allocations[entry.Tenant] = vlanID
+			}
+		}
+
+		return allocations, nil
+	}
+}
+
+// refusedError is returned when a document that already exists is not adopted
+// because it is not the one requested
+type refusedError struct {
+	err error
+}
+
+func (e *refusedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *refusedError) Unwrap() error {
+	return e.err
+}
+
+// NotCreated checks if a create request definitely did not create the
+// document: Fabric Manager rejected it, or the document that already exists
+// was not adopted. A lost response or a server error may hide a created document
+func NotCreated(err error) bool {
+	var refusedErr *refusedError
+	if errors.As(err, &refusedErr) {
+		return true
+	}
+
+	var statusErr *httpclient.StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode < 500 && !httpclient.IsConflict(err)
+}
+
+// RecordCreate records a document in the inventory before it is created, so
+// that it is never left unowned. The returned function undoes the record when
+// the create request failed with an error for which NotCreated is true
+func RecordCreate(ctx context.Context, inv *inventory.Inventory, kind inventory.Kind, name string, tenantName string) (func(createErr error), error) {
+	owned, err := inv.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	previous, recorded := owned.Tenant(kind, name)
+
+	err = inv.Record(ctx, kind, name, tenantName)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(createErr error) {
+		if !NotCreated(createErr) {
+			return
+		}
+
+		//keep the document recorded by an earlier create request
+		var err error
+		if recorded {
+			err = inv.Record(ctx, kind, name, previous)
+		} else {
+			err = inv.Forget(ctx, kind, name)
+		}
+		if err != nil {
+			log.Printf("cannot forget %s %s: %+v", kind, name, err)
+		}
+	}, nil
+}
+
+// AdoptVNIPartition returns the VNI partition Fabric Manager reported as
+// already existing when it was created, after checking that it has the VNIs
+// requested: it may have been created by a request whose response was lost.
+// createErr is returned when it is another VNI partition
+func AdoptVNIPartition(ctx context.Context, fabric *fm.Client, request models.VNIRequestData, createErr error) (models.VNIPartitionResponse, error) {
+	vniPartition, err := fabric.VNIPartitions().Get(ctx, request.PartitionName)
+	if err != nil {
+		return vniPartition, fmt.Errorf("cannot get existing VNI partition %s: %v: %w", request.PartitionName, err, createErr)
+	}
+
+	if len(request.VNIRange) > 0 {
+		requested, err := NormalizeVNIRanges(request.VNIRange)
+		if err != nil {
+			return vniPartition, err
+		}
+		existing, err := NormalizeVNIRanges(vniPartition.VNIRange)
+		if err != nil || strings.Join(existing, ",") != strings.Join(requested, ",") {
+			return vniPartition, &refusedError{fmt.Errorf("VNI partition %s already exists with the VNI ranges %v instead of %v: %w",
+				request.PartitionName, vniPartition.VNIRange, requested, createErr)}
+		}
+	} else if vniPartition.VNICount != request.VNICount {
+		return vniPartition, &refusedError{fmt.Errorf("VNI partition %s already exists with %d VNIs instead of %d: %w",
+			request.PartitionName, vniPartition.VNICount, request.VNICount, createErr)}
+	}
+
+	log.Printf("adopted existing VNI partition %s", request.PartitionName)
+	return vniPartition, nil
+}
+
+// adoptVNIBlock returns the VNI block Fabric Manager reported as already
+// existing when it was created, after checking that it has the VNI partition
+// and VNI ranges requested
+func adoptVNIBlock(ctx context.Context, fabric *fm.Client, request models.VNIBlockRequestData, createErr error) (models.VNIBlockResponse, error) {
+	vniBlock, err := fabric.VNIBlocks().Get(ctx, request.VNIBlockName)
+	if err != nil {
+		return vniBlock, fmt.Errorf("cannot get existing VNI block %s: %v: %w", request.VNIBlockName, err, createErr)
+	}
+
+	existing, err := NormalizeVNIRanges(vniBlock.VNIBlockRange)
+	if err != nil || vniBlock.PartitionName != request.VNIPartitionName || strings.Join(existing, ",") != strings.Join(request.VNIBlockRange, ",") {
+		return vniBlock, &refusedError{fmt.Errorf("VNI block %s already exists in the VNI partition %s with the VNI ranges %v: %w",
+			request.VNIBlockName, vniBlock.PartitionName, vniBlock.VNIBlockRange, createErr)}
+	}
+
+	log.Printf("adopted existing VNI block %s", request.VNIBlockName)
+	return vniBlock, nil
+}
+
+// adoptVLAN returns the VLAN Fabric Manager reported as already existing
+// when it was created, after checking that it has the name of the tenant
+func adoptVLAN(ctx context.Context, fabric *fm.Client, request models.VLANRequestData, createErr error) (models.VLANResponse, error) {
+	vlan, err := fabric.VLANs().Get(ctx, request.VLANID)
+	if err != nil {
+		return vlan, fmt.Errorf("cannot get existing VLAN %d: %v: %w", request.VLANID, err, createErr)
+	}
+
+	if vlan.VLANName != request.VLANName {
+		return vlan, &refusedError{fmt.Errorf("VLAN %d already exists with the name %s: %w", request.VLANID, vlan.VLANName, createErr)}
+	}
+
+	log.Printf("adopted existing VLAN %d", request.VLANID)
+	return vlan, nil
+}
+
+// adoptVLANPortPolicy returns the port policy Fabric Manager reported as
+// already existing when it was created, after checking that it allows the
+// VLAN of the tenant
+func adoptVLANPortPolicy(ctx context.Context, fabric *fm.Client, request models.VLANPortPolicyRequest, createErr error) (models.VLANPortPolicyResponse, error) {
+	portPolicy, err := fabric.PortPolicies().Get(ctx, request.DocumentSelfLink)
+	if err != nil {
+		return models.VLANPortPolicyResponse{}, fmt.Errorf("cannot get existing port policy %s: %v: %w", request.DocumentSelfLink, err, createErr)
+	}
+
+	if len(request.AllowedVlans) == 0 || !containsLink(portPolicy.AllowedVlans, fm.LinkName(request.AllowedVlans[0])) {
+		return models.VLANPortPolicyResponse{}, &refusedError{fmt.Errorf("port policy %s already exists with the VLANs %v: %w",
+			request.DocumentSelfLink, portPolicy.AllowedVlans, createErr)}
+	}
+
+	log.Printf("adopted existing port policy %s", request.DocumentSelfLink)
+	return models.VLANPortPolicyResponse{
+		AutoRetry:         portPolicy.AutoRetry,
+		HeadShellReset:    portPolicy.HeadShellReset,
+		AllowedVlans:      portPolicy.AllowedVlans,
+		NativeVlanID:      portPolicy.NativeVlanID,
+		IsUntaggedAllowed: portPolicy.IsUntaggedAllowed,
+		DocumentVersion:   portPolicy.DocumentVersion,
+		DocumentKind:      portPolicy.DocumentKind,
+		DocumentSelfLink:  portPolicy.DocumentSelfLink,
+	}, nil
+}
@@ -0,0 +1,287 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package tapms
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+	"github.hpe.com/hpe/sshot-net-operator/internal/inventory"
+	"github.hpe.com/hpe/sshot-net-operator/internal/ipam"
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+func TestUnownedDocuments(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	tenant, sshotTenant := newTestTenants("x1000c2s0b0n0")
+	tenant.Finalizers = []string{Finalizer}
+	//a tenant reconciled after the one with unowned documents
+	otherTenant, otherSshotTenant := newTestTenants("x1000c2s1b0n0")
+	otherTenant.Name, otherTenant.Spec.TenantName = "vcluster-red", "vcluster-red"
+	otherSshotTenant.Name, otherSshotTenant.Spec.TenantName = "vcluster-red-slingshot", "vcluster-red"
+	otherSshotTenant.Spec.VNIPartition.VNIRange = []string{"3000-3009"}
+	k8sClient := newFakeClient(t, tenant, &sshotTenant, otherTenant, &otherSshotTenant)
+	inv := newInventory(k8sClient)
+
+	// documents that follow the operator naming but were created by someone else
+	_, err := fabric.VNIPartitions().Create(ctx, models.VNIRequestData{PartitionName: "vcluster-blue", VNICount: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fabric.VLANs().Create(ctx, models.VLANRequestData{VLANName: "vcluster-blue", VLANID: 5, Status: "ONLINE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vlanID, err := GetVlanID(ctx, fabric, inv, "vcluster-blue")
+	if err != nil || vlanID != 0 {
+		t.Errorf("expected the unowned VLAN to be ignored, got %d %v", vlanID, err)
+	}
+
+	r := &TenantReconciler{Client: k8sClient, Fabric: fabric, Inventory: inv}
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tenant)})
+	if err != nil {
+		t.Fatalf("expected the unowned VNI partition to be reported in the status only, got %v", err)
+	}
+	if _, ok := server.VNIPartition("vcluster-red"); !ok {
+		t.Error("expected the next tenant to be reconciled")
+	}
+
+	var updated slingshot.SlingshotTenant
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(&sshotTenant), &updated); err != nil {
+		t.Fatal(err)
+	}
+	partitionReady := meta.FindStatusCondition(updated.Status.Conditions, slingshot.ConditionPartitionReady)
+	if partitionReady == nil || partitionReady.Reason != ReasonNotOwned {
+		t.Errorf("expected PartitionReady to be NotOwned, got %+v", partitionReady)
+	}
+	degraded := meta.FindStatusCondition(updated.Status.Conditions, slingshot.ConditionDegraded)
+	if degraded == nil || degraded.Status != metav1.ConditionTrue || degraded.Reason != ReasonNotOwned {
+		t.Errorf("expected the slingshot tenant to be degraded, got %+v", degraded)
+	}
+
+	err = HandleDelete(ctx, fabric, inv, "vcluster-blue", "vcluster-blue-block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = TeardownTenant(ctx, k8sClient, fabric, inv, nil, nil, "vcluster-blue", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.VNIPartition("vcluster-blue"); !ok {
+		t.Error("unowned VNI partition was deleted")
+	}
+	if vlans := server.VLANs(); len(vlans) != 2 || vlans[1].VLANName != "vcluster-blue" {
+		t.Errorf("unowned VLAN was deleted: %+v", vlans)
+	}
+}
+
+func TestAdoptExisting(t *testing.T) {
+	_, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	tenant, sshotTenant := newTestTenants("x1000c2s0b0n0")
+	createTenantNetwork(t, fabric, newInventory(newFakeClient(t)), tenant, sshotTenant)
+
+	// a partition without a tenant is not adopted
+	_, err := fabric.VNIPartitions().Create(ctx, models.VNIRequestData{PartitionName: "vcluster-red", VNICount: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k8sClient := newFakeClient(t, tenant, &sshotTenant)
+	inv := inventory.New(k8sClient, "sshot-net-operator", "sshot-net-operator-inventory", AdoptExisting(k8sClient, fabric))
+	owned, err := inv.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []inventory.Entry{
+		{Kind: inventory.PortPolicy, Name: "vcluster-blue", Tenant: "vcluster-blue"},
+		{Kind: inventory.VLAN, Name: "1", Tenant: "vcluster-blue"},
+		{Kind: inventory.VNIBlock, Name: "vcluster-blue-block", Tenant: "vcluster-blue"},
+		{Kind: inventory.VNIPartition, Name: "vcluster-blue", Tenant: "vcluster-blue"},
+	}
+	if !reflect.DeepEqual(owned.Entries(), expected) {
+		t.Errorf("expected adopted entries %v, got %v", expected, owned.Entries())
+	}
+}
+
+func TestAdoptVNIAllocations(t *testing.T) {
+	_, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	k8sClient := newFakeClient(t)
+	inv := newInventory(k8sClient)
+	tenant, sshotTenant := newTestTenants("x1000c2s0b0n0")
+	createTenantNetwork(t, fabric, inv, tenant, sshotTenant)
+
+	// a partition that is not owned is not adopted
+	_, err := fabric.VNIPartitions().Create(ctx, models.VNIRequestData{PartitionName: "vcluster-red", VNIRange: []string{"3000-3009"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vnis := ipam.New(k8sClient, "sshot-net-operator", "sshot-net-operator-vni-allocations", nil, AdoptVNIAllocations(fabric, inv))
+	allocations, err := vnis.Allocations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{"vcluster-blue": {"2000-2009"}}
+	if !reflect.DeepEqual(allocations, expected) {
+		t.Errorf("expected adopted allocations %v, got %v", expected, allocations)
+	}
+}
+
+func TestCreateConflict(t *testing.T) {
+	_, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	inv := newInventory(newFakeClient(t))
+	tenant, sshotTenant := newTestTenants("x1000c2s0b0n0")
+
+	// documents created by a request whose response was lost are adopted
+	_, err := fabric.VNIPartitions().Create(ctx, models.VNIRequestData{PartitionName: "vcluster-blue", VNIRange: []string{"2000-2009"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = HandleCreate(ctx, fabric, inv, nil, tenant, sshotTenant)
+	if err != nil {
+		t.Fatalf("expected the existing VNI partition to be adopted, got %v", err)
+	}
+
+	vniBlock, err := CreateVNIBlock(ctx, fabric, inv, *tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adopted, err := CreateVNIBlock(ctx, fabric, inv, *tenant, sshotTenant)
+	if err != nil || adopted.EnforcementTaskServiceLink != vniBlock.EnforcementTaskServiceLink {
+		t.Errorf("expected the existing VNI block to be adopted, got %+v %v", adopted, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = createVlan(ctx, fabric, inv, 5, "vcluster-blue", slingshot.VLANSpec{})
+		if err != nil {
+			t.Fatalf("expected the existing VLAN to be adopted, got %v", err)
+		}
+		portPolicy, err := CreateVLANPortPolicy(ctx, fabric, inv, 5, "vcluster-blue", slingshot.VLANSpec{})
+		if err != nil || portPolicy.DocumentSelfLink != "/fabric/port-policies/vcluster-blue" {
+			t.Fatalf("expected the existing port policy to be adopted, got %+v %v", portPolicy, err)
+		}
+	}
+
+	// documents that are not the ones requested are reported as conflicts
+	_, err = createVlan(ctx, fabric, inv, 5, "vcluster-red", slingshot.VLANSpec{})
+	if !httpclient.IsConflict(err) || !strings.Contains(err.Error(), "already exists with the name vcluster-blue") {
+		t.Errorf("expected the VLAN of another tenant not to be adopted, got %v", err)
+	}
+	sshotTenant.Spec.VNIPartition.VNIRange = []string{"3000-3009"}
+	err = HandleCreate(ctx, fabric, inv, nil, tenant, sshotTenant)
+	if !httpclient.IsConflict(err) || !strings.Contains(err.Error(), "already exists with the VNI ranges") {
+		t.Errorf("expected the VNI partition with other VNIs not to be adopted, got %v", err)
+	}
+}
+
+func TestCreateFailureForgotten(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	inv := newInventory(newFakeClient(t))
+
+	// a port policy that exists with another VLAN is not adopted nor recorded
+	_, err := fabric.PortPolicies().Create(ctx, NewVLANPortPolicyRequest(7, "vcluster-blue", slingshot.VLANSpec{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = CreateVLANPortPolicy(ctx, fabric, inv, 5, "vcluster-blue", slingshot.VLANSpec{})
+	if !httpclient.IsConflict(err) || !NotCreated(err) {
+		t.Fatalf("expected the foreign port policy not to be adopted, got %v", err)
+	}
+	owns, err := inv.Owns(ctx, inventory.PortPolicy, "vcluster-blue")
+	if err != nil || owns {
+		t.Errorf("expected the foreign port policy not to be owned, got %t %v", owns, err)
+	}
+
+	// a rejected request is forgotten
+	server.FailNext(http.MethodPost, "/fabric/vlans", http.StatusBadRequest)
+	_, err = createVlan(ctx, fabric, inv, 5, "vcluster-blue", slingshot.VLANSpec{})
+	if err == nil {
+		t.Fatal("expected the VLAN creation to fail")
+	}
+	owned, err := inv.Load(ctx)
+	if err != nil || owned.VLAN(5) {
+		t.Errorf("expected the rejected VLAN not to be owned, got %t %v", owned.VLAN(5), err)
+	}
+
+	// a request that may have created the document keeps it recorded
+	server.FailNext(http.MethodPost, "/fabric/vlans", http.StatusBadGateway)
+	_, err = createVlan(ctx, fabric, inv, 5, "vcluster-blue", slingshot.VLANSpec{})
+	if err == nil || NotCreated(err) {
+		t.Fatalf("expected the VLAN creation to fail without a definite answer, got %v", err)
+	}
+	owned, err = inv.Load(ctx)
+	if err != nil || !owned.VLAN(5) {
+		t.Errorf("expected the VLAN to stay owned, got %t %v", owned.VLAN(5), err)
+	}
+
+	// a document of another tenant keeps its owner
+	_, err = createVlan(ctx, fabric, inv, 5, "vcluster-blue", slingshot.VLANSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = createVlan(ctx, fabric, inv, 5, "vcluster-red", slingshot.VLANSpec{})
+	if !NotCreated(err) {
+		t.Fatalf("expected the VLAN of another tenant not to be adopted, got %v", err)
+	}
+	owned, err = inv.Load(ctx)
+	if tenant, _ := owned.Tenant(inventory.VLAN, "5"); err != nil || tenant != "vcluster-blue" {
+		t.Errorf("expected the VLAN to stay owned by vcluster-blue, got %q %v", tenant, err)
+	}
+}
+
+func TestDeleteNotFound(t *testing.T) {
+	_, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	inv := newInventory(newFakeClient(t))
+
+	// documents recorded in the inventory but already deleted from Fabric Manager
+	for _, entry := range []inventory.Entry{
+		{Kind: inventory.VNIBlock, Name: "vcluster-blue-block", Tenant: "vcluster-blue"},
+		{Kind: inventory.VNIPartition, Name: "vcluster-blue", Tenant: "vcluster-blue"},
+	} {
+		err := inv.Record(ctx, entry.Kind, entry.Name, entry.Tenant)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := inv.RecordVLAN(ctx, 5, "vcluster-blue")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = HandleDelete(ctx, fabric, inv, "vcluster-blue", "vcluster-blue-block")
+	if err != nil {
+		t.Fatalf("expected the missing VNI block and partition to be deleted, got %v", err)
+	}
+	err = DeleteVLAN(ctx, fabric, inv, "vcluster-blue", 5)
+	if err != nil {
+		t.Fatalf("expected the missing VLAN to be deleted, got %v", err)
+	}
+
+	owned, err := inv.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(owned.Entries()) != 0 {
+		t.Errorf("expected the deleted documents to be forgotten, got %+v", owned.Entries())
+	}
+}
@@ -0,0 +1,217 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package tapms
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+	"github.hpe.com/hpe/sshot-net-operator/fm"
+	"github.hpe.com/hpe/sshot-net-operator/internal/inventory"
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+// Stages of a VNI block enforcement task that is over
+const (
+	// EnforcementStageFinished is the stage of an enforcement task that succeeded
+	EnforcementStageFinished = "FINISHED"
+
+	// EnforcementStageFailed is the stage of an enforcement task that failed
+	EnforcementStageFailed = "FAILED"
+)
+
+const (
+	// minEnforcementPoll is the shortest interval at which a running enforcement task is polled
+	minEnforcementPoll = time.Second
+
+	// maxEnforcementPoll is the longest interval at which a running enforcement task is polled
+	maxEnforcementPoll = 30 * time.Second
+)
+
+// TrackEnforcement follows the enforcement task of the VNI block of a
+// slingshot tenant without waiting for it, and records the task in the status
+// of the slingshot tenant. It returns how long to wait before the task is
+// polled again, or zero when the task is over. A task that fails, or does not
+// finish within timeout, is started again up to retries times by patching the
+// VNI block with its own range and edge ports. A zero timeout never expires
+func TrackEnforcement(ctx context.Context, c client.Client, fabric *fm.Client, inv *inventory.Inventory, sshotTenant *slingshot.SlingshotTenant,
+	timeout time.Duration, retries int) (time.Duration, error) {
+	vniBlockName := fmt.Sprintf("%s-%s", sshotTenant.Spec.TenantName, sshotTenant.Spec.VNIBlockName)
+
+	//Never enforce again a VNI block that was not created by the operator
+	owns, err := inv.Owns(ctx, inventory.VNIBlock, vniBlockName)
+	if err != nil {
+		log.Printf("cannot load inventory: %+v", err)
+		return 0, err
+	}
+	if !owns {
+		return 0, nil
+	}
+
+	vniBlock, err := fabric.VNIBlocks().Get(ctx, vniBlockName)
+	if err != nil {
+		log.Printf("cannot get VNI block: %+v", err)
+		return 0, err
+	}
+	if vniBlock.EnforcementTaskServiceLink == "" {
+		return 0, nil
+	}
+
+	state, err := fabric.VNIBlocks().EnforcementTask(ctx, vniBlock.EnforcementTaskServiceLink)
+	if err != nil {
+		log.Printf("cannot get VNI block enforcement task: %+v", err)
+		return 0, err
+	}
+
+	original := sshotTenant.DeepCopy()
+	status := &sshotTenant.Status
+
+	//the reason of the condition tells what was already reported for this task
+	var reported string
+	if condition := meta.FindStatusCondition(status.Conditions, slingshot.ConditionEnforcementComplete); condition != nil &&
+		status.EnforcementTaskLink == vniBlock.EnforcementTaskServiceLink {
+		reported = condition.Reason
+	}
+
+	now := time.Now()
+	setEnforcementState(status, vniBlock.EnforcementTaskServiceLink, state)
+	if status.EnforcementDeadline == nil && timeout > 0 {
+		status.EnforcementDeadline = &metav1.Time{Time: now.Add(timeout)}
+	}
+
+	var requeue time.Duration
+	switch {
+	case state.TaskInfo.Stage == EnforcementStageFinished:
+		status.EnforcementDeadline = nil
+		status.EnforcementRetries = 0
+		if status.FirstEnforcedTime == nil {
+			status.FirstEnforcedTime = &metav1.Time{Time: now}
+		}
+		if reported != ReasonEnforcementFinished {
+			log.Printf("enforcement for VNI block %s is completed", vniBlock.DocumentSelfLink)
+			NormalEvent(ctx, EventEnforcementFinished, "VNI block %s enforcement finished: %d VNIs added, %d VNIs removed",
+				vniBlockName, len(state.AddVniList), len(state.RemoveVniList))
+		}
+	case state.TaskInfo.Stage == EnforcementStageFailed || enforcementExpired(status, now):
+		reason, event := ReasonEnforcementFailed, EventEnforcementFailed
+		message := fmt.Sprintf("VNI block %s enforcement failed in sub-stage %s", vniBlockName, state.SubStage)
+		if state.TaskInfo.Stage != EnforcementStageFailed {
+			reason, event = ReasonEnforcementTimedOut, EventEnforcementTimedOut
+			message = fmt.Sprintf("VNI block %s enforcement did not finish within %s, it is in stage %s, sub-stage %s",
+				vniBlockName, timeout, state.TaskInfo.Stage, state.SubStage)
+		}
+		log.Print(message)
+
+		if status.EnforcementRetries >= retries {
+			if reported != reason {
+				recordEnforcementFailure(sshotTenant.Spec.TenantName)
+				WarningEvent(ctx, event, "%s, giving up after %d retries", message, status.EnforcementRetries)
+			}
+			break
+		}
+		recordEnforcementFailure(sshotTenant.Spec.TenantName)
+		WarningEvent(ctx, event, "%s, retrying", message)
+
+		link, err := retryEnforcement(ctx, fabric, vniBlockName, vniBlock)
+		if err != nil {
+			return 0, err
+		}
+		setEnforcementState(status, link, models.VniBlockEnforcementTaskServiceState{})
+		status.EnforcementRetries = original.Status.EnforcementRetries + 1
+		if timeout > 0 {
+			status.EnforcementDeadline = &metav1.Time{Time: now.Add(timeout)}
+		}
+		NormalEvent(ctx, EventEnforcementRetried, "VNI block %s enforcement started again as task %s, retry %d of %d",
+			vniBlockName, link, status.EnforcementRetries, retries)
+		requeue = minEnforcementPoll
+	default:
+		requeue = enforcementPollInterval(status, now, timeout)
+	}
+
+	if equality.Semantic.DeepEqual(original.Status, sshotTenant.Status) {
+		return requeue, nil
+	}
+
+	err = c.Status().Patch(ctx, sshotTenant, client.MergeFrom(original))
+	if err != nil {
+		return 0, fmt.Errorf("cannot record enforcement task of slingshot tenant %s: %w", sshotTenant.Name, err)
+	}
+
+	return requeue, nil
+}
+
+// setEnforcementState sets the enforcement fields of a status from the state
+// of an enforcement task. A new task gets a new deadline and its retries are
+// counted again
+func setEnforcementState(status *slingshot.SlingshotTenantStatus, link string, state models.VniBlockEnforcementTaskServiceState) {
+	if status.EnforcementTaskLink != link {
+		status.EnforcementTaskLink = link
+		status.EnforcementDeadline = nil
+		status.EnforcementRetries = 0
+	}
+	status.EnforcementStage = state.TaskInfo.Stage
+	status.EnforcementSubStage = state.SubStage
+	status.EnforcementAddVNIs = state.AddVniList
+	status.EnforcementRemoveVNIs = state.RemoveVniList
+}
+
+// clearEnforcementState clears the enforcement fields of a status
+func clearEnforcementState(status *slingshot.SlingshotTenantStatus) {
+	setEnforcementState(status, "", models.VniBlockEnforcementTaskServiceState{})
+}
+
+// enforcementExpired checks if the enforcement task of a status is past its deadline
+func enforcementExpired(status *slingshot.SlingshotTenantStatus, now time.Time) bool {
+	return status.EnforcementDeadline != nil && now.After(status.EnforcementDeadline.Time)
+}
+
+// enforcementPollInterval returns how long to wait before a running
+// enforcement task is polled again. The interval grows with the time the task
+// has been running, from minEnforcementPoll to maxEnforcementPoll, and does
+// not go past the deadline of the task
+func enforcementPollInterval(status *slingshot.SlingshotTenantStatus, now time.Time, timeout time.Duration) time.Duration {
+	if status.EnforcementDeadline == nil {
+		return maxEnforcementPoll
+	}
+
+	remaining := status.EnforcementDeadline.Sub(now)
+	interval := timeout - remaining
+	if interval > maxEnforcementPoll {
+		interval = maxEnforcementPoll
+	}
+	if interval > remaining {
+		interval = remaining
+	}
+	if interval < minEnforcementPoll {
+		interval = minEnforcementPoll
+	}
+
+	return interval
+}
+
+// retryEnforcement starts the enforcement of a VNI block again by patching it
+// with its own range and edge ports, and returns the link of the new task
+func retryEnforcement(ctx context.Context, fabric *fm.Client, vniBlockName string, vniBlock models.VNIBlockResponse) (string, error) {
+	vniBlock, err := fabric.VNIBlocks().Patch(ctx, vniBlockName, models.VNIBlockPatchRequest{
+		VNIBlockRange: vniBlock.VNIBlockRange,
+		PortDFAs:      vniBlock.PortDFAs,
+	})
+	if err != nil {
+		log.Printf("cannot enforce VNI block again: %+v", err)
+		WarningEvent(ctx, EventVNIBlockPatchFailed, "cannot update VNI block %s to enforce it again: %v", vniBlockName, err)
+		return "", err
+	}
+	log.Printf("enforcement for VNI block %s started again as task %s", vniBlock.DocumentSelfLink, vniBlock.EnforcementTaskServiceLink)
+
+	return vniBlock.EnforcementTaskServiceLink, nil
+}
@@ -0,0 +1,246 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package tapms
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+)
+
+// events returns the events recorded so far, as "type reason message"
+func events(recorder *record.FakeRecorder) []string {
+	var recorded []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			recorded = append(recorded, event)
+		default:
+			return recorded
+		}
+	}
+}
+
+// expectEvents checks that the events recorded so far have the reasons
+func expectEvents(t *testing.T, recorder *record.FakeRecorder, reasons ...string) {
+	t.Helper()
+
+	recorded := events(recorder)
+	if len(recorded) != len(reasons) {
+		t.Fatalf("expected events %v, got %v", reasons, recorded)
+	}
+	for i, reason := range reasons {
+		if strings.Fields(recorded[i])[1] != reason {
+			t.Errorf("expected event %s, got %s", reason, recorded[i])
+		}
+	}
+}
+
+func TestTrackEnforcement(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	server.EnforcementStage = "RUNNING"
+	ctx := context.Background()
+	tenant, sshotTenant := newTestTenants("x1000c2s0b0n0")
+	k8sClient := newFakeClient(t, &sshotTenant)
+	inv := newInventory(k8sClient)
+	recorder := record.NewFakeRecorder(10)
+
+	err := HandleCreate(ctx, fabric, inv, nil, tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vniBlock, err := CreateVNIBlock(ctx, fabric, inv, *tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = WithEvents(ctx, recorder, &sshotTenant)
+
+	// a running task is recorded and polled again soon
+	requeue, err := TrackEnforcement(ctx, k8sClient, fabric, inv, &sshotTenant, 10*time.Minute, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requeue != minEnforcementPoll {
+		t.Errorf("expected the task to be polled in %s, got %s", minEnforcementPoll, requeue)
+	}
+
+	var updated slingshot.SlingshotTenant
+	err = k8sClient.Get(ctx, client.ObjectKeyFromObject(&sshotTenant), &updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := updated.Status
+	if status.EnforcementTaskLink != vniBlock.EnforcementTaskServiceLink || status.EnforcementStage != "RUNNING" ||
+		status.EnforcementSubStage != "ENFORCE" || len(status.EnforcementAddVNIs) != 10 || status.EnforcementDeadline == nil {
+		t.Errorf("unexpected status %+v", status)
+	}
+	expectEvents(t, recorder)
+
+	// a failed task is started again
+	err = server.SetEnforcementStage(vniBlock.EnforcementTaskServiceLink, "FAILED")
+	if err != nil {
+		t.Fatal(err)
+	}
+	requeue, err = TrackEnforcement(ctx, k8sClient, fabric, inv, &sshotTenant, 10*time.Minute, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requeue != minEnforcementPoll {
+		t.Errorf("expected the new task to be polled in %s, got %s", minEnforcementPoll, requeue)
+	}
+	retried := sshotTenant.Status.EnforcementTaskLink
+	if retried == vniBlock.EnforcementTaskServiceLink || sshotTenant.Status.EnforcementRetries != 1 {
+		t.Errorf("expected the enforcement to be retried, got %+v", sshotTenant.Status)
+	}
+	expectEvents(t, recorder, EventEnforcementFailed, EventEnforcementRetried)
+
+	// once the retries are exhausted, the failure is only reported once
+	err = server.SetEnforcementStage(retried, "FAILED")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		requeue, err = TrackEnforcement(ctx, k8sClient, fabric, inv, &sshotTenant, 10*time.Minute, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if requeue != 0 || sshotTenant.Status.EnforcementTaskLink != retried {
+			t.Errorf("expected the enforcement not to be retried, got %s %+v", requeue, sshotTenant.Status)
+		}
+		err = UpdateStatus(ctx, k8sClient, fabric, inv, &sshotTenant, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectEvents(t, recorder, EventEnforcementFailed)
+	if failures := testutil.ToFloat64(enforcementFailures.WithLabelValues("vcluster-blue")); failures != 2 {
+		t.Errorf("expected 2 enforcement failures, got %v", failures)
+	}
+	condition := meta.FindStatusCondition(sshotTenant.Status.Conditions, slingshot.ConditionEnforcementComplete)
+	if condition == nil || condition.Reason != ReasonEnforcementFailed || !strings.Contains(condition.Message, "after 1 retries") {
+		t.Errorf("expected EnforcementComplete to be failed, got %+v", condition)
+	}
+
+	// a finished task resets the retries
+	err = server.SetEnforcementStage(retried, "FINISHED")
+	if err != nil {
+		t.Fatal(err)
+	}
+	requeue, err = TrackEnforcement(ctx, k8sClient, fabric, inv, &sshotTenant, 10*time.Minute, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requeue != 0 || sshotTenant.Status.EnforcementRetries != 0 || sshotTenant.Status.EnforcementDeadline != nil {
+		t.Errorf("expected the enforcement to be over, got %s %+v", requeue, sshotTenant.Status)
+	}
+	expectEvents(t, recorder, EventEnforcementFinished)
+	firstEnforced := sshotTenant.Status.FirstEnforcedTime
+	if firstEnforced == nil {
+		t.Fatal("expected the first enforcement time to be recorded")
+	}
+
+	// the first enforcement time is kept
+	_, err = TrackEnforcement(ctx, k8sClient, fabric, inv, &sshotTenant, 10*time.Minute, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = k8sClient.Get(ctx, client.ObjectKeyFromObject(&sshotTenant), &updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.FirstEnforcedTime == nil || !updated.Status.FirstEnforcedTime.Equal(firstEnforced) {
+		t.Errorf("expected the first enforcement time %s, got %v", firstEnforced, updated.Status.FirstEnforcedTime)
+	}
+}
+
+func TestEnforcementTimeout(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	server.EnforcementStage = "RUNNING"
+	ctx := context.Background()
+	tenant, sshotTenant := newTestTenants("x1000c2s0b0n0")
+	k8sClient := newFakeClient(t, &sshotTenant)
+	inv := newInventory(k8sClient)
+	recorder := record.NewFakeRecorder(10)
+
+	err := HandleCreate(ctx, fabric, inv, nil, tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vniBlock, err := CreateVNIBlock(ctx, fabric, inv, *tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = WithEvents(ctx, recorder, &sshotTenant)
+
+	_, err = TrackEnforcement(ctx, k8sClient, fabric, inv, &sshotTenant, time.Nanosecond, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+
+	// without retries, a hung task is reported as timed out
+	requeue, err := TrackEnforcement(ctx, k8sClient, fabric, inv, &sshotTenant, time.Nanosecond, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requeue != 0 || sshotTenant.Status.EnforcementTaskLink != vniBlock.EnforcementTaskServiceLink {
+		t.Errorf("expected the enforcement not to be retried, got %s %+v", requeue, sshotTenant.Status)
+	}
+	expectEvents(t, recorder, EventEnforcementTimedOut)
+
+	err = UpdateStatus(ctx, k8sClient, fabric, inv, &sshotTenant, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(sshotTenant.Status.Conditions, slingshot.ConditionEnforcementComplete)
+	if condition == nil || condition.Status != metav1.ConditionFalse || condition.Reason != ReasonEnforcementTimedOut ||
+		!strings.Contains(condition.Message, "sub-stage ENFORCE, adding 10 VNIs and removing 0 VNIs") {
+		t.Errorf("expected EnforcementComplete to be timed out, got %+v", condition)
+	}
+
+	// with retries, it is started again
+	_, err = TrackEnforcement(ctx, k8sClient, fabric, inv, &sshotTenant, time.Nanosecond, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sshotTenant.Status.EnforcementTaskLink == vniBlock.EnforcementTaskServiceLink {
+		t.Errorf("expected the enforcement to be retried, got %+v", sshotTenant.Status)
+	}
+	expectEvents(t, recorder, EventEnforcementTimedOut, EventEnforcementRetried)
+}
+
+func TestEnforcementPollInterval(t *testing.T) {
+	now := time.Now()
+	timeout := 10 * time.Minute
+	tests := []struct {
+		name     string
+		deadline *metav1.Time
+		expected time.Duration
+	}{
+		{name: "just started", deadline: &metav1.Time{Time: now.Add(timeout)}, expected: minEnforcementPoll},
+		{name: "backing off", deadline: &metav1.Time{Time: now.Add(timeout - 5*time.Second)}, expected: 5 * time.Second},
+		{name: "long running", deadline: &metav1.Time{Time: now.Add(5 * time.Minute)}, expected: maxEnforcementPoll},
+		{name: "close to the deadline", deadline: &metav1.Time{Time: now.Add(3 * time.Second)}, expected: 3 * time.Second},
+		{name: "no deadline", expected: maxEnforcementPoll},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &slingshot.SlingshotTenantStatus{EnforcementDeadline: tt.deadline}
+			if interval := enforcementPollInterval(status, now, timeout); interval != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, interval)
+			}
+		})
+	}
+}
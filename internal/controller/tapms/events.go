@@ -0,0 +1,151 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package tapms
+
+import (
+	"context"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+)
+
+// Event reasons recorded on a Tenant and its SlingshotTenant. They are part
+// of the API of the operator: alerts and scripts may match them, so they must
+// not change. Each fabric operation has a Normal reason for its success and a
+// Warning reason for its failure
+const (
+	// EventVNIPartitionCreated is recorded when the VNI partition is created
+	EventVNIPartitionCreated = "VNIPartitionCreated"
+
+	// EventVNIPartitionCreateFailed is recorded when the VNI partition cannot be created
+	EventVNIPartitionCreateFailed = "VNIPartitionCreateFailed"
+
+	// EventVNIPartitionUpdated is recorded when the edge ports of the VNI partition are updated
+	EventVNIPartitionUpdated = "VNIPartitionUpdated"
+
+	// EventVNIPartitionUpdateFailed is recorded when the VNI partition cannot be updated
+	EventVNIPartitionUpdateFailed = "VNIPartitionUpdateFailed"
+
+	// EventVNIPartitionDeleted is recorded when the VNI partition is deleted
+	EventVNIPartitionDeleted = "VNIPartitionDeleted"
+
+	// EventVNIPartitionDeleteFailed is recorded when the VNI partition cannot be deleted
+	EventVNIPartitionDeleteFailed = "VNIPartitionDeleteFailed"
+
+	// EventVLANCreated is recorded when the VLAN is created
+	EventVLANCreated = "VLANCreated"
+
+	// EventVLANCreateFailed is recorded when the VLAN or its port policy cannot be created
+	EventVLANCreateFailed = "VLANCreateFailed"
+
+	// EventVLANUpdated is recorded when the VLAN or its port policy is updated to match the VLAN spec
+	EventVLANUpdated = "VLANUpdated"
+
+	// EventVLANUpdateFailed is recorded when the VLAN or its port policy cannot be updated
+	EventVLANUpdateFailed = "VLANUpdateFailed"
+
+	// EventVLANDeleted is recorded when the VLAN is deleted
+	EventVLANDeleted = "VLANDeleted"
+
+	// EventVLANDeleteFailed is recorded when the VLAN or its port policy cannot be deleted
+	EventVLANDeleteFailed = "VLANDeleteFailed"
+
+	// EventPortPolicyApplied is recorded when the port policy is applied to edge ports
+	EventPortPolicyApplied = "PortPolicyApplied"
+
+	// EventPortPolicyApplyFailed is recorded when the port policy cannot be applied to edge ports
+	EventPortPolicyApplyFailed = "PortPolicyApplyFailed"
+
+	// EventPortPolicyRemoved is recorded when the port policy is removed from an edge port
+	EventPortPolicyRemoved = "PortPolicyRemoved"
+
+	// EventPortPolicyRemoveFailed is recorded when the port policy cannot be removed from an edge port
+	EventPortPolicyRemoveFailed = "PortPolicyRemoveFailed"
+
+	// EventVNIBlockCreated is recorded when the VNI block is created
+	EventVNIBlockCreated = "VNIBlockCreated"
+
+	// EventVNIBlockCreateFailed is recorded when the VNI block cannot be created
+	EventVNIBlockCreateFailed = "VNIBlockCreateFailed"
+
+	// EventVNIBlockPatched is recorded when the VNI ranges or edge ports of the VNI block are updated
+	EventVNIBlockPatched = "VNIBlockPatched"
+
+	// EventVNIBlockPatchFailed is recorded when the VNI block cannot be updated
+	EventVNIBlockPatchFailed = "VNIBlockPatchFailed"
+
+	// EventVNIBlockDeleted is recorded when the VNI block is deleted
+	EventVNIBlockDeleted = "VNIBlockDeleted"
+
+	// EventVNIBlockDeleteFailed is recorded when the VNI block cannot be deleted
+	EventVNIBlockDeleteFailed = "VNIBlockDeleteFailed"
+
+	// EventEnforcementFinished is recorded when a VNI block enforcement task finishes
+	EventEnforcementFinished = "EnforcementFinished"
+
+	// EventEnforcementFailed is recorded when a VNI block enforcement task fails
+	EventEnforcementFailed = "EnforcementFailed"
+
+	// EventEnforcementTimedOut is recorded when a VNI block enforcement task does not finish in time
+	EventEnforcementTimedOut = "EnforcementTimedOut"
+
+	// EventEnforcementRetried is recorded when the VNI block enforcement is started again
+	EventEnforcementRetried = "EnforcementRetried"
+
+	// EventTokenFailed is recorded when no access token can be obtained for Fabric Manager
+	EventTokenFailed = "TokenFailed"
+)
+
+type eventTargetKey struct{}
+
+// eventTarget is the recorder and the objects the events of a context are recorded on
+type eventTarget struct {
+	recorder record.EventRecorder
+	objects  []runtime.Object
+}
+
+// WithEvents returns a context with which the fabric operations record their
+// events on objects, the Tenant and SlingshotTenant of a tenant. Nothing is
+// recorded without a recorder, or with a context that was not set up
+func WithEvents(ctx context.Context, recorder record.EventRecorder, objects ...runtime.Object) context.Context {
+	if recorder == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, eventTargetKey{}, &eventTarget{recorder: recorder, objects: objects})
+}
+
+// RecordTokenFailure records a TokenFailed event when err was caused by the
+// access token for Fabric Manager
+func RecordTokenFailure(ctx context.Context, err error) {
+	if httpclient.IsTokenError(err) {
+		WarningEvent(ctx, EventTokenFailed, "cannot get an access token for Fabric Manager: %v", err)
+	}
+}
+
+// recordEvent records an event on the objects of the context
+func recordEvent(ctx context.Context, eventType string, reason string, messageFmt string, args ...interface{}) {
+	target, ok := ctx.Value(eventTargetKey{}).(*eventTarget)
+	if !ok {
+		return
+	}
+
+	for _, obj := range target.objects {
+		target.recorder.Eventf(obj, eventType, reason, messageFmt, args...)
+	}
+}
+
+// NormalEvent records a normal event on the objects of the context
+func NormalEvent(ctx context.Context, reason string, messageFmt string, args ...interface{}) {
+	recordEvent(ctx, core.EventTypeNormal, reason, messageFmt, args...)
+}
+
+// WarningEvent records a warning event on the objects of the context
+func WarningEvent(ctx context.Context, reason string, messageFmt string, args ...interface{}) {
+	recordEvent(ctx, core.EventTypeWarning, reason, messageFmt, args...)
+}
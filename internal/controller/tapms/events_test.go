@@ -0,0 +1,79 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package tapms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"k8s.io/client-go/tools/record"
+
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+)
+
+func TestFabricEvents(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	tenant, sshotTenant := newTestTenants("x1000c2s0b0n0")
+	k8sClient := newFakeClient(t, &sshotTenant)
+	inv := newInventory(k8sClient)
+	recorder := record.NewFakeRecorder(20)
+	ctx := WithEvents(context.Background(), recorder, &sshotTenant)
+
+	err := HandleCreate(ctx, fabric, inv, nil, tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEvents(t, recorder, EventVNIPartitionCreated)
+
+	_, err = CreateVNIBlock(ctx, fabric, inv, *tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEvents(t, recorder, EventVNIBlockCreated)
+
+	// a failed step records a warning
+	server.FailNext("DELETE", "/fabric/vni/partitions/vcluster-blue", http.StatusInternalServerError)
+	err = TeardownTenant(ctx, k8sClient, fabric, inv, nil, nil, "vcluster-blue", &sshotTenant)
+	if err == nil {
+		t.Fatal("expected the teardown to fail")
+	}
+	expectEvents(t, recorder, EventVNIBlockDeleted, EventVNIPartitionDeleteFailed)
+
+	err = TeardownTenant(ctx, k8sClient, fabric, inv, nil, nil, "vcluster-blue", &sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEvents(t, recorder, EventVNIPartitionDeleted)
+}
+
+func TestEventsOnEveryObject(t *testing.T) {
+	tenant, sshotTenant := newTestTenants("x1000c2s0b0n0")
+	recorder := record.NewFakeRecorder(10)
+	ctx := WithEvents(context.Background(), recorder, tenant, &sshotTenant)
+
+	NormalEvent(ctx, EventVLANCreated, "created VLAN %d", 100)
+	expectEvents(t, recorder, EventVLANCreated, EventVLANCreated)
+
+	// nothing is recorded without a recorder
+	NormalEvent(WithEvents(context.Background(), nil, tenant), EventVLANCreated, "created VLAN %d", 100)
+	NormalEvent(context.Background(), EventVLANCreated, "created VLAN %d", 100)
+	expectEvents(t, recorder)
+}
+
+func TestRecordTokenFailure(t *testing.T) {
+	_, sshotTenant := newTestTenants("x1000c2s0b0n0")
+	recorder := record.NewFakeRecorder(10)
+	ctx := WithEvents(context.Background(), recorder, &sshotTenant)
+
+	RecordTokenFailure(ctx, errors.New("connection refused"))
+	expectEvents(t, recorder)
+
+	err := fmt.Errorf("cannot get VNI partition: %w", &httpclient.TokenError{Err: errors.New("invalid client secret")})
+	RecordTokenFailure(ctx, err)
+	expectEvents(t, recorder, EventTokenFailed)
+}
@@ -0,0 +1,157 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package tapms
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+	tapms "github.hpe.com/hpe/sshot-net-operator/api/tapms/v1alpha2"
+	"github.hpe.com/hpe/sshot-net-operator/fm"
+)
+
+const (
+	// metricsNamespace prefixes the names of the metrics of the operator
+	metricsNamespace = "sshot_net_operator"
+
+	// stateReady is the state label of the tenants whose resource is ready
+	stateReady = "ready"
+
+	// statePending is the state label of the tenants whose resource is not ready yet
+	statePending = "pending"
+)
+
+// readinessConditions maps the resource label of the tenants gauge to the condition reporting it
+var readinessConditions = map[string]string{
+	"vni_partition": slingshot.ConditionPartitionReady,
+	"vni_block":     slingshot.ConditionVNIBlockReady,
+	"vlan":          slingshot.ConditionVLANReady,
+	"enforcement":   slingshot.ConditionEnforcementComplete,
+}
+
+var (
+	tenantsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "tenants",
+		Help:      "Number of slingshot tenants whose VNI partition, VNI block, VLAN or enforcement is ready or pending.",
+	}, []string{"resource", "state"})
+
+	enforcementDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "tenant_enforcement_duration_seconds",
+		Help:      "Time from the creation of a Tenant to the first VNI block enforcement task of the tenant that finished.",
+		Buckets:   prometheus.ExponentialBuckets(10, 2, 10),
+	})
+
+	enforcementFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "enforcement_failures_total",
+		Help:      "Number of VNI block enforcement tasks that failed or timed out, by tenant.",
+	}, []string{"tenant"})
+
+	unresolvedXnamesGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "unresolved_xnames",
+		Help:      "Number of xnames of a tenant that have no edge port in Fabric Manager, by tenant.",
+	}, []string{"tenant"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(tenantsGauge, enforcementDuration, enforcementFailures, unresolvedXnamesGauge)
+}
+
+// tenantMetrics is the state of the tenants the metrics are computed from.
+// Both reconcilers update it, so it is guarded by a mutex
+var tenantMetrics = struct {
+	mu sync.Mutex
+
+	// ready tells, for each tenant, which resources are ready
+	ready map[string]map[string]bool
+}{
+	ready: make(map[string]map[string]bool),
+}
+
+// recordTenantReadiness records the readiness conditions of a slingshot tenant
+func recordTenantReadiness(sshotTenant *slingshot.SlingshotTenant) {
+	ready := make(map[string]bool, len(readinessConditions))
+	for resource, conditionType := range readinessConditions {
+		ready[resource] = meta.IsStatusConditionTrue(sshotTenant.Status.Conditions, conditionType)
+	}
+
+	tenantMetrics.mu.Lock()
+	defer tenantMetrics.mu.Unlock()
+
+	tenantMetrics.ready[sshotTenant.Spec.TenantName] = ready
+	updateTenantsGauge()
+}
+
+// updateTenantsGauge counts the tenants whose resources are ready or pending.
+// It must be called with the mutex of tenantMetrics held
+func updateTenantsGauge() {
+	for resource := range readinessConditions {
+		var ready, pending int
+		for _, tenant := range tenantMetrics.ready {
+			if tenant[resource] {
+				ready++
+			} else {
+				pending++
+			}
+		}
+		tenantsGauge.WithLabelValues(resource, stateReady).Set(float64(ready))
+		tenantsGauge.WithLabelValues(resource, statePending).Set(float64(pending))
+	}
+}
+
+// recordEnforcementFinished observes the time from the creation of a tenant
+// to the end of its first enforcement
+func recordEnforcementFinished(tenant *tapms.Tenant, finished time.Time) {
+	enforcementDuration.Observe(finished.Sub(tenant.CreationTimestamp.Time).Seconds())
+}
+
+// recordEnforcementFailure counts a failed or timed out enforcement task of a tenant
+func recordEnforcementFailure(tenantName string) {
+	enforcementFailures.WithLabelValues(tenantName).Inc()
+}
+
+// recordUnresolvedXnames records the number of xnames of a tenant that have
+// no edge port in Fabric Manager
+func recordUnresolvedXnames(ctx context.Context, fabric *fm.Client, tenantName string, xnames []string) {
+	edgePorts, err := fabric.Topology().EdgePorts(ctx, xnames)
+	if err != nil && edgePorts == nil {
+		log.Printf("cannot get edge ports of tenant %s: %+v", tenantName, err)
+		return
+	}
+
+	resolved := make(map[string]bool, len(edgePorts))
+	for _, edgePort := range edgePorts {
+		resolved[edgePort.Xname] = true
+	}
+	unresolved := make(map[string]bool)
+	for _, xname := range xnames {
+		if !resolved[xname] {
+			unresolved[xname] = true
+		}
+	}
+
+	unresolvedXnamesGauge.WithLabelValues(tenantName).Set(float64(len(unresolved)))
+}
+
+// forgetTenantMetrics removes a deleted tenant from the metrics
+func forgetTenantMetrics(tenantName string) {
+	tenantMetrics.mu.Lock()
+	defer tenantMetrics.mu.Unlock()
+
+	delete(tenantMetrics.ready, tenantName)
+	updateTenantsGauge()
+	enforcementFailures.DeleteLabelValues(tenantName)
+	unresolvedXnamesGauge.DeleteLabelValues(tenantName)
+}
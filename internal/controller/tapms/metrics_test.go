@@ -0,0 +1,88 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package tapms
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// resetTenantMetrics forgets the tenants recorded by the previous tests
+func resetTenantMetrics() {
+	tenantMetrics.mu.Lock()
+	defer tenantMetrics.mu.Unlock()
+
+	tenantMetrics.ready = make(map[string]map[string]bool)
+	updateTenantsGauge()
+	enforcementFailures.Reset()
+	unresolvedXnamesGauge.Reset()
+}
+
+// enforcementObservations counts the enforcement durations observed so far
+func enforcementObservations(t *testing.T) uint64 {
+	var metric dto.Metric
+	err := enforcementDuration.Write(&metric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestTenantMetrics(t *testing.T) {
+	_, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	tenant, sshotTenant := newTestTenants("x1000c2s0b0n0", "x1000c9s0b0n0")
+	k8sClient := newFakeClient(t, &sshotTenant)
+	inv := newInventory(k8sClient)
+
+	expectTenants := func(resource string, ready float64, pending float64) {
+		t.Helper()
+		if value := testutil.ToFloat64(tenantsGauge.WithLabelValues(resource, stateReady)); value != ready {
+			t.Errorf("expected %v tenants with %s ready, got %v", ready, resource, value)
+		}
+		if value := testutil.ToFloat64(tenantsGauge.WithLabelValues(resource, statePending)); value != pending {
+			t.Errorf("expected %v tenants with %s pending, got %v", pending, resource, value)
+		}
+	}
+
+	err := HandleCreate(ctx, fabric, inv, nil, tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = UpdateStatus(ctx, k8sClient, fabric, inv, &sshotTenant, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectTenants("vni_partition", 1, 0)
+	expectTenants("vni_block", 0, 1)
+	expectTenants("vlan", 0, 1)
+
+	// the xname without an edge port is unresolved
+	recordUnresolvedXnames(ctx, fabric, "vcluster-blue", tenant.Spec.TenantResources[0].XNames)
+	if value := testutil.ToFloat64(unresolvedXnamesGauge.WithLabelValues("vcluster-blue")); value != 1 {
+		t.Errorf("expected 1 unresolved xname, got %v", value)
+	}
+
+	// the enforcement duration is observed from the creation of the tenant
+	observed := enforcementObservations(t)
+	recordEnforcementFinished(tenant, tenant.CreationTimestamp.Add(time.Minute))
+	if count := enforcementObservations(t) - observed; count != 1 {
+		t.Errorf("expected 1 enforcement duration, got %d", count)
+	}
+
+	// a deleted tenant is forgotten
+	err = TeardownTenant(ctx, k8sClient, fabric, inv, nil, nil, "vcluster-blue", &sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectTenants("vni_partition", 0, 0)
+	if count := testutil.CollectAndCount(unresolvedXnamesGauge); count != 0 {
+		t.Errorf("expected no unresolved xnames, got %d metrics", count)
+	}
+}
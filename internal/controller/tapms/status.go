@@ -0,0 +1,319 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package tapms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+	"github.hpe.com/hpe/sshot-net-operator/fm"
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+	"github.hpe.com/hpe/sshot-net-operator/internal/inventory"
+)
+
+// Condition reasons of a SlingshotTenant
+const (
+	// ReasonFound is used when the Fabric Manager document exists
+	ReasonFound = "Found"
+
+	// ReasonNotFound is used when the Fabric Manager document does not exist
+	ReasonNotFound = "NotFound"
+
+	// ReasonNotOwned is used when the Fabric Manager document exists but was not created by the operator
+	ReasonNotOwned = "NotOwned"
+
+	// ReasonFabricManagerError is used when Fabric Manager could not be queried
+	ReasonFabricManagerError = "FabricManagerError"
+
+	// ReasonEnforcementFinished is used when the enforcement task finished
+	ReasonEnforcementFinished = "Finished"
+
+	// ReasonEnforcementFailed is used when the enforcement task failed
+	ReasonEnforcementFailed = "Failed"
+
+	// ReasonEnforcementInProgress is used when the enforcement task has not finished yet
+	ReasonEnforcementInProgress = "InProgress"
+
+	// ReasonEnforcementTimedOut is used when the enforcement task did not finish by its deadline
+	ReasonEnforcementTimedOut = "TimedOut"
+
+	// ReasonConflict is used when the requested VLAN ID is used by another VLAN
+	ReasonConflict = "Conflict"
+
+	// ReasonOutOfSync is used when the VLAN or its port policy does not match the VLAN spec
+	ReasonOutOfSync = "OutOfSync"
+
+	// ReasonFabricManagerUnavailable is used when the requests to Fabric Manager are stopped by the circuit breaker
+	ReasonFabricManagerUnavailable = "FabricManagerUnavailable"
+
+	// ReasonReconcileFailed is used when the last reconciliation failed
+	ReasonReconcileFailed = "ReconcileFailed"
+
+	// ReasonReconcileSucceeded is used when the last reconciliation succeeded
+	ReasonReconcileSucceeded = "ReconcileSucceeded"
+)
+
+// UpdateStatus observes the network of the slingshot tenant in Fabric Manager
+// and writes the result, along with the outcome of the reconciliation, to the
+// status of the slingshot tenant. The status is only written when it changed.
+func UpdateStatus(ctx context.Context, c client.Client, fabric *fm.Client, inv *inventory.Inventory, sshotTenant *slingshot.SlingshotTenant, reconcileErr error) error {
+	original := sshotTenant.DeepCopy()
+
+	ObserveStatus(ctx, fabric, inv, sshotTenant)
+	setDegraded(sshotTenant, reconcileErr)
+	meta.RemoveStatusCondition(&sshotTenant.Status.Conditions, slingshot.ConditionTeardownComplete)
+	sshotTenant.Status.ObservedGeneration = sshotTenant.Generation
+	sshotTenant.Status.Message = statusMessage(&sshotTenant.Status)
+	recordTenantReadiness(sshotTenant)
+
+	if equality.Semantic.DeepEqual(original.Status, sshotTenant.Status) {
+		return nil
+	}
+
+	err := c.Status().Patch(ctx, sshotTenant, client.MergeFrom(original))
+	if err != nil {
+		return fmt.Errorf("cannot update status of slingshot tenant %s: %w", sshotTenant.Name, err)
+	}
+
+	return nil
+}
+
+// ObserveStatus sets the status fields and the readiness conditions of the
+// slingshot tenant from the documents in Fabric Manager
+func ObserveStatus(ctx context.Context, fabric *fm.Client, inv *inventory.Inventory, sshotTenant *slingshot.SlingshotTenant) {
+	status := &sshotTenant.Status
+	generation := sshotTenant.Generation
+
+	owned, err := inv.Load(ctx)
+	if err != nil {
+		for _, conditionType := range []string{slingshot.ConditionPartitionReady, slingshot.ConditionVLANReady, slingshot.ConditionVNIBlockReady, slingshot.ConditionEnforcementComplete} {
+			setCondition(status, generation, conditionType, metav1.ConditionUnknown, ReasonFabricManagerError, err.Error())
+		}
+		return
+	}
+
+	//VNI partition
+	observeVNIPartition(ctx, fabric, owned, sshotTenant)
+
+	//VLAN and VLAN port policy
+	observeVLAN(ctx, fabric, inv, sshotTenant)
+
+	//VNI block and its enforcement task
+	observeVNIBlock(ctx, fabric, owned, sshotTenant)
+}
+
+// observeVNIPartition records the VNI ranges of the VNI partition, which are
+// allocated by the operator or by Fabric Manager when only a count is requested
+func observeVNIPartition(ctx context.Context, fabric *fm.Client, owned inventory.Owned, sshotTenant *slingshot.SlingshotTenant) {
+	status := &sshotTenant.Status
+	tenantName := sshotTenant.Spec.TenantName
+	generation := sshotTenant.Generation
+
+	status.PartitionSelfLink = ""
+	vniPartition, err := fabric.VNIPartitions().Get(ctx, tenantName)
+	if httpclient.IsNotFound(err) {
+		status.VNIRanges = nil
+		setCondition(status, generation, slingshot.ConditionPartitionReady, metav1.ConditionFalse, ReasonNotFound, "VNI partition does not exist")
+		return
+	}
+	if err != nil {
+		setCondition(status, generation, slingshot.ConditionPartitionReady, metav1.ConditionUnknown, ReasonFabricManagerError, err.Error())
+		return
+	}
+
+	status.PartitionSelfLink = vniPartition.DocumentSelfLink
+	if !owned.Has(inventory.VNIPartition, tenantName) {
+		setCondition(status, generation, slingshot.ConditionPartitionReady, metav1.ConditionFalse, ReasonNotOwned, "VNI partition exists but is not owned by the operator")
+		return
+	}
+	status.VNIRanges = vniPartition.VNIRange
+	setCondition(status, generation, slingshot.ConditionPartitionReady, metav1.ConditionTrue, ReasonFound, "VNI partition exists")
+}
+
+func observeVLAN(ctx context.Context, fabric *fm.Client, inv *inventory.Inventory, sshotTenant *slingshot.SlingshotTenant) {
+	status := &sshotTenant.Status
+	tenantName := sshotTenant.Spec.TenantName
+	generation := sshotTenant.Generation
+
+	vlanID, err := GetVlanID(ctx, fabric, inv, tenantName)
+	if err != nil {
+		setCondition(status, generation, slingshot.ConditionVLANReady, metav1.ConditionUnknown, ReasonFabricManagerError, err.Error())
+		return
+	}
+	status.VLANID = vlanID
+
+	//the requested VLAN ID is in use when a VLAN with that ID exists that is not the tenant VLAN
+	requestedID := sshotTenant.Spec.VLAN.ID
+	if requestedID != 0 && requestedID != vlanID {
+		existing, err := GetExistingVLANIDs(ctx, fabric)
+		if err != nil {
+			setCondition(status, generation, slingshot.ConditionVLANReady, metav1.ConditionUnknown, ReasonFabricManagerError, err.Error())
+			return
+		}
+		for _, existingID := range existing {
+			if existingID == requestedID {
+				setCondition(status, generation, slingshot.ConditionVLANReady, metav1.ConditionFalse, ReasonConflict,
+					fmt.Sprintf("requested VLAN ID %d is already in use", requestedID))
+				return
+			}
+		}
+	}
+
+	if vlanID == 0 {
+		setCondition(status, generation, slingshot.ConditionVLANReady, metav1.ConditionFalse, ReasonNotFound, "VLAN does not exist")
+		return
+	}
+	if requestedID != 0 && requestedID != vlanID {
+		setCondition(status, generation, slingshot.ConditionVLANReady, metav1.ConditionFalse, ReasonOutOfSync,
+			fmt.Sprintf("VLAN ID is %d instead of the requested VLAN ID %d", vlanID, requestedID))
+		return
+	}
+
+	portPolicy, err := fabric.PortPolicies().Get(ctx, tenantName)
+	if httpclient.IsNotFound(err) {
+		setCondition(status, generation, slingshot.ConditionVLANReady, metav1.ConditionFalse, ReasonNotFound,
+			fmt.Sprintf("port policy for VLAN %d does not exist", vlanID))
+		return
+	}
+	if err != nil {
+		setCondition(status, generation, slingshot.ConditionVLANReady, metav1.ConditionUnknown, ReasonFabricManagerError, err.Error())
+		return
+	}
+
+	vlan, err := fabric.VLANs().Get(ctx, vlanID)
+	if err != nil {
+		setCondition(status, generation, slingshot.ConditionVLANReady, metav1.ConditionUnknown, ReasonFabricManagerError, err.Error())
+		return
+	}
+
+	if drift := vlanDrift(vlan, portPolicy, tenantName, sshotTenant.Spec.VLAN); drift != "" {
+		setCondition(status, generation, slingshot.ConditionVLANReady, metav1.ConditionFalse, ReasonOutOfSync, drift)
+		return
+	}
+
+	setCondition(status, generation, slingshot.ConditionVLANReady, metav1.ConditionTrue, ReasonFound,
+		fmt.Sprintf("VLAN %d and its port policy exist", vlanID))
+}
+
+func observeVNIBlock(ctx context.Context, fabric *fm.Client, owned inventory.Owned, sshotTenant *slingshot.SlingshotTenant) {
+	status := &sshotTenant.Status
+	generation := sshotTenant.Generation
+	vniBlockName := fmt.Sprintf("%s-%s", sshotTenant.Spec.TenantName, sshotTenant.Spec.VNIBlockName)
+
+	vniBlock, err := fabric.VNIBlocks().Get(ctx, vniBlockName)
+	if httpclient.IsNotFound(err) {
+		status.VNIBlockSelfLink = ""
+		status.EdgePortDFAs = nil
+		clearEnforcementState(status)
+		setCondition(status, generation, slingshot.ConditionVNIBlockReady, metav1.ConditionFalse, ReasonNotFound, "VNI block does not exist")
+		setCondition(status, generation, slingshot.ConditionEnforcementComplete, metav1.ConditionFalse, ReasonNotFound, "VNI block does not exist")
+		return
+	}
+	if err != nil {
+		setCondition(status, generation, slingshot.ConditionVNIBlockReady, metav1.ConditionUnknown, ReasonFabricManagerError, err.Error())
+		setCondition(status, generation, slingshot.ConditionEnforcementComplete, metav1.ConditionUnknown, ReasonFabricManagerError, err.Error())
+		return
+	}
+
+	if !owned.Has(inventory.VNIBlock, vniBlockName) {
+		status.VNIBlockSelfLink = ""
+		status.EdgePortDFAs = nil
+		clearEnforcementState(status)
+		setCondition(status, generation, slingshot.ConditionVNIBlockReady, metav1.ConditionFalse, ReasonNotOwned, "VNI block exists but is not owned by the operator")
+		setCondition(status, generation, slingshot.ConditionEnforcementComplete, metav1.ConditionUnknown, ReasonNotOwned, "VNI block exists but is not owned by the operator")
+		return
+	}
+
+	status.VNIBlockSelfLink = vniBlock.DocumentSelfLink
+	status.EdgePortDFAs = vniBlock.PortDFAs
+	setCondition(status, generation, slingshot.ConditionVNIBlockReady, metav1.ConditionTrue, ReasonFound, "VNI block exists")
+
+	link := vniBlock.EnforcementTaskServiceLink
+	if link == "" {
+		link = status.EnforcementTaskLink
+	}
+	if link == "" {
+		setCondition(status, generation, slingshot.ConditionEnforcementComplete, metav1.ConditionUnknown, ReasonNotFound, "VNI block has no enforcement task")
+		return
+	}
+
+	state, err := fabric.VNIBlocks().EnforcementTask(ctx, link)
+	if err != nil {
+		setCondition(status, generation, slingshot.ConditionEnforcementComplete, metav1.ConditionUnknown, ReasonFabricManagerError, err.Error())
+		return
+	}
+	setEnforcementState(status, link, state)
+
+	changes := fmt.Sprintf("adding %d VNIs and removing %d VNIs", len(state.AddVniList), len(state.RemoveVniList))
+	switch {
+	case state.TaskInfo.Stage == EnforcementStageFinished:
+		setCondition(status, generation, slingshot.ConditionEnforcementComplete, metav1.ConditionTrue, ReasonEnforcementFinished, "VNI block enforcement finished")
+	case state.TaskInfo.Stage == EnforcementStageFailed:
+		setCondition(status, generation, slingshot.ConditionEnforcementComplete, metav1.ConditionFalse, ReasonEnforcementFailed,
+			fmt.Sprintf("VNI block enforcement failed in sub-stage %s after %d retries", state.SubStage, status.EnforcementRetries))
+	case enforcementExpired(status, time.Now()):
+		setCondition(status, generation, slingshot.ConditionEnforcementComplete, metav1.ConditionFalse, ReasonEnforcementTimedOut,
+			fmt.Sprintf("VNI block enforcement did not finish by %s, it is in stage %s, sub-stage %s, %s",
+				status.EnforcementDeadline.UTC().Format(time.RFC3339), state.TaskInfo.Stage, state.SubStage, changes))
+	default:
+		setCondition(status, generation, slingshot.ConditionEnforcementComplete, metav1.ConditionFalse, ReasonEnforcementInProgress,
+			fmt.Sprintf("VNI block enforcement is in stage %s, sub-stage %s, %s", state.TaskInfo.Stage, state.SubStage, changes))
+	}
+}
+
+// setDegraded sets the Degraded condition from the outcome of the reconciliation
+func setDegraded(sshotTenant *slingshot.SlingshotTenant, reconcileErr error) {
+	if reconcileErr != nil {
+		log.Printf("slingshot tenant %s is degraded: %+v", sshotTenant.Name, reconcileErr)
+		reason := ReasonReconcileFailed
+		if httpclient.IsCircuitOpen(reconcileErr) {
+			reason = ReasonFabricManagerUnavailable
+		} else if errors.Is(reconcileErr, ErrNotOwned) {
+			reason = ReasonNotOwned
+		}
+		setCondition(&sshotTenant.Status, sshotTenant.Generation, slingshot.ConditionDegraded, metav1.ConditionTrue, reason, reconcileErr.Error())
+		return
+	}
+
+	setCondition(&sshotTenant.Status, sshotTenant.Generation, slingshot.ConditionDegraded, metav1.ConditionFalse, ReasonReconcileSucceeded, "reconciliation succeeded")
+}
+
+func setCondition(status *slingshot.SlingshotTenantStatus, generation int64, conditionType string, conditionStatus metav1.ConditionStatus, reason string, message string) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             conditionStatus,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// statusMessage summarizes the conditions in a single line
+func statusMessage(status *slingshot.SlingshotTenantStatus) string {
+	if degraded := meta.FindStatusCondition(status.Conditions, slingshot.ConditionDegraded); degraded != nil && degraded.Status == metav1.ConditionTrue {
+		return degraded.Message
+	}
+
+	for _, conditionType := range []string{slingshot.ConditionPartitionReady, slingshot.ConditionVLANReady, slingshot.ConditionVNIBlockReady, slingshot.ConditionEnforcementComplete} {
+		if !meta.IsStatusConditionTrue(status.Conditions, conditionType) {
+			condition := meta.FindStatusCondition(status.Conditions, conditionType)
+			if condition == nil {
+				return conditionType + " is unknown"
+			}
+			return condition.Message
+		}
+	}
+
+	return "tenant network is ready"
+}
@@ -0,0 +1,205 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package tapms
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+	tapms "github.hpe.com/hpe/sshot-net-operator/api/tapms/v1alpha2"
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+	"github.hpe.com/hpe/sshot-net-operator/internal/inventory"
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+func newFakeClient(t *testing.T, objects ...client.Object) client.Client {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := core.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := slingshot.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := tapms.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		WithStatusSubresource(&slingshot.SlingshotTenant{}).
+		Build()
+}
+
+// newInventory returns an empty inventory stored in c
+func newInventory(c client.Client) *inventory.Inventory {
+	return inventory.New(c, "sshot-net-operator", "sshot-net-operator-inventory", nil)
+}
+
+func TestUpdateStatus(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	tenant, sshotTenant := newTestTenants("x1000c2s0b0n0", "x1000c2s0b0n1")
+	k8sClient := newFakeClient(t, &sshotTenant)
+	inv := newInventory(k8sClient)
+
+	// nothing exists in Fabric Manager yet
+	err := UpdateStatus(ctx, k8sClient, fabric, inv, &sshotTenant, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.IsStatusConditionTrue(sshotTenant.Status.Conditions, slingshot.ConditionPartitionReady) {
+		t.Error("expected PartitionReady to be false")
+	}
+
+	err = HandleCreate(ctx, fabric, inv, nil, tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = CreateVLAN(ctx, fabric, inv, nil, []string{"x1000c2r3j100p0", "x1000c2r3j101p0"}, tenant.Spec.TenantName, slingshot.VLANSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vniBlock, err := CreateVNIBlock(ctx, fabric, inv, *tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the documents of the tenant are read without listing their collections
+	sent := len(server.Requests())
+	err = UpdateStatus(ctx, k8sClient, fabric, inv, &sshotTenant, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, request := range server.Requests()[sent:] {
+		switch request {
+		case "GET /fabric/vni/partitions", "GET /fabric/port-policies", "GET /fabric/vni/blocks":
+			t.Errorf("expected no collection to be listed, got %s", request)
+		}
+	}
+
+	var updated slingshot.SlingshotTenant
+	err = k8sClient.Get(ctx, client.ObjectKeyFromObject(&sshotTenant), &updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := updated.Status
+	for _, conditionType := range []string{slingshot.ConditionPartitionReady, slingshot.ConditionVLANReady,
+		slingshot.ConditionVNIBlockReady, slingshot.ConditionEnforcementComplete} {
+		if !meta.IsStatusConditionTrue(status.Conditions, conditionType) {
+			t.Errorf("expected %s to be true, got %+v", conditionType, meta.FindStatusCondition(status.Conditions, conditionType))
+		}
+	}
+	if !meta.IsStatusConditionFalse(status.Conditions, slingshot.ConditionDegraded) {
+		t.Error("expected Degraded to be false")
+	}
+	if status.VLANID != 1 || status.PartitionSelfLink != "/fabric/vni/partitions/vcluster-blue" ||
+		status.VNIBlockSelfLink != vniBlock.DocumentSelfLink || len(status.EdgePortDFAs) != 2 ||
+		status.EnforcementTaskLink != vniBlock.EnforcementTaskServiceLink || status.EnforcementStage != "FINISHED" {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if status.ObservedGeneration != 1 || status.Message != "tenant network is ready" {
+		t.Errorf("unexpected observed generation or message %+v", status)
+	}
+
+	// a failed reconciliation marks the tenant as degraded
+	err = server.SetEnforcementStage(vniBlock.EnforcementTaskServiceLink, "FAILED")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = UpdateStatus(ctx, k8sClient, fabric, inv, &updated, fmt.Errorf("cannot create VNI block"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	degraded := meta.FindStatusCondition(updated.Status.Conditions, slingshot.ConditionDegraded)
+	if degraded == nil || degraded.Status != metav1.ConditionTrue || degraded.Reason != ReasonReconcileFailed {
+		t.Errorf("expected Degraded to be true, got %+v", degraded)
+	}
+	enforcement := meta.FindStatusCondition(updated.Status.Conditions, slingshot.ConditionEnforcementComplete)
+	if enforcement == nil || enforcement.Status != metav1.ConditionFalse || enforcement.Reason != ReasonEnforcementFailed {
+		t.Errorf("expected EnforcementComplete to be false, got %+v", enforcement)
+	}
+	if updated.Status.Message != "cannot create VNI block" {
+		t.Errorf("unexpected message %q", updated.Status.Message)
+	}
+}
+
+func TestSetDegraded(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		reason string
+	}{
+		{name: "failed", err: fmt.Errorf("cannot create VNI block"), reason: ReasonReconcileFailed},
+		{name: "circuit open", err: fmt.Errorf("cannot create VNI block: %w", &httpclient.CircuitOpenError{Failures: 5}), reason: ReasonFabricManagerUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, sshotTenant := newTestTenants("x1000c2s0b0n0")
+			setDegraded(&sshotTenant, tt.err)
+
+			degraded := meta.FindStatusCondition(sshotTenant.Status.Conditions, slingshot.ConditionDegraded)
+			if degraded == nil || degraded.Status != metav1.ConditionTrue || degraded.Reason != tt.reason {
+				t.Errorf("expected Degraded to be true with reason %s, got %+v", tt.reason, degraded)
+			}
+		})
+	}
+}
+
+func TestObserveVLAN(t *testing.T) {
+	_, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	_, sshotTenant := newTestTenants("x1000c2s0b0n0")
+	inv := newInventory(newFakeClient(t))
+
+	_, err := CreateVLAN(ctx, fabric, inv, nil, []string{"x1000c2r3j100p0"}, sshotTenant.Spec.TenantName, slingshot.VLANSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fabric.VLANs().Create(ctx, models.VLANRequestData{VLANName: "other", VLANID: 5, Status: "ONLINE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		vlanSpec       slingshot.VLANSpec
+		expectedStatus metav1.ConditionStatus
+		expectedReason string
+	}{
+		{name: "matching", vlanSpec: slingshot.VLANSpec{ID: 1}, expectedStatus: metav1.ConditionTrue, expectedReason: ReasonFound},
+		{name: "requested VLAN ID in use", vlanSpec: slingshot.VLANSpec{ID: 5}, expectedStatus: metav1.ConditionFalse, expectedReason: ReasonConflict},
+		{name: "other VLAN ID", vlanSpec: slingshot.VLANSpec{ID: 6}, expectedStatus: metav1.ConditionFalse, expectedReason: ReasonOutOfSync},
+		{name: "other mode", vlanSpec: slingshot.VLANSpec{Mode: slingshot.VLANModeTagged}, expectedStatus: metav1.ConditionFalse, expectedReason: ReasonOutOfSync},
+		{name: "other status", vlanSpec: slingshot.VLANSpec{Status: slingshot.VLANStatusOffline}, expectedStatus: metav1.ConditionFalse, expectedReason: ReasonOutOfSync},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sshotTenant := sshotTenant.DeepCopy()
+			sshotTenant.Spec.VLAN = tt.vlanSpec
+
+			observeVLAN(ctx, fabric, inv, sshotTenant)
+			condition := meta.FindStatusCondition(sshotTenant.Status.Conditions, slingshot.ConditionVLANReady)
+			if condition == nil || condition.Status != tt.expectedStatus || condition.Reason != tt.expectedReason {
+				t.Errorf("expected VLANReady to be %s with reason %s, got %+v", tt.expectedStatus, tt.expectedReason, condition)
+			}
+		})
+	}
+}
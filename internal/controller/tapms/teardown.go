@@ -0,0 +1,217 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package tapms
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+	"github.hpe.com/hpe/sshot-net-operator/fm"
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+	"github.hpe.com/hpe/sshot-net-operator/internal/inventory"
+	"github.hpe.com/hpe/sshot-net-operator/internal/ipam"
+)
+
+const (
+	// Finalizer blocks the deletion of a Tenant or SlingshotTenant until the
+	// network of the tenant has been removed from Fabric Manager
+	Finalizer = "slingshot.hpe.com/network-teardown"
+)
+
+// Teardown condition reasons of a SlingshotTenant
+const (
+	// ReasonDeletingVNIBlock is used while the VNI block is deleted
+	ReasonDeletingVNIBlock = "DeletingVNIBlock"
+
+	// ReasonDeletingVNIPartition is used while the VNI partition is deleted
+	ReasonDeletingVNIPartition = "DeletingVNIPartition"
+
+	// ReasonDeletingVLAN is used while the VLAN and port policy are deleted
+	ReasonDeletingVLAN = "DeletingVLAN"
+
+	// ReasonTeardownFailed is used when a teardown step failed. The teardown is retried
+	ReasonTeardownFailed = "TeardownFailed"
+
+	// ReasonTeardownComplete is used when the network of the tenant has been removed
+	ReasonTeardownComplete = "TeardownComplete"
+)
+
+// EnsureFinalizer adds the teardown finalizer to obj when it is missing
+func EnsureFinalizer(ctx context.Context, c client.Client, obj client.Object) error {
+	if controllerutil.ContainsFinalizer(obj, Finalizer) {
+		return nil
+	}
+
+	original := obj.DeepCopyObject().(client.Object)
+	controllerutil.AddFinalizer(obj, Finalizer)
+	err := c.Patch(ctx, obj, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+	if err != nil {
+		return fmt.Errorf("cannot add finalizer to %s: %w", obj.GetName(), err)
+	}
+
+	return nil
+}
+
+// RemoveFinalizer removes the teardown finalizer from obj so it can be deleted
+func RemoveFinalizer(ctx context.Context, c client.Client, obj client.Object) error {
+	if !controllerutil.ContainsFinalizer(obj, Finalizer) {
+		return nil
+	}
+
+	original := obj.DeepCopyObject().(client.Object)
+	controllerutil.RemoveFinalizer(obj, Finalizer)
+	err := c.Patch(ctx, obj, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+	if err != nil {
+		return fmt.Errorf("cannot remove finalizer from %s: %w", obj.GetName(), err)
+	}
+
+	return nil
+}
+
+// TeardownTenant deletes the VNI block, VNI partition, VLAN and port policy
+// owned by the operator for a tenant and releases its VNIs and VLAN ID.
+// Documents that no longer exist are skipped, so a failed teardown can be
+// retried. When sshotTenant is not nil its status reports the progress.
+func TeardownTenant(ctx context.Context, c client.Client, fabric *fm.Client, inv *inventory.Inventory, vnis *ipam.Allocator, vlans *ipam.VLANAllocator, tenantName string, sshotTenant *slingshot.SlingshotTenant) error {
+	if tenantName == "" {
+		return nil
+	}
+
+	fail := func(err error) error {
+		setTeardownStatus(ctx, c, sshotTenant, metav1.ConditionFalse, ReasonTeardownFailed, err.Error())
+		return err
+	}
+
+	owned, err := inv.Load(ctx)
+	if err != nil {
+		return fail(err)
+	}
+
+	//delete the VNI blocks of the tenant
+	setTeardownStatus(ctx, c, sshotTenant, metav1.ConditionFalse, ReasonDeletingVNIBlock, "deleting VNI block")
+	vniBlocks, err := fabric.VNIBlocks().List(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	for _, entry := range owned.Entries() {
+		if entry.Kind != inventory.VNIBlock || entry.Tenant != tenantName {
+			continue
+		}
+
+		if containsLink(vniBlocks.DocumentLinks, entry.Name) {
+			//a VNI block already deleted is not an error
+			err = httpclient.IgnoreNotFound(fabric.VNIBlocks().Delete(ctx, entry.Name))
+			if err != nil {
+				WarningEvent(ctx, EventVNIBlockDeleteFailed, "cannot delete VNI block %s: %v", entry.Name, err)
+				return fail(err)
+			}
+			log.Printf("deleted VNI block %s for the tenant %s", entry.Name, tenantName)
+			NormalEvent(ctx, EventVNIBlockDeleted, "deleted VNI block %s", entry.Name)
+		}
+		err = inv.Forget(ctx, inventory.VNIBlock, entry.Name)
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	//delete the VNI partition
+	setTeardownStatus(ctx, c, sshotTenant, metav1.ConditionFalse, ReasonDeletingVNIPartition, "deleting VNI partition")
+	if owned.Has(inventory.VNIPartition, tenantName) {
+		vniPartitions, err := fabric.VNIPartitions().List(ctx)
+		if err != nil {
+			return fail(err)
+		}
+		if containsLink(vniPartitions.DocumentLinks, tenantName) {
+			err = httpclient.IgnoreNotFound(fabric.VNIPartitions().Delete(ctx, tenantName))
+			if err != nil {
+				WarningEvent(ctx, EventVNIPartitionDeleteFailed, "cannot delete VNI partition %s: %v", tenantName, err)
+				return fail(err)
+			}
+			log.Printf("deleted VNI partition %s", tenantName)
+			NormalEvent(ctx, EventVNIPartitionDeleted, "deleted VNI partition %s", tenantName)
+		}
+		err = inv.Forget(ctx, inventory.VNIPartition, tenantName)
+		if err != nil {
+			return fail(err)
+		}
+	}
+	if vnis != nil {
+		err = vnis.Release(ctx, tenantName)
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	//delete the VLAN and the port policy
+	setTeardownStatus(ctx, c, sshotTenant, metav1.ConditionFalse, ReasonDeletingVLAN, "deleting VLAN and port policy")
+	vlanID, err := GetVlanID(ctx, fabric, inv, tenantName)
+	if err != nil {
+		return fail(err)
+	}
+	if vlanID != 0 {
+		err = DeleteVLAN(ctx, fabric, inv, tenantName, vlanID)
+	} else {
+		err = DeletePortPolicy(ctx, fabric, inv, tenantName)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	//forget the VLANs of the tenant that were deleted outside of the operator
+	for _, entry := range owned.Entries() {
+		if entry.Kind == inventory.VLAN && entry.Tenant == tenantName && entry.Name != strconv.Itoa(vlanID) {
+			err = inv.Forget(ctx, inventory.VLAN, entry.Name)
+			if err != nil {
+				return fail(err)
+			}
+		}
+	}
+	if vlans != nil {
+		err = vlans.Release(ctx, tenantName)
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	setTeardownStatus(ctx, c, sshotTenant, metav1.ConditionTrue, ReasonTeardownComplete, "tenant network is deleted")
+	log.Printf("deleted the network of the tenant %s", tenantName)
+	forgetTenantMetrics(tenantName)
+
+	return nil
+}
+
+// containsLink returns true when one of the document links names the document
+func containsLink(links []string, name string) bool {
+	for _, link := range links {
+		if fm.LinkName(link) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setTeardownStatus reports the teardown progress in the status of the slingshot tenant
+func setTeardownStatus(ctx context.Context, c client.Client, sshotTenant *slingshot.SlingshotTenant, conditionStatus metav1.ConditionStatus, reason string, message string) {
+	if sshotTenant == nil {
+		return
+	}
+
+	original := sshotTenant.DeepCopy()
+	setCondition(&sshotTenant.Status, sshotTenant.Generation, slingshot.ConditionTeardownComplete, conditionStatus, reason, message)
+	sshotTenant.Status.Message = message
+
+	err := c.Status().Patch(ctx, sshotTenant, client.MergeFrom(original))
+	if err != nil {
+		log.Printf("cannot update teardown status of slingshot tenant %s: %+v", sshotTenant.Name, err)
+	}
+}
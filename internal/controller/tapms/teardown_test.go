@@ -0,0 +1,122 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package tapms
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+	tapms "github.hpe.com/hpe/sshot-net-operator/api/tapms/v1alpha2"
+	"github.hpe.com/hpe/sshot-net-operator/fm"
+	"github.hpe.com/hpe/sshot-net-operator/internal/inventory"
+)
+
+// createTenantNetwork creates the VNI partition, VLAN and VNI block of the test tenant
+func createTenantNetwork(t *testing.T, fabric *fm.Client, inv *inventory.Inventory, tenant *tapms.Tenant, sshotTenant slingshot.SlingshotTenant) {
+	t.Helper()
+	ctx := context.Background()
+
+	err := HandleCreate(ctx, fabric, inv, nil, tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = CreateVLAN(ctx, fabric, inv, nil, []string{"x1000c2r3j100p0"}, tenant.Spec.TenantName, slingshot.VLANSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = CreateVNIBlock(ctx, fabric, inv, *tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTeardownTenant(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	tenant, sshotTenant := newTestTenants("x1000c2s0b0n0")
+	k8sClient := newFakeClient(t, &sshotTenant)
+	inv := newInventory(k8sClient)
+	createTenantNetwork(t, fabric, inv, tenant, sshotTenant)
+
+	// a failed step is reported and the teardown can be retried
+	server.FailNext("DELETE", "/fabric/vni/partitions/vcluster-blue", http.StatusInternalServerError)
+	err := TeardownTenant(ctx, k8sClient, fabric, inv, nil, nil, "vcluster-blue", &sshotTenant)
+	if err == nil {
+		t.Fatal("expected the teardown to fail")
+	}
+	teardown := meta.FindStatusCondition(sshotTenant.Status.Conditions, slingshot.ConditionTeardownComplete)
+	if teardown == nil || teardown.Status != metav1.ConditionFalse || teardown.Reason != ReasonTeardownFailed {
+		t.Errorf("expected TeardownComplete to be false, got %+v", teardown)
+	}
+	if _, ok := server.VNIBlock("vcluster-blue-block"); ok {
+		t.Error("VNI block was not deleted")
+	}
+
+	err = TeardownTenant(ctx, k8sClient, fabric, inv, nil, nil, "vcluster-blue", &sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !meta.IsStatusConditionTrue(sshotTenant.Status.Conditions, slingshot.ConditionTeardownComplete) {
+		t.Error("expected TeardownComplete to be true")
+	}
+	if _, ok := server.VNIPartition("vcluster-blue"); ok {
+		t.Error("VNI partition was not deleted")
+	}
+	if _, ok := server.PortPolicy("vcluster-blue"); ok {
+		t.Error("port policy was not deleted")
+	}
+	if len(server.VLANs()) != 0 {
+		t.Errorf("VLAN was not deleted: %+v", server.VLANs())
+	}
+	if port, _ := server.Port("x1000c2r3j100p0"); len(port.PortPolicyLinks) != 0 {
+		t.Errorf("port policy was not removed from the edge port: %+v", port.PortPolicyLinks)
+	}
+
+	// nothing is left to delete
+	err = TeardownTenant(ctx, k8sClient, fabric, inv, nil, nil, "vcluster-blue", &sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconcileDeletedTenant(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	tenant, sshotTenant := newTestTenants("x1000c2s0b0n0")
+	inv := newInventory(newFakeClient(t))
+	createTenantNetwork(t, fabric, inv, tenant, sshotTenant)
+
+	tenant.Finalizers = []string{Finalizer}
+	tenant.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	k8sClient := newFakeClient(t, tenant, &sshotTenant)
+
+	r := &TenantReconciler{Client: k8sClient, Fabric: fabric, Inventory: inv}
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tenant)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := server.VNIPartition("vcluster-blue"); ok {
+		t.Error("VNI partition was not deleted")
+	}
+	if len(server.VLANs()) != 0 {
+		t.Errorf("VLAN was not deleted: %+v", server.VLANs())
+	}
+
+	// the tenant is gone once its finalizer is removed
+	err = k8sClient.Get(ctx, client.ObjectKeyFromObject(tenant), &tapms.Tenant{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected the tenant to be deleted, got %v", err)
+	}
+}
@@ -7,44 +7,76 @@ package tapms
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.hpe.com/hpe/sshot-net-operator/fm"
 	"github.hpe.com/hpe/sshot-net-operator/httpclient"
 	"k8s.io/apimachinery/pkg/runtime"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
 	tapms "github.hpe.com/hpe/sshot-net-operator/api/tapms/v1alpha2"
+	"github.hpe.com/hpe/sshot-net-operator/internal/inventory"
+	"github.hpe.com/hpe/sshot-net-operator/internal/ipam"
 	"github.hpe.com/hpe/sshot-net-operator/models"
-	core "k8s.io/api/core/v1"
 )
 
 // TenantReconciler reconciles a Tenant object
 type TenantReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Fabric is the Fabric Manager client
+	Fabric *fm.Client
+
+	// Inventory records the Fabric Manager documents owned by the operator
+	Inventory *inventory.Inventory
+
+	// VNIs allocates the VNIs of the tenants that only request a count. When
+	// nil, Fabric Manager allocates them
+	VNIs *ipam.Allocator
+
+	// VLANs allocates the VLAN IDs of the tenants. When nil, the lowest VLAN
+	// ID that does not exist in Fabric Manager is used
+	VLANs *ipam.VLANAllocator
+
+	// ReconciliationTime is the interval at which tenants are reconciled again
+	ReconciliationTime time.Duration
+
+	// Recorder records the events of the slingshot tenants
+	Recorder record.EventRecorder
+
+	// EnforcementTimeout is how long a VNI block enforcement task may run
+	// before it is retried. Zero never times out
+	EnforcementTimeout time.Duration
+
+	// EnforcementRetries is how many times a failed or timed out VNI block
+	// enforcement is retried
+	EnforcementRetries int
 }
 
-// VLANIDs is a global variable to store existing VLAN IDs
 var (
-	VLANIDs             = [256]int{}
 	tenantList          tapms.TenantList
 	slingshotTenantList slingshot.SlingshotTenantList
 	VNIPartitionsList   models.AllVNIPartitionsResponse
 	VNIBlocksList       models.AllVNIBlocksResponse
-	httpClient          = httpclient.NewClient(models.BaseURL)
 	tenantsMap          = make(map[string]tenantInfo)
 	ClientID            = "admin-client"
 )
 
+// ErrNotOwned is returned when a Fabric Manager document exists but was not
+// created by the operator, so it is never changed
+var ErrNotOwned = errors.New("exists but is not owned by the operator")
+
 type tenantInfo struct {
 	tenantName       string
 	tenantGeneration int64
@@ -54,6 +86,8 @@ type tenantInfo struct {
 //+kubebuilder:rbac:groups=tapms.hpe.com.hpe.com,resources=tenants,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=tapms.hpe.com.hpe.com,resources=tenants/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=tapms.hpe.com.hpe.com,resources=tenants/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -65,12 +99,24 @@ type tenantInfo struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.16.3/pkg/reconcile
 func (r *TenantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	accessToken, err := r.GetAccessToken(ctx)
-	if err != nil {
-		log.Printf("cannot get access token: %+v", err)
+	//tear down the network of a deleted tenant before its finalizer is removed
+	var requestedTenant tapms.Tenant
+	err := r.Get(ctx, req.NamespacedName, &requestedTenant)
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Printf("cannot get tenant: %+v", err)
 		return ctrl.Result{}, err
 	}
-	models.AccessToken = accessToken
+	if err == nil {
+		if !requestedTenant.DeletionTimestamp.IsZero() {
+			return ctrl.Result{}, r.finalizeTenant(ctx, &requestedTenant)
+		}
+
+		err = EnsureFinalizer(ctx, r.Client, &requestedTenant)
+		if err != nil {
+			log.Printf("cannot add finalizer to tenant: %+v", err)
+			return ctrl.Result{}, err
+		}
+	}
 
 	// Get the list of all v1alpha1 tenants
 	var tl tapms.TenantList
@@ -89,7 +135,7 @@ func (r *TenantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	slingshotTenantList = sTL
 
 	// Get all the VNI Partitions
-	vniPartitions, err := GetAllVNIPartitions()
+	vniPartitions, err := r.Fabric.VNIPartitions().List(ctx)
 	if err != nil {
 		log.Printf("cannot get VNI partitions: %s", err)
 		return ctrl.Result{}, err
@@ -97,7 +143,7 @@ func (r *TenantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	VNIPartitionsList = vniPartitions
 
 	// Get all the VNI Blocks
-	vniBlocks, err := GetAllVNIBlocks()
+	vniBlocks, err := r.Fabric.VNIBlocks().List(ctx)
 	if err != nil {
 		log.Printf("cannot get VNI blocks: %s", err)
 		return ctrl.Result{}, err
@@ -118,19 +164,38 @@ func (r *TenantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		}
 	}
 
+	//the pool metrics are refreshed on every reconciliation
+	if r.VNIs != nil {
+		if err := r.VNIs.Observe(ctx); err != nil {
+			log.Printf("cannot observe VNI pool: %+v", err)
+		}
+	}
+	if r.VLANs != nil {
+		if err := r.VLANs.Observe(ctx); err != nil {
+			log.Printf("cannot observe VLAN pool: %+v", err)
+		}
+	}
+
+	//tenants with a running enforcement task are polled sooner
+	requeueAfter := r.ReconciliationTime
+	//a failing tenant does not block the tenants after it
+	var errs []error
 	if len(tenantList.Items) > 0 {
 		log.Println("checking if VNI partitions and VLAN are present for tenants")
 		//Check for tenant creation. Compare the tenants and VNI Partitions
 		for _, tenant := range tenantList.Items {
+			//tenants being deleted are torn down by their own reconcile request
+			if !tenant.DeletionTimestamp.IsZero() {
+				continue
+			}
+
 			// Check if tenant is present in VNI Partitions
 			log.Printf("checking tenant %s", tenant.Spec.TenantName)
 			var vniPartitionFound bool
-			var vlanFound bool
 			var vniBlockFound bool
 			if len(vniPartitions.DocumentLinks) > 0 {
 				for _, vniPartition := range vniPartitions.DocumentLinks {
-					v := strings.Split(vniPartition, "/")
-					if tenant.Spec.TenantName == v[len(v)-1] {
+					if tenant.Spec.TenantName == fm.LinkName(vniPartition) {
 						vniPartitionFound = true
 						log.Println("VNI partition exists:", vniPartition)
 						break
@@ -142,12 +207,11 @@ func (r *TenantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 			var sshotTenant slingshot.SlingshotTenant
 			var sshotTenantFound bool
 			for _, sshotTenant = range slingshotTenantList.Items {
-				if tenant.Spec.TenantName == sshotTenant.Spec.TenantName {
+				if tenant.Spec.TenantName == sshotTenant.Spec.TenantName && sshotTenant.DeletionTimestamp.IsZero() {
 					sshotTenantFound = true
 					log.Println("slingshot tenant exists:", sshotTenant.Spec.TenantName)
 					for _, vniBlock := range VNIBlocksList.DocumentLinks {
-						v := strings.Split(vniBlock, "/")
-						if fmt.Sprintf("%s-%s", tenant.Spec.TenantName, sshotTenant.Spec.VNIBlockName) == v[len(v)-1] {
+						if fmt.Sprintf("%s-%s", tenant.Spec.TenantName, sshotTenant.Spec.VNIBlockName) == fm.LinkName(vniBlock) {
 							vniBlockFound = true
 							log.Println("VNI block exists:", vniBlock)
 							break
@@ -162,161 +226,221 @@ func (r *TenantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 				continue
 			}
 
-			if !vniPartitionFound && sshotTenantFound {
-				var txnames []string
-				// Create the VNI Partition
-				err := HandleCreate(ctx, &tenant, sshotTenant)
-				if err != nil {
-					log.Printf("cannot create VNI partition: %s", err)
-					return ctrl.Result{}, err
-				}
-				tenantsMap[tenant.Name] = tenantInfo{
-					tenantName:       tenant.Spec.TenantName,
-					tenantGeneration: tenant.Generation,
+			//the fabric operations of the tenant record their events on both resources
+			tenantCtx := WithEvents(ctx, r.Recorder, &tenant, &sshotTenant)
+			err := r.reconcileTenant(tenantCtx, &tenant, sshotTenant, vniPartitionFound, vniBlockFound)
+			if err == nil {
+				err = RecordAppliedVLAN(tenantCtx, r.Client, &sshotTenant)
+			}
+			if err == nil && !vniPartitionFound {
+				//the VNI partition and VNI block were just created from the spec
+				err = RecordAppliedVNI(tenantCtx, r.Client, &sshotTenant)
+			}
+			if err == nil {
+				//the duration is observed once, when the tenant is first enforced
+				enforced := sshotTenant.Status.FirstEnforcedTime != nil
+				var poll time.Duration
+				poll, err = TrackEnforcement(tenantCtx, r.Client, r.Fabric, r.Inventory, &sshotTenant, r.EnforcementTimeout, r.EnforcementRetries)
+				if poll > 0 && poll < requeueAfter {
+					requeueAfter = poll
 				}
-				for _, t := range tenant.Spec.TenantResources {
-					txnames = append(txnames, t.XNames...)
+				if err == nil && !enforced && sshotTenant.Status.FirstEnforcedTime != nil {
+					recordEnforcementFinished(&tenant, sshotTenant.Status.FirstEnforcedTime.Time)
 				}
-				info := tenantsMap[tenant.Name]
-				info.tenantXnames = txnames
-				tenantsMap[tenant.Name] = info
 			}
-
-			//Check if VLAN exists for the tenant
-			vlanFound, _, err = CheckVLANExists(&tenant)
+			var tenantXnames []string
+			for _, t := range tenant.Spec.TenantResources {
+				tenantXnames = append(tenantXnames, t.XNames...)
+			}
+			recordUnresolvedXnames(ctx, r.Fabric, tenant.Spec.TenantName, tenantXnames)
+			if statusErr := UpdateStatus(tenantCtx, r.Client, r.Fabric, r.Inventory, &sshotTenant, err); statusErr != nil {
+				log.Printf("cannot update slingshot tenant status: %+v", statusErr)
+			}
 			if err != nil {
-				log.Printf("cannot check if VLAN exists: %+v", err)
-				return ctrl.Result{}, err
+				RecordTokenFailure(tenantCtx, err)
+				//a document owned by someone else is only reported in the status of its tenant
+				if !errors.Is(err, ErrNotOwned) {
+					errs = append(errs, err)
+				}
 			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return ctrl.Result{}, err
+	}
 
-			//if VLAN does not exist, create VLAN
-			if !vlanFound {
-				// Create VLAN
-				var tenantXnames []string
-				for _, t := range tenant.Spec.TenantResources {
-					tenantXnames = append(tenantXnames, t.XNames...)
-				}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 
-				_, edgePorts, err := GetEdgePortDFAList(tenantXnames)
-				if err != nil {
-					log.Printf("cannot get edge ports for tenant: %+v", err)
-					return ctrl.Result{}, err
-				}
+}
 
-				vlan, err := CreateVLAN(edgePorts, tenant.Spec.TenantName)
-				if err != nil {
-					log.Printf("cannot create VLAN for tenant: %+v", err)
-					return ctrl.Result{}, err
-				}
-				log.Printf("created VLAN %s for the tenant %s", vlan, tenant.Spec.TenantName)
-			}
+// finalizeTenant tears down the network of a deleted tenant and removes its finalizer
+func (r *TenantReconciler) finalizeTenant(ctx context.Context, tenant *tapms.Tenant) error {
+	if !controllerutil.ContainsFinalizer(tenant, Finalizer) {
+		return nil
+	}
 
-			//Check if VNI block exists for the tenant. If not, create VNI block
-			if !vniBlockFound && sshotTenantFound {
-				//create VNI block
-				vniBlock, err := CreateVNIBlock(ctx, tenant, sshotTenant)
-				if err != nil {
-					log.Printf("cannot create VNI block: %+v", err)
-					return ctrl.Result{}, err
-				}
-				log.Printf("created VNI block %s for the tenant %s", vniBlock.DocumentSelfLink, tenant.Spec.TenantName)
+	var sTL slingshot.SlingshotTenantList
+	if err := r.List(ctx, &sTL); err != nil {
+		log.Printf("cannot list slingshot tenants: %s", err)
+		return err
+	}
 
-				//Check the stage of VniBlockEnforceTaskServiceState, keep checking until it is "FINISHED" or "FAILED"
-				stage, err := CheckVniBlockEnforceTaskServiceState(ctx, vniBlock.EnforcementTaskServiceLink)
-				if err != nil {
-					log.Printf("cannot check VniBlockEnforceTaskServiceState: %+v", err)
-					return ctrl.Result{}, err
-				}
+	var sshotTenant *slingshot.SlingshotTenant
+	for i := range sTL.Items {
+		if sTL.Items[i].Spec.TenantName == tenant.Spec.TenantName {
+			sshotTenant = &sTL.Items[i]
+			break
+		}
+	}
 
-				if stage {
-					log.Printf("enforcement for VNI block %s is completed", vniBlock.DocumentSelfLink)
-				} else {
-					log.Printf("enforcement for VNI block %s is failed", vniBlock.DocumentSelfLink)
-				}
-			}
+	objects := []runtime.Object{tenant}
+	if sshotTenant != nil {
+		objects = append(objects, sshotTenant)
+	}
+	ctx = WithEvents(ctx, r.Recorder, objects...)
 
-			//Check if both tenant specification and slingshot tenant specification exists.
-			//if yes, check if the generation of the tenant has changed. If yes, update the VNI partition.
-			//if tenant Xname is updated, delete the previous VLAN and create a new VLAN. This
-			//will be handled in the update function
-			if vniPartitionFound && sshotTenantFound {
-				if tenantsMap[tenant.Name].tenantGeneration != tenant.Generation {
-					// Update the VNI Partition
-					err := HandleUpdate(ctx, &tenant, sshotTenant)
-					if err != nil {
-						log.Printf("cannot update VNI partition or block: %+v", err)
-						return ctrl.Result{}, err
-					}
-					tempTenantInfo := tenantsMap[tenant.Name]
-					tempTenantInfo.tenantName = tenant.Spec.TenantName
-					tempTenantInfo.tenantGeneration = tenant.Generation
-					var txn []string
-					for _, t := range tenant.Spec.TenantResources {
-						txn = append(txn, t.XNames...)
-					}
-					tempTenantInfo.tenantXnames = txn
-					tenantsMap[tenant.Name] = tempTenantInfo
-				}
-			}
-		}
+	log.Printf("tenant %s is deleted. deleting VNI block, partition and VLAN", tenant.Spec.TenantName)
+	err := TeardownTenant(ctx, r.Client, r.Fabric, r.Inventory, r.VNIs, r.VLANs, tenant.Spec.TenantName, sshotTenant)
+	if err != nil {
+		log.Printf("cannot delete network of tenant %s: %+v", tenant.Spec.TenantName, err)
+		RecordTokenFailure(ctx, err)
+		return err
 	}
 
-	//check for tenant deletion. Check if tenant is marked for deletion and delete the VNI partition
-	if len(tenantsMap) > 0 {
-		tenantName := tenantsMap[req.Name].tenantName
-		var tenantForDeletion tapms.Tenant
-		err = r.Get(ctx, req.NamespacedName, &tenantForDeletion)
+	err = RemoveFinalizer(ctx, r.Client, tenant)
+	if err != nil {
+		log.Printf("cannot remove finalizer from tenant: %+v", err)
+		return err
+	}
+	delete(tenantsMap, tenant.Name)
+
+	return nil
+}
+
+// reconcileTenant creates or updates the VNI partition, VLAN and VNI block of a tenant
+func (r *TenantReconciler) reconcileTenant(ctx context.Context, tenant *tapms.Tenant, sshotTenant slingshot.SlingshotTenant, vniPartitionFound bool, vniBlockFound bool) error {
+	//Never update a VNI partition or block that was not created by the operator
+	owned, err := r.Inventory.Load(ctx)
+	if err != nil {
+		log.Printf("cannot load inventory: %+v", err)
+		return err
+	}
+	if vniPartitionFound && !owned.Has(inventory.VNIPartition, tenant.Spec.TenantName) {
+		return fmt.Errorf("VNI partition %s %w", tenant.Spec.TenantName, ErrNotOwned)
+	}
+	vniBlockName := fmt.Sprintf("%s-%s", tenant.Spec.TenantName, sshotTenant.Spec.VNIBlockName)
+	if vniBlockFound && !owned.Has(inventory.VNIBlock, vniBlockName) {
+		return fmt.Errorf("VNI block %s %w", vniBlockName, ErrNotOwned)
+	}
+
+	if !vniPartitionFound {
+		var txnames []string
+		// Create the VNI Partition
+		err := HandleCreate(ctx, r.Fabric, r.Inventory, r.VNIs, tenant, sshotTenant)
 		if err != nil {
-			var vniBlockName string
-			for _, slingshotTenants := range slingshotTenantList.Items {
-				if slingshotTenants.Spec.TenantName == tenantName {
-					vniBlockName = fmt.Sprintf("%s-%s", tenantName, slingshotTenants.Spec.VNIBlockName)
-					break
-				}
-			}
-			if tenantName != "" {
-				log.Printf("tenant %s is deleted. deleting VNI block %s, partition %s and VLAN", tenantName, vniBlockName, tenantName)
-				err := HandleDelete(ctx, tenantName, vniBlockName)
-				if err != nil {
-					log.Printf("cannot delete VNI partition: %+v", err)
-					return ctrl.Result{}, err
-				}
+			log.Printf("cannot create VNI partition: %s", err)
+			return err
+		}
+		tenantsMap[tenant.Name] = tenantInfo{
+			tenantName:       tenant.Spec.TenantName,
+			tenantGeneration: tenant.Generation,
+		}
+		for _, t := range tenant.Spec.TenantResources {
+			txnames = append(txnames, t.XNames...)
+		}
+		info := tenantsMap[tenant.Name]
+		info.tenantXnames = txnames
+		tenantsMap[tenant.Name] = info
+	}
 
-				//delete the vlan for the tenant
-				vlans, err := GetVLANs()
-				if err != nil {
-					log.Printf("cannot get all VLANs: %+v", err)
-					return ctrl.Result{}, err
-				}
+	//Check if VLAN exists for the tenant
+	vlanFound, vlanID, err := CheckVLANExists(ctx, r.Fabric, r.Inventory, tenant)
+	if err != nil {
+		log.Printf("cannot check if VLAN exists: %+v", err)
+		return err
+	}
 
-				for _, vlan := range vlans {
-					vlanID, err := strconv.Atoi(vlan[len(vlan)-1:])
-					if err != nil {
-						log.Printf("cannot convert vlan ID to integer: %+v", err)
-						return ctrl.Result{}, err
-					}
-					vlanDetails, err := GetVLAN(vlanID)
-					if err != nil {
-						log.Printf("cannot get VLAN details: %+v", err)
-						return ctrl.Result{}, err
-					}
-					if vlanDetails.VLANName == tenantName {
-						err = DeleteVLAN(tenantName, strconv.Itoa(vlanID))
-						if err != nil {
-							log.Printf("cannot delete VLAN: %+v", err)
-							return ctrl.Result{}, err
-						}
+	//if VLAN does not exist, or another VLAN ID is requested, create VLAN
+	if !vlanFound || (sshotTenant.Spec.VLAN.ID != 0 && sshotTenant.Spec.VLAN.ID != vlanID) {
+		var tenantXnames []string
+		for _, t := range tenant.Spec.TenantResources {
+			tenantXnames = append(tenantXnames, t.XNames...)
+		}
 
-					}
-				}
+		_, edgePorts, err := GetEdgePortDFAList(ctx, r.Fabric, tenantXnames)
+		if err != nil {
+			log.Printf("cannot get edge ports for tenant: %+v", err)
+			return err
+		}
+
+		if vlanFound {
+			//make sure the requested VLAN ID is free before deleting the previous VLAN
+			_, err := GetNewVLANID(ctx, r.Fabric, r.Inventory, r.VLANs, tenant.Spec.TenantName, sshotTenant.Spec.VLAN.ID)
+			if err != nil {
+				log.Printf("cannot change VLAN ID: %+v", err)
+				return err
+			}
 
-				delete(tenantsMap, req.Name)
+			log.Printf("VLAN ID %d is requested for tenant %s. deleting VLAN %d", sshotTenant.Spec.VLAN.ID, tenant.Spec.TenantName, vlanID)
+			err = DeleteVLAN(ctx, r.Fabric, r.Inventory, tenant.Spec.TenantName, vlanID)
+			if err != nil {
+				log.Printf("cannot delete VLAN: %+v", err)
+				return err
 			}
 		}
+
+		vlan, err := CreateVLAN(ctx, r.Fabric, r.Inventory, r.VLANs, edgePorts, tenant.Spec.TenantName, sshotTenant.Spec.VLAN)
+		if err != nil {
+			log.Printf("cannot create VLAN for tenant: %+v", err)
+			return err
+		}
+		log.Printf("created VLAN %s for the tenant %s", vlan, tenant.Spec.TenantName)
+	} else if RevertsDrift(sshotTenant) {
+		//update the VLAN status and port policy when they do not match the spec
+		err := UpdateVLAN(ctx, r.Fabric, r.Inventory, vlanID, tenant.Spec.TenantName, sshotTenant.Spec.VLAN)
+		if err != nil {
+			log.Printf("cannot update VLAN for tenant: %+v", err)
+			return err
+		}
 	}
 
-	return ctrl.Result{RequeueAfter: models.ReconciliationTime}, nil
+	//Check if VNI block exists for the tenant. If not, create VNI block
+	if !vniBlockFound {
+		//create VNI block
+		vniBlock, err := CreateVNIBlock(ctx, r.Fabric, r.Inventory, *tenant, sshotTenant)
+		if err != nil {
+			log.Printf("cannot create VNI block: %+v", err)
+			return err
+		}
+		log.Printf("created VNI block %s for the tenant %s, enforcement task %s started", vniBlock.DocumentSelfLink, tenant.Spec.TenantName,
+			vniBlock.EnforcementTaskServiceLink)
+	}
+
+	//Check if both tenant specification and slingshot tenant specification exists.
+	//if yes, check if the generation of the tenant has changed. If yes, update the VNI partition.
+	//if tenant Xname is updated, delete the previous VLAN and create a new VLAN. This
+	//will be handled in the update function
+	if vniPartitionFound {
+		if tenantsMap[tenant.Name].tenantGeneration != tenant.Generation {
+			// Update the VNI Partition
+			err := HandleUpdate(ctx, r.Fabric, r.Inventory, r.VNIs, r.VLANs, tenant, sshotTenant)
+			if err != nil {
+				log.Printf("cannot update VNI partition or block: %+v", err)
+				return err
+			}
+			tempTenantInfo := tenantsMap[tenant.Name]
+			tempTenantInfo.tenantName = tenant.Spec.TenantName
+			tempTenantInfo.tenantGeneration = tenant.Generation
+			var txn []string
+			for _, t := range tenant.Spec.TenantResources {
+				txn = append(txn, t.XNames...)
+			}
+			tempTenantInfo.tenantXnames = txn
+			tenantsMap[tenant.Name] = tempTenantInfo
+		}
+	}
 
+	return nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -328,14 +452,8 @@ func (r *TenantReconciler) SetupWithManager(mgr ctrl.Manager) error {
 }
 
 // HandleCreate handles create events for tenant resource
-func HandleCreate(ctx context.Context, tenant *tapms.Tenant, sshotTenant slingshot.SlingshotTenant) error {
-	var vniRequestData models.VNIRequestData
-	vniRequestData.PartitionName = tenant.Spec.TenantName
-	vniRequestData.VNICount = sshotTenant.Spec.VNIPartition.VNICount
-	vniRequestData.VNIRange = sshotTenant.Spec.VNIPartition.VNIRange
-
-	// Validate the VNI request data
-	err := ValidateVNIRequestData(vniRequestData)
+func HandleCreate(ctx context.Context, fabric *fm.Client, inv *inventory.Inventory, vnis *ipam.Allocator, tenant *tapms.Tenant, sshotTenant slingshot.SlingshotTenant) error {
+	vniRequestData, err := NewVNIRequestData(ctx, vnis, tenant.Spec.TenantName, sshotTenant)
 	if err != nil {
 		return err
 	}
@@ -345,35 +463,41 @@ func HandleCreate(ctx context.Context, tenant *tapms.Tenant, sshotTenant slingsh
 		tenantXnames = append(tenantXnames, t.XNames...)
 	}
 
-	edgePortDFAList, _, err := GetEdgePortDFAList(tenantXnames)
+	edgePortDFAList, _, err := GetEdgePortDFAList(ctx, fabric, tenantXnames)
 	if err != nil {
 		log.Printf("cannot get edge ports for tenant: %+v", err)
 		return err
 	}
 	vniRequestData.EdgePortDFA = edgePortDFAList
 
-	// Send the request
-	responseBody, err := httpClient.SendRequest(ctx, "POST", "/fabric/vni/partitions", vniRequestData)
+	//Record the VNI partition before creating it, so that it is never left unowned
+	forget, err := RecordCreate(ctx, inv, inventory.VNIPartition, tenant.Spec.TenantName, tenant.Spec.TenantName)
 	if err != nil {
-		log.Printf("cannot create VNI partition: %s %+v", responseBody, err)
+		log.Printf("cannot record VNI partition: %+v", err)
 		return err
 	}
 
-	//unmarshal the response in models.VNIPartitionResponse
-	var vniPartition models.VNIPartitionResponse
-	err = json.Unmarshal(responseBody, &vniPartition)
+	// Send the request
+	vniPartition, err := fabric.VNIPartitions().Create(ctx, vniRequestData)
+	if httpclient.IsConflict(err) {
+		//the VNI partition may have been created by a request whose response was lost
+		vniPartition, err = AdoptVNIPartition(ctx, fabric, vniRequestData, err)
+	}
 	if err != nil {
-		log.Printf("cannot unmarshal VNI partition: %s %+v", responseBody, err)
+		log.Printf("cannot create VNI partition: %+v", err)
+		forget(err)
+		WarningEvent(ctx, EventVNIPartitionCreateFailed, "cannot create VNI partition %s: %v", tenant.Spec.TenantName, err)
 		return err
 	}
 
 	log.Printf("created VNI partition %s for the tenant %s", vniPartition.DocumentSelfLink, sshotTenant.Spec.TenantName)
+	NormalEvent(ctx, EventVNIPartitionCreated, "created VNI partition %s with %d edge ports", tenant.Spec.TenantName, len(edgePortDFAList))
 
 	return nil
 }
 
 // HandleUpdate handles create events for tenant resource
-func HandleUpdate(ctx context.Context, tenant *tapms.Tenant, sshotTenant slingshot.SlingshotTenant) error {
+func HandleUpdate(ctx context.Context, fabric *fm.Client, inv *inventory.Inventory, vnis *ipam.Allocator, vlans *ipam.VLANAllocator, tenant *tapms.Tenant, sshotTenant slingshot.SlingshotTenant) error {
 	//check if tenant xname is updated. If yes, delete the previous VLAN and create a new VLAN
 	var tenantXnameUpdated bool
 	var tenantNodesCount int
@@ -408,13 +532,7 @@ func HandleUpdate(ctx context.Context, tenant *tapms.Tenant, sshotTenant slingsh
 			return fmt.Errorf("cannot update VNI block. VNIBlockName is empty")
 		}
 
-		var vniRequestData models.VNIRequestData
-		vniRequestData.PartitionName = tenant.Spec.TenantName
-		vniRequestData.VNICount = sshotTenant.Spec.VNIPartition.VNICount
-		vniRequestData.VNIRange = sshotTenant.Spec.VNIPartition.VNIRange
-
-		// Validate the VNI request data
-		err := ValidateVNIRequestData(vniRequestData)
+		vniRequestData, err := NewVNIRequestData(ctx, vnis, tenant.Spec.TenantName, sshotTenant)
 		if err != nil {
 			return err
 		}
@@ -424,7 +542,7 @@ func HandleUpdate(ctx context.Context, tenant *tapms.Tenant, sshotTenant slingsh
 			tenantXnames = append(tenantXnames, t.XNames...)
 		}
 
-		edgePortDFAList, edgePorts, err := GetEdgePortDFAList(tenantXnames)
+		edgePortDFAList, edgePorts, err := GetEdgePortDFAList(ctx, fabric, tenantXnames)
 		if err != nil {
 			log.Printf("cannot get edge ports for tenant: %+v", err)
 			return err
@@ -432,87 +550,92 @@ func HandleUpdate(ctx context.Context, tenant *tapms.Tenant, sshotTenant slingsh
 		vniRequestData.EdgePortDFA = edgePortDFAList
 
 		// Send the request
-		responseBody, err := httpClient.SendRequest(ctx, "PATCH", "/fabric/vni/partitions/"+tenant.Spec.TenantName, vniRequestData)
+		vniPartition, err := fabric.VNIPartitions().Update(ctx, tenant.Spec.TenantName, vniRequestData)
 		if err != nil {
-			log.Printf("cannot update VNI partition: %s %+v", responseBody, err)
+			log.Printf("cannot update VNI partition: %+v", err)
+			WarningEvent(ctx, EventVNIPartitionUpdateFailed, "cannot update VNI partition %s, creating it again: %v", tenant.Spec.TenantName, err)
 
-			err = HandleDelete(ctx, tenant.Spec.TenantName, fmt.Sprintf("%s-%s", tenant.Spec.TenantName, sshotTenant.Spec.VNIBlockName))
+			err = HandleDelete(ctx, fabric, inv, tenant.Spec.TenantName, fmt.Sprintf("%s-%s", tenant.Spec.TenantName, sshotTenant.Spec.VNIBlockName))
 			if err != nil {
 				log.Printf("cannot update VNI partition: %+v", err)
 				return err
 			}
 
-			_, vid, err := CheckVLANExists(tenant)
+			_, vid, err := CheckVLANExists(ctx, fabric, inv, tenant)
 			if err != nil {
 				log.Printf("cannot update VNI partition: %+v", err)
 				return err
 			}
 
 			//delete the vlan for the tenant
-			err = DeleteVLAN(tenant.Spec.TenantName, strconv.Itoa(vid))
+			err = DeleteVLAN(ctx, fabric, inv, tenant.Spec.TenantName, vid)
 			if err != nil {
 				log.Printf("cannot delete VLAN: %+v", err)
 				return err
 			}
 
 			//if partition not found, create the partition
-			err = HandleCreate(ctx, tenant, sshotTenant)
+			err = HandleCreate(ctx, fabric, inv, vnis, tenant, sshotTenant)
 			if err != nil {
 				log.Printf("cannot create VNI partition: %+v", err)
 				return err
 			}
+
+			//the VNI block and VLAN were deleted with the partition, create them again
+			vniBlock, err := CreateVNIBlock(ctx, fabric, inv, *tenant, sshotTenant)
+			if err != nil {
+				log.Printf("cannot create VNI block: %+v", err)
+				return err
+			}
+			log.Printf("created VNI block %s for the tenant %s, enforcement task %s started", vniBlock.DocumentSelfLink, tenant.Spec.TenantName,
+				vniBlock.EnforcementTaskServiceLink)
+
+			vlan, err := CreateVLAN(ctx, fabric, inv, vlans, edgePorts, tenant.Spec.TenantName, sshotTenant.Spec.VLAN)
+			if err != nil {
+				log.Printf("cannot create VLAN: %+v", err)
+				return err
+			}
+			log.Printf("created VLAN %s for the tenant %s", vlan, tenant.Spec.TenantName)
+
+			return nil
 		}
-		log.Println("updated VNI partitions for the tenant:", string(responseBody))
+		NormalEvent(ctx, EventVNIPartitionUpdated, "updated VNI partition %s to %d edge ports", tenant.Spec.TenantName, len(edgePortDFAList))
+		log.Println("updated VNI partitions for the tenant:", vniPartition.DocumentSelfLink)
 
 		//update VNI Block
 		var vniBlockPatchRequestData models.VNIBlockPatchRequest
-		vniBlockPatchRequestData.VNIBlockRange = sshotTenant.Spec.VNIPartition.VNIRange
+		vniBlockPatchRequestData.VNIBlockRange = vniRequestData.VNIRange
 		vniBlockPatchRequestData.PortDFAs = edgePortDFAList
 		vniBlockName := fmt.Sprintf("%s-%s", tenant.Spec.TenantName, sshotTenant.Spec.VNIBlockName)
 
 		//send the request
-		vniBlockResponseBody, err := httpClient.SendRequest(ctx, "PATCH", "/fabric/vni/blocks/"+vniBlockName, vniBlockPatchRequestData)
+		vniBlock, err := fabric.VNIBlocks().Patch(ctx, vniBlockName, vniBlockPatchRequestData)
 		if err != nil {
-			log.Printf("cannot update VNI block: %s %+v", vniBlockResponseBody, err)
+			log.Printf("cannot update VNI block: %+v", err)
+			WarningEvent(ctx, EventVNIBlockPatchFailed, "cannot update VNI block %s: %v", vniBlockName, err)
 			return err
 		}
+		NormalEvent(ctx, EventVNIBlockPatched, "updated VNI block %s to %d edge ports, enforcement task %s started", vniBlockName,
+			len(edgePortDFAList), vniBlock.EnforcementTaskServiceLink)
 
-		var vniBlock models.VNIBlockResponse
-		err = json.Unmarshal(vniBlockResponseBody, &vniBlock)
-		if err != nil {
-			log.Printf("cannot unmarshal VNI block: %s %+v", vniBlockResponseBody, err)
-			return err
-		}
-
-		//Check the stage of VniBlockEnforceTaskServiceState, keep checking until it is "FINISHED" or "FAILED"
-		stage, err := CheckVniBlockEnforceTaskServiceState(ctx, vniBlock.EnforcementTaskServiceLink)
-		if err != nil {
-			log.Printf("cannot check VniBlockEnforceTaskServiceState: %+v", err)
-			return err
-		}
-
-		if stage {
-			log.Printf("enforcement for VNI block %s is completed", vniBlock.DocumentSelfLink)
-		} else {
-			log.Printf("enforcement for VNI block %s is failed", vniBlock.DocumentSelfLink)
-		}
-
-		log.Println("updated VNI block for the tenant:", tenant.Spec.TenantName)
+		//the enforcement task is tracked by the reconciler, without waiting for it here
+		log.Printf("updated VNI block %s for the tenant %s, enforcement task %s started", vniBlock.DocumentSelfLink, tenant.Spec.TenantName,
+			vniBlock.EnforcementTaskServiceLink)
 
 		log.Println("tenant xname is updated.updating vlan for the tenant:", tenant.Spec.TenantName)
-		vlnaID, err := GetVlanID(ctx, tenant.Spec.TenantName)
+		vlnaID, err := GetVlanID(ctx, fabric, inv, tenant.Spec.TenantName)
 		if err != nil {
 			log.Printf("cannot get VLAN ID: %+v", err)
 			return err
 		}
 		log.Println("deleting the previous vlan for the tenant:", tenant.Spec.TenantName)
-		err = DeleteVLAN(tenant.Spec.TenantName, strconv.Itoa(vlnaID))
+		err = DeleteVLAN(ctx, fabric, inv, tenant.Spec.TenantName, vlnaID)
 		if err != nil {
 			log.Printf("cannot delete VLAN: %+v", err)
 			return err
 		}
 		log.Println("creating new vlan for the tenant:", tenant.Spec.TenantName)
-		vlan, err := CreateVLAN(edgePorts, tenant.Spec.TenantName)
+		vlan, err := CreateVLAN(ctx, fabric, inv, vlans, edgePorts, tenant.Spec.TenantName, sshotTenant.Spec.VLAN)
 		if err != nil {
 			log.Printf("cannot create VLAN: %+v", err)
 			return err
@@ -525,7 +648,7 @@ func HandleUpdate(ctx context.Context, tenant *tapms.Tenant, sshotTenant slingsh
 }
 
 // HandleDelete handles create events for tenant resource
-func HandleDelete(ctx context.Context, tenantName string, vniBlockName string) error {
+func HandleDelete(ctx context.Context, fabric *fm.Client, inv *inventory.Inventory, tenantName string, vniBlockName string) error {
 	if tenantName == "" {
 		log.Println("cannot delete vni partition. tenant name is empty")
 		return nil
@@ -536,211 +659,179 @@ func HandleDelete(ctx context.Context, tenantName string, vniBlockName string) e
 		return nil
 	}
 
-	log.Printf("deleting VNI enforced block %s for the tenant %s", vniBlockName, tenantName)
-	//delete the VNI block
-	_, err := httpClient.SendRequest(ctx, "DELETE", "/fabric/vni/blocks/"+vniBlockName, nil)
+	owned, err := inv.Load(ctx)
 	if err != nil {
-		log.Printf("cannot delete VNI block %s for the tenant:%s. %+v", vniBlockName, tenantName, err)
+		log.Printf("cannot load inventory: %+v", err)
 		return err
 	}
-	log.Printf("deleted VNI block %s", vniBlockName)
 
-	// delete the VNI partition
-	log.Printf("deleting VNI partition %s for the tenant %s", tenantName, tenantName)
-	_, err = httpClient.SendRequest(ctx, "DELETE", "/fabric/vni/partitions/"+tenantName, nil)
-	if err != nil {
-		log.Printf("cannot delete VNI partition for the tenant:%s. %+v", tenantName, err)
-		return err
+	//delete the VNI block
+	if owned.Has(inventory.VNIBlock, vniBlockName) {
+		log.Printf("deleting VNI enforced block %s for the tenant %s", vniBlockName, tenantName)
+		//a VNI block already deleted is not an error
+		err = httpclient.IgnoreNotFound(fabric.VNIBlocks().Delete(ctx, vniBlockName))
+		if err != nil {
+			log.Printf("cannot delete VNI block %s for the tenant:%s. %+v", vniBlockName, tenantName, err)
+			WarningEvent(ctx, EventVNIBlockDeleteFailed, "cannot delete VNI block %s: %v", vniBlockName, err)
+			return err
+		}
+		err = inv.Forget(ctx, inventory.VNIBlock, vniBlockName)
+		if err != nil {
+			return err
+		}
+		log.Printf("deleted VNI block %s", vniBlockName)
+		NormalEvent(ctx, EventVNIBlockDeleted, "deleted VNI block %s", vniBlockName)
+	} else {
+		log.Printf("VNI block %s is not owned by the operator. not deleting it", vniBlockName)
 	}
 
-	log.Println("deleted VNI partition")
+	// delete the VNI partition
+	if owned.Has(inventory.VNIPartition, tenantName) {
+		log.Printf("deleting VNI partition %s for the tenant %s", tenantName, tenantName)
+		err = httpclient.IgnoreNotFound(fabric.VNIPartitions().Delete(ctx, tenantName))
+		if err != nil {
+			log.Printf("cannot delete VNI partition for the tenant:%s. %+v", tenantName, err)
+			WarningEvent(ctx, EventVNIPartitionDeleteFailed, "cannot delete VNI partition %s: %v", tenantName, err)
+			return err
+		}
+		err = inv.Forget(ctx, inventory.VNIPartition, tenantName)
+		if err != nil {
+			return err
+		}
+		log.Println("deleted VNI partition")
+		NormalEvent(ctx, EventVNIPartitionDeleted, "deleted VNI partition %s", tenantName)
+	} else {
+		log.Printf("VNI partition %s is not owned by the operator. not deleting it", tenantName)
+	}
 
 	return nil
 
 }
 
-// GetAccessToken gets the access token for CSM API gateway
-func (r *TenantReconciler) GetAccessToken(ctx context.Context) (string, error) {
-	//get the client-secret from admin-client-auth secret in the default namespace
-	var adminSecret core.Secret
-	var accessToken string
+// GetEdgePortDFAList gets the list of edge ports for a tenant from the
+// topology cache of the fabric
+func GetEdgePortDFAList(ctx context.Context, fabric *fm.Client, tenantXnames []string) ([]int, []string, error) {
+	var edgePortDFAs []int
+	var edgePorts []string
 
-	err := r.Get(ctx, client.ObjectKey{Namespace: models.NamespaceForClientData, Name: models.SecretForClientData}, &adminSecret)
+	ports, err := fabric.Topology().EdgePorts(ctx, tenantXnames)
 	if err != nil {
-		log.Printf("cannot get admin-client-auth secret: %+v", err)
-		return accessToken, err
+		return edgePortDFAs, edgePorts, fmt.Errorf("could not get edge ports %+v", err)
 	}
 
-	adminClientSecretData := adminSecret.Data["client-secret"]
-	slingshotAdminClientSecret := strings.TrimSpace(string(adminClientSecretData))
-
-	//get endpoint for access token
-	endpointBytes := adminSecret.Data["endpoint"]
-	endpoint := string(endpointBytes)
-
-	//create form data
-	data := make(map[string]string)
-	data["grant_type"] = "client_credentials"
-	data["client_id"] = models.ClientID
-	data["scope"] = "openid"
-	data["client_secret"] = slingshotAdminClientSecret
-
-	client := httpclient.NewClient("")
-
-	resp, err := client.SendRequest(ctx, "POST", endpoint, data)
-	if err != nil {
-		log.Printf("cannot get access token: %+v", err)
-		return accessToken, err
+	for _, p := range ports {
+		log.Printf("edge port found %s for xname %s", p.Name, p.Xname)
+		edgePortDFAs = append(edgePortDFAs, p.DFA)
+		edgePorts = append(edgePorts, p.Name)
 	}
 
-	// parse the access token from the response body
-	var result models.TokenResponse
-	err = json.Unmarshal([]byte(resp), &result)
-	if err != nil {
-		log.Printf("cannot unmarshal access token: %+v", err)
-		return accessToken, err
-	}
-
-	// get the access token
-	accessToken = result.AccessToken
-
-	return accessToken, nil
+	return edgePortDFAs, edgePorts, nil
+}
 
+// CalculateEdgePortDFA calculates the edge port DFA
+func CalculateEdgePortDFA(grpID int, swID int, portID int) (int, error) {
+	return fm.EdgePortDFA(grpID, swID, portID), nil
 }
 
-// GetPartition gets the VNI partition
-func GetPartition(ctx context.Context, partitionName string) (models.VNIPartitionResponse, error) {
-	responseBody, err := httpClient.SendRequest(ctx, "GET", "/fabric/vni/partitions/"+partitionName, models.VNIRequestData{})
+// GetNewVLANID returns the VLAN ID of a tenant, skipping the VLAN IDs that
+// exist in Fabric Manager for other tenants or outside of the operator. When
+// requestedID is not zero, it is returned unless it is already in use
+func GetNewVLANID(ctx context.Context, fabric *fm.Client, inv *inventory.Inventory, vlans *ipam.VLANAllocator, tenantName string, requestedID int) (int, error) {
+	existing, err := GetExistingVLANIDs(ctx, fabric)
 	if err != nil {
-		log.Printf("cannot get VNI partition: %s %+v", responseBody, err)
-		return models.VNIPartitionResponse{}, err
+		log.Printf("cannot get existing VLAN IDs: %+v", err)
+		return 0, err
 	}
 
-	//unmarshal the response in models.VNIPartitionResponse
-	var vniPartition models.VNIPartitionResponse
-	err = json.Unmarshal(responseBody, &vniPartition)
+	owned, err := inv.Load(ctx)
 	if err != nil {
-		log.Printf("cannot unmarshal VNI partition: %s %+v", responseBody, err)
-		return models.VNIPartitionResponse{}, err
+		log.Printf("cannot load inventory: %+v", err)
+		return 0, err
 	}
 
-	return vniPartition, nil
-}
-
-// GetEdgePortDFAList gets the list of edge ports for a tenant
-func GetEdgePortDFAList(tenantXnames []string) ([]int, []string, error) {
-	var edgePortDFAs []int
-	var edgePorts []string
+	var inUse []int
+	for _, vlanID := range existing {
+		if tenant, ok := owned.Tenant(inventory.VLAN, strconv.Itoa(vlanID)); !ok || tenant != tenantName {
+			inUse = append(inUse, vlanID)
+		}
+	}
 
-	switches, err := fm.GetAllSwitches()
-	if err != nil {
-		return edgePortDFAs, edgePorts, fmt.Errorf("could not get all switches %+v", err)
+	used := make(map[int]bool, len(inUse))
+	for _, vlanID := range inUse {
+		used[vlanID] = true
 	}
 
-	for _, x := range switches {
-		DFAComponents, err := fm.GetSwitch(x)
-		if err != nil {
-			return edgePortDFAs, edgePorts, fmt.Errorf("could not get ports for switch %+v", err)
+	if requestedID != 0 {
+		if used[requestedID] {
+			return 0, fmt.Errorf("VLAN ID %d requested by tenant %s is already in use", requestedID, tenantName)
 		}
-
-		for _, p := range DFAComponents.EdgePortsInfo {
-			port, err := fm.GetPort(p.EdgePort)
+		if vlans != nil {
+			err := vlans.Reserve(ctx, tenantName, requestedID)
 			if err != nil {
-				return edgePortDFAs, edgePorts, fmt.Errorf("could not get port details for port %+v", err)
-			}
-
-			dstPort := port.DstPort
-
-			for _, xname := range tenantXnames {
-				if dstPort[:len(dstPort)-2] == xname {
-					log.Printf("edge port found %s for xname %s", p.EdgePort, xname)
-					edgePortDFA, err := CalculateEdgePortDFA(DFAComponents.GroupID, DFAComponents.SwitchID, p.PortID)
-					if err != nil {
-						return edgePortDFAs, edgePorts, fmt.Errorf("could not calculate edge port DFA %+v", err)
-					}
-					edgePortDFAs = append(edgePortDFAs, edgePortDFA)
-					edgePorts = append(edgePorts, p.EdgePort)
-				}
+				return 0, err
 			}
 		}
-
+		return requestedID, nil
 	}
 
-	return edgePortDFAs, edgePorts, nil
-}
-
-// CalculateEdgePortDFA calculates the edge port DFA
-func CalculateEdgePortDFA(grpID int, swID int, portID int) (int, error) {
-	dfa := ((grpID << 23) | (swID << 18) | (portID << 12))
-	return dfa, nil
-}
-
-// GetNewVLANID returns a new VLAN ID
-func GetNewVLANID() (int, error) {
-	err := GetExistingVLANIDs()
-	if err != nil {
-		log.Printf("cannot get existing VLAN IDs: %+v", err)
-		return 0, err
+	if vlans != nil {
+		return vlans.Allocate(ctx, tenantName, inUse)
 	}
 
-	var vlanid int
-
-	for i := 0; i < len(VLANIDs); i++ {
-		if VLANIDs[i] == 0 {
-			vlanid = i + 1
-			break
+	for vlanID := ipam.MinVLANID; vlanID <= ipam.MaxVLANID; vlanID++ {
+		if !used[vlanID] {
+			return vlanID, nil
 		}
 	}
 
-	return vlanid, nil
+	return 0, fmt.Errorf("no VLAN ID is free")
 }
 
-func createVlan(vlanid int, tenantname string) (string, error) {
+func createVlan(ctx context.Context, fabric *fm.Client, inv *inventory.Inventory, vlanid int, tenantname string, vlanSpec slingshot.VLANSpec) (string, error) {
 	var vlanRequestData models.VLANRequestData
 	vlanRequestData.VLANName = tenantname
 	vlanRequestData.VLANID = vlanid
-	vlanRequestData.Status = "ONLINE"
-
-	httpClient := httpclient.NewClient(models.BaseURL)
+	vlanRequestData.Status = VLANStatus(vlanSpec)
 
-	// send POST request to /fabric/vlans to create VLAN
-	responseBody, err := httpClient.SendRequest(context.Background(), "POST", "/fabric/vlans", vlanRequestData)
+	forget, err := RecordCreate(ctx, inv, inventory.VLAN, strconv.Itoa(vlanid), tenantname)
 	if err != nil {
-		log.Printf("cannot create VLAN: %s %+v", responseBody, err)
+		log.Printf("cannot record VLAN: %+v", err)
 		return "", err
 	}
 
-	var vlanResponse models.VLANResponse
-	err = json.Unmarshal(responseBody, &vlanResponse)
+	vlanResponse, err := fabric.VLANs().Create(ctx, vlanRequestData)
+	if httpclient.IsConflict(err) {
+		vlanResponse, err = adoptVLAN(ctx, fabric, vlanRequestData, err)
+	}
 	if err != nil {
-		log.Printf("cannot unmarshal VLAN response: %+v", err)
+		log.Printf("cannot create VLAN: %+v", err)
+		forget(err)
+		WarningEvent(ctx, EventVLANCreateFailed, "cannot create VLAN %d: %v", vlanid, err)
 		return "", err
 	}
+	NormalEvent(ctx, EventVLANCreated, "created VLAN %d", vlanid)
 
 	return vlanResponse.DocumentSelfLink, nil
 }
 
 // CreateVLANPortPolicy creates VLAN port policy for a tenant
-func CreateVLANPortPolicy(vlanid int, tenantname string) (models.VLANPortPolicyResponse, error) {
-	var VLANPortPolicyResponse models.VLANPortPolicyResponse
-	var VLANPortPolicyRequest models.VLANPortPolicyRequest
-	VLANPortPolicyRequest.NativeVlanID = fmt.Sprintf("/fabric/vlans/%d", vlanid)
-	VLANPortPolicyRequest.IsUntaggedAllowed = true
-	VLANPortPolicyRequest.AllowedVlans = append(VLANPortPolicyRequest.AllowedVlans, fmt.Sprintf("/fabric/vlans/%d", vlanid))
-	VLANPortPolicyRequest.DocumentSelfLink = tenantname
-
-	httpClient := httpclient.NewClient(models.BaseURL)
-
-	// send POST request to /fabric/port-policies to create VLAN port policy
-	responseBody, err := httpClient.SendRequest(context.Background(), "POST", "/fabric/port-policies", VLANPortPolicyRequest)
+func CreateVLANPortPolicy(ctx context.Context, fabric *fm.Client, inv *inventory.Inventory, vlanid int, tenantname string, vlanSpec slingshot.VLANSpec) (models.VLANPortPolicyResponse, error) {
+	VLANPortPolicyRequest := NewVLANPortPolicyRequest(vlanid, tenantname, vlanSpec)
+
+	forget, err := RecordCreate(ctx, inv, inventory.PortPolicy, tenantname, tenantname)
 	if err != nil {
-		log.Printf("cannot create VLAN port policy: %s %+v", responseBody, err)
-		return VLANPortPolicyResponse, err
+		log.Printf("cannot record VLAN port policy: %+v", err)
+		return models.VLANPortPolicyResponse{}, err
 	}
 
-	err = json.Unmarshal(responseBody, &VLANPortPolicyResponse)
+	VLANPortPolicyResponse, err := fabric.PortPolicies().Create(ctx, VLANPortPolicyRequest)
+	if httpclient.IsConflict(err) {
+		VLANPortPolicyResponse, err = adoptVLANPortPolicy(ctx, fabric, VLANPortPolicyRequest, err)
+	}
 	if err != nil {
-		log.Printf("cannot unmarshal VLAN port policy response: %+v", err)
+		log.Printf("cannot create VLAN port policy: %+v", err)
+		forget(err)
+		WarningEvent(ctx, EventVLANCreateFailed, "cannot create port policy %s for VLAN %d: %v", tenantname, vlanid, err)
 		return VLANPortPolicyResponse, err
 	}
 
@@ -749,63 +840,44 @@ func CreateVLANPortPolicy(vlanid int, tenantname string) (models.VLANPortPolicyR
 }
 
 // ApplyVLANPortPolicyToEdgePorts applies VLAN port policy to edge ports
-func ApplyVLANPortPolicyToEdgePorts(edgePorts []string, vlanPortPolicy models.VLANPortPolicyResponse) error {
-	for _, edgePort := range edgePorts {
-		log.Printf("applying VLAN port policy to edge port %+v", edgePort)
-		var PortPATCHRequest models.PortPATCHRequest
-		port, err := fm.GetPort(edgePort)
-		if err != nil {
-			log.Printf("cannot get port details for edge port: %+v", err)
-			return err
-		}
-
-		for _, x := range port.PortPolicyLinks {
-			if x == vlanPortPolicy.DocumentSelfLink {
-				log.Printf("VLAN port policy already applied to edge port %s", edgePort)
-				break
-			}
-		}
-
-		PortPATCHRequest.PortPolicyLinks = append(PortPATCHRequest.PortPolicyLinks, vlanPortPolicy.DocumentSelfLink)
-		PortPATCHRequest.PortPolicyLinks = append(PortPATCHRequest.PortPolicyLinks, port.PortPolicyLinks...)
-
-		// send PATCH request to /fabric/ports/{edgePort} to apply VLAN port policy
-		responseBody, err := httpClient.SendRequest(context.Background(), "PATCH", "/fabric/ports/"+edgePort, PortPATCHRequest)
-		if err != nil {
-			log.Printf("cannot apply VLAN port policy to edge port: %s %+v", responseBody, err)
-			return err
-		}
+func ApplyVLANPortPolicyToEdgePorts(ctx context.Context, fabric *fm.Client, edgePorts []string, vlanPortPolicy models.VLANPortPolicyResponse) error {
+	err := fabric.PortPolicyManager().Apply(ctx, edgePorts, vlanPortPolicy.DocumentSelfLink)
+	if err != nil {
+		log.Printf("cannot apply VLAN port policy to edge port: %+v", err)
+		WarningEvent(ctx, EventPortPolicyApplyFailed, "cannot apply port policy %s to edge ports: %v", fm.LinkName(vlanPortPolicy.DocumentSelfLink), err)
+		return err
 	}
+	NormalEvent(ctx, EventPortPolicyApplied, "applied port policy %s to %d edge ports", fm.LinkName(vlanPortPolicy.DocumentSelfLink), len(edgePorts))
 
 	log.Printf("applied VLAN port policy to edge ports %+v", edgePorts)
 	return nil
 }
 
 // CreateVLAN creates VLAN for a tenant
-func CreateVLAN(edgePorts []string, tenantName string) (string, error) {
+func CreateVLAN(ctx context.Context, fabric *fm.Client, inv *inventory.Inventory, vlans *ipam.VLANAllocator, edgePorts []string, tenantName string, vlanSpec slingshot.VLANSpec) (string, error) {
 	log.Printf("creating VLAN for tenant %s", tenantName)
 
-	vlanid, err := GetNewVLANID()
+	vlanid, err := GetNewVLANID(ctx, fabric, inv, vlans, tenantName, vlanSpec.ID)
 	if err != nil {
 		log.Printf("cannot get new VLAN ID: %+v", err)
 		return "", err
 	}
 
-	vlan, err := createVlan(vlanid, tenantName)
+	vlan, err := createVlan(ctx, fabric, inv, vlanid, tenantName, vlanSpec)
 	if err != nil {
 		log.Printf("cannot create VLAN: %+v", err)
 		return "", err
 	}
 
 	//create VLAN port policy
-	vlanPortPolicy, err := CreateVLANPortPolicy(vlanid, tenantName)
+	vlanPortPolicy, err := CreateVLANPortPolicy(ctx, fabric, inv, vlanid, tenantName, vlanSpec)
 	if err != nil {
 		log.Printf("cannot create VLAN port policy: %+v", err)
 		return "", err
 	}
 
 	//apply vlan port policy to edge ports
-	err = ApplyVLANPortPolicyToEdgePorts(edgePorts, vlanPortPolicy)
+	err = ApplyVLANPortPolicyToEdgePorts(ctx, fabric, edgePorts, vlanPortPolicy)
 	if err != nil {
 		log.Printf("cannot apply VLAN port policy to edge ports: %+v", err)
 		return "", err
@@ -814,285 +886,178 @@ func CreateVLAN(edgePorts []string, tenantName string) (string, error) {
 	return vlan, nil
 }
 
-// GetVLANs gets the list of existing VLANs
-func GetVLANs() ([]string, error) {
-	ctx := context.Background()
-	var VLANs []string
-
-	responseBody, err := httpClient.SendRequest(ctx, "GET", "/fabric/vlans", nil)
-	if err != nil {
-		log.Printf("cannot get VLANs: %s %+v", responseBody, err)
-		return VLANs, err
-	}
-
-	var VLANsResponse models.VLANsResponse
-	err = json.Unmarshal(responseBody, &VLANsResponse)
-	if err != nil {
-		log.Printf("cannot unmarshal VLANs response: %+v", err)
-		return VLANs, err
-	}
-
-	VLANs = append(VLANs, VLANsResponse.DocumentLinks...)
-
-	return VLANs, nil
-}
-
-// GetVLAN gets the VLAN
-func GetVLAN(vlan int) (models.VLANResponse, error) {
-	ctx := context.Background()
-	vlanLink := fmt.Sprintf("/fabric/vlans/%d", vlan)
-	responseBody, err := httpClient.SendRequest(ctx, "GET", vlanLink, nil)
-	if err != nil {
-		log.Printf("cannot get VLAN: %s %+v", responseBody, err)
-		return models.VLANResponse{}, err
-	}
-
-	var vlanResponse models.VLANResponse
-	err = json.Unmarshal(responseBody, &vlanResponse)
-	if err != nil {
-		log.Printf("cannot unmarshal VLAN response: %s %+v", responseBody, err)
-		return models.VLANResponse{}, err
-	}
-
-	return vlanResponse, nil
-}
-
 // GetExistingVLANIDs gets the list of existing VLAN IDs
-func GetExistingVLANIDs() error {
-	valns, err := GetVLANs()
+func GetExistingVLANIDs(ctx context.Context, fabric *fm.Client) ([]int, error) {
+	vlans, err := fabric.VLANs().List(ctx)
 	if err != nil {
 		log.Printf("cannot get VLANs: %+v", err)
-		return nil
+		return nil, err
 	}
 
-	for _, x := range valns {
-		splitDocumentLink := strings.Split(x, "/")
-		vlanID, err := strconv.Atoi(splitDocumentLink[len(splitDocumentLink)-1])
+	var vlanIDs []int
+	for _, x := range vlans.DocumentLinks {
+		vlanID, err := strconv.Atoi(fm.LinkName(x))
 		if err != nil {
 			log.Printf("cannot convert VLAN ID to integer: %+v", err)
-			return err
+			return nil, err
 		}
-		VLANIDs[vlanID-1] = 1
+		vlanIDs = append(vlanIDs, vlanID)
+	}
 
+	return vlanIDs, nil
+}
+
+// RemovePortPolicyFromEdgePort removes port policy from edge port
+func RemovePortPolicyFromEdgePort(ctx context.Context, fabric *fm.Client, edgePort string, portPolicy string) error {
+	err := fabric.PortPolicyManager().Remove(ctx, []string{edgePort}, portPolicy)
+	if err != nil {
+		log.Printf("cannot remove port policy from edge port: %+v", err)
+		WarningEvent(ctx, EventPortPolicyRemoveFailed, "cannot remove port policy %s from edge port %s: %v", fm.LinkName(portPolicy), edgePort, err)
+		return err
 	}
+	NormalEvent(ctx, EventPortPolicyRemoved, "removed port policy %s from edge port %s", fm.LinkName(portPolicy), edgePort)
+
+	log.Printf("removed port policy %s from edge port %s", portPolicy, edgePort)
 	return nil
 }
 
-// GetPortPolicy gets the port policy
-func GetPortPolicy(portPolicy string) (models.PortPolicyResponse, error) {
-	ctx := context.Background()
-	httpClient := httpclient.NewClient(models.BaseURL)
-	responseBody, err := httpClient.SendRequest(ctx, "GET", portPolicy, nil)
+// CheckVLANExists checks if VLAN exists
+func CheckVLANExists(ctx context.Context, fabric *fm.Client, inv *inventory.Inventory, tenant *tapms.Tenant) (bool, int, error) {
+	vlanID, err := GetVlanID(ctx, fabric, inv, tenant.Spec.TenantName)
 	if err != nil {
-		log.Printf("cannot get port policy: %s %+v", responseBody, err)
-		return models.PortPolicyResponse{}, err
+		return false, vlanID, err
 	}
 
-	var portPolicyResponse models.PortPolicyResponse
-	err = json.Unmarshal(responseBody, &portPolicyResponse)
-	if err != nil {
-		log.Printf("cannot unmarshal port policy: %s %+v", responseBody, err)
-		return models.PortPolicyResponse{}, err
+	if vlanID != 0 {
+		log.Println("VLAN exists for tenant:", tenant.Spec.TenantName, vlanID)
+		return true, vlanID, nil
 	}
 
-	return portPolicyResponse, nil
+	return false, vlanID, nil
 }
 
-// deleteVLAN deletes VLAN
-func deleteVLAN(vlan string) error {
-	httpClient := httpclient.NewClient(models.BaseURL)
+// DeleteVLAN deletes VLAN
+func DeleteVLAN(ctx context.Context, fabric *fm.Client, inv *inventory.Inventory, tenantName string, vlanID int) error {
+	log.Println("deleting VLAN for tenant:", tenantName)
 
-	responseBody, err := httpClient.SendRequest(context.Background(), "DELETE", fmt.Sprintf("/fabric/vlans/%s", vlan), nil)
+	err := DeletePortPolicy(ctx, fabric, inv, tenantName)
 	if err != nil {
-		log.Printf("cannot delete VLAN: %s %+v", responseBody, err)
 		return err
 	}
 
-	log.Printf("deleted VLAN %s", vlan)
-	return nil
-}
-
-// DeletePortPolicy deletes port policy
-func DeletePortPolicy(portPolicy string) error {
-	httpClient := httpclient.NewClient(models.BaseURL)
-
-	responseBody, err := httpClient.SendRequest(context.Background(), "DELETE", portPolicy, nil)
+	owned, err := inv.Load(ctx)
 	if err != nil {
-		log.Printf("cannot delete port policy: %s %+v", responseBody, err)
+		log.Printf("cannot load inventory: %+v", err)
 		return err
 	}
+	if !owned.VLAN(vlanID) {
+		log.Printf("VLAN %d is not owned by the operator. not deleting it", vlanID)
+		return nil
+	}
 
-	log.Printf("deleted port policy %s", portPolicy)
-	return nil
-}
-
-// RemovePortPolicyFromEdgePort removes port policy from edge port
-func RemovePortPolicyFromEdgePort(edgePort string, portPolicy string) error {
-
-	port, err := fm.GetPort(edgePort)
+	//delete the VLAN
+	err = httpclient.IgnoreNotFound(fabric.VLANs().Delete(ctx, vlanID))
 	if err != nil {
-		log.Printf("cannot get port details for port: %+v", err)
+		log.Printf("cannot delete VLAN: %+v", err)
+		WarningEvent(ctx, EventVLANDeleteFailed, "cannot delete VLAN %d: %v", vlanID, err)
 		return err
 	}
-
-	portPolicyLinks := port.PortPolicyLinks
-	newPortPolicyLinks := []string{}
-	for _, policy := range portPolicyLinks {
-		if policy != portPolicy {
-			newPortPolicyLinks = append(newPortPolicyLinks, policy)
-		}
-	}
-
-	var portpolicylinksPATCHRequest models.PortPATCHRequest
-
-	portpolicylinksPATCHRequest.PortPolicyLinks = newPortPolicyLinks
-
-	httpClient := httpclient.NewClient(models.BaseURL)
-	// send PATCH request to /fabric/ports/{edgePort} to remove port policy
-	responseBody, err := httpClient.SendRequest(context.Background(), "PATCH", "/fabric/ports/"+edgePort, portpolicylinksPATCHRequest)
+	err = inv.ForgetVLAN(ctx, vlanID)
 	if err != nil {
-		log.Printf("cannot remove port policy from edge port: %s %+v", responseBody, err)
 		return err
 	}
+	log.Printf("deleted VLAN %d", vlanID)
+	NormalEvent(ctx, EventVLANDeleted, "deleted VLAN %d", vlanID)
 
-	log.Printf("removed port policy %s from edge port %s", portPolicy, edgePort)
 	return nil
 }
 
-// GetAllVNIPartitions gets all VNI partitions
-func GetAllVNIPartitions() (models.AllVNIPartitionsResponse, error) {
-	ctx := context.Background()
-
-	var vniPartitions models.AllVNIPartitionsResponse
-	httpClient := httpclient.NewClient(models.BaseURL)
-	responseBody, err := httpClient.SendRequest(ctx, "GET", "/fabric/vni/partitions", nil)
+// DeletePortPolicy removes the VLAN port policy of a tenant from the edge ports
+// and deletes it. Nothing is deleted when the port policy does not exist or
+// is not owned by the operator
+func DeletePortPolicy(ctx context.Context, fabric *fm.Client, inv *inventory.Inventory, tenantName string) error {
+	owns, err := inv.Owns(ctx, inventory.PortPolicy, tenantName)
 	if err != nil {
-		log.Printf("cannot get all VNI partitions: %s %+v", responseBody, err)
-		return vniPartitions, err
+		log.Printf("cannot load inventory: %+v", err)
+		return err
 	}
-
-	err = json.Unmarshal(responseBody, &vniPartitions)
-	if err != nil {
-		log.Printf("cannot unmarshal all VNI partitions: %s %+v", responseBody, err)
-		return vniPartitions, err
+	if !owns {
+		log.Printf("port policy %s is not owned by the operator. not deleting it", tenantName)
+		return nil
 	}
 
-	return vniPartitions, nil
-
-}
-
-// CheckVLANExists checks if VLAN exists
-func CheckVLANExists(tenant *tapms.Tenant) (bool, int, error) {
-	var vlanID int
-	vlans, err := GetVLANs()
+	//get all edge ports
+	edgePorts, err := fabric.Topology().AllEdgePorts(ctx)
 	if err != nil {
-		log.Printf("cannot get VLANs: %+v", err)
-		return false, vlanID, err
+		return err
 	}
 
-	for _, vlan := range vlans {
-		vlanID, err := strconv.Atoi(strings.Split(vlan, "/")[3])
-		if err != nil {
-			log.Printf("cannot convert VLAN ID to integer: %+v", err)
-			return false, vlanID, err
-		}
-
-		vlan, err := GetVLAN(vlanID)
-		if err != nil {
-			log.Printf("cannot get VLAN: %+v", err)
-			return false, vlanID, err
-		}
-
-		if vlan.VLANName == tenant.Spec.TenantName {
-			log.Println("VLAN exists for tenant:", tenant.Spec.TenantName, vlan.VLANName)
-			return true, vlanID, nil
-		}
+	//the edge ports are fetched in parallel, only the ones with the port policy are updated
+	portNames := make([]string, 0, len(edgePorts))
+	for _, p := range edgePorts {
+		portNames = append(portNames, p.Name)
 	}
-
-	return false, vlanID, nil
-}
-
-// DeleteVLAN deletes VLAN
-func DeleteVLAN(tenantName string, vlanID string) error {
-	log.Println("deleting VLAN for tenant:", tenantName)
-
-	//get all edge ports
-	switches, err := fm.GetAllSwitches()
+	ports, err := fabric.Crawler().Ports(ctx, portNames)
 	if err != nil {
 		return err
 	}
 
-	for _, x := range switches {
-		DFAComponents, err := fm.GetSwitch(x)
-		if err != nil {
-			return err
-		}
-
-		for _, p := range DFAComponents.EdgePortsInfo {
-			port, err := fm.GetPort(p.EdgePort)
-			if err != nil {
-				return err
-			}
-
-			for _, policy := range port.PortPolicyLinks {
-				po := strings.Split(policy, "/")[3]
-				if po == tenantName {
-					err := RemovePortPolicyFromEdgePort(p.EdgePort, policy)
-					if err != nil {
-						log.Printf("cannot remove port policy from edge port: %+v", err)
-					}
+	for i, port := range ports {
+		for _, policy := range port.PortPolicyLinks {
+			if fm.LinkName(policy) == tenantName {
+				err := RemovePortPolicyFromEdgePort(ctx, fabric, portNames[i], policy)
+				if err != nil {
+					log.Printf("cannot remove port policy from edge port: %+v", err)
 				}
 			}
-
 		}
 	}
 
-	err = DeletePortPolicy(fmt.Sprintf("/fabric/port-policies/%s", tenantName))
+	portPolicies, err := fabric.PortPolicies().List(ctx)
 	if err != nil {
-		log.Printf("cannot delete port policy: %+v", err)
+		log.Printf("cannot get port policies: %+v", err)
 		return err
 	}
 
-	//delete the VLAN
-	err = deleteVLAN(vlanID)
-	if err != nil {
-		log.Printf("cannot delete VLAN: %+v", err)
-		return err
+	for _, link := range portPolicies.DocumentLinks {
+		if link != fm.PortPolicyLink(tenantName) {
+			continue
+		}
+
+		err = httpclient.IgnoreNotFound(fabric.PortPolicies().Delete(ctx, tenantName))
+		if err != nil {
+			log.Printf("cannot delete port policy: %+v", err)
+			WarningEvent(ctx, EventVLANDeleteFailed, "cannot delete port policy %s: %v", tenantName, err)
+			return err
+		}
+		log.Printf("deleted port policy %s", link)
 	}
 
-	return nil
+	return inv.Forget(ctx, inventory.PortPolicy, tenantName)
 }
 
-// GetVlanID gets the VLAN ID for a VLAN
-func GetVlanID(ctx context.Context, tenantName string) (int, error) {
-
-	responseBody, err := httpClient.SendRequest(ctx, "GET", "/fabric/vlans", nil)
+// GetVlanID gets the ID of the VLAN created by the operator for a tenant
+func GetVlanID(ctx context.Context, fabric *fm.Client, inv *inventory.Inventory, tenantName string) (int, error) {
+	owned, err := inv.Load(ctx)
 	if err != nil {
-		log.Printf("cannot get VLANs: %s %+v", responseBody, err)
+		log.Printf("cannot load inventory: %+v", err)
 		return 0, err
 	}
 
-	var vlans models.VLANsResponse
-	err = json.Unmarshal(responseBody, &vlans)
+	vlans, err := fabric.VLANs().List(ctx)
 	if err != nil {
-		log.Printf("cannot unmarshal VLANs: %s %+v", responseBody, err)
+		log.Printf("cannot get VLANs: %+v", err)
 		return 0, err
 	}
 
 	for _, x := range vlans.DocumentLinks {
-		vlanID, err := strconv.Atoi(strings.Split(x, "/")[3])
+		vlanID, err := strconv.Atoi(fm.LinkName(x))
 		if err != nil {
 			log.Printf("cannot convert VLAN ID to integer: %+v", err)
 			return 0, err
 		}
 
-		vlan, err := GetVLAN(vlanID)
-		if err != nil {
-			log.Printf("cannot get VLAN: %+v", err)
-			return 0, err
-		}
-
-		if vlan.VLANName == tenantName {
+		if tenant, ok := owned.Tenant(inventory.VLAN, strconv.Itoa(vlanID)); ok && tenant == tenantName {
 			return vlanID, nil
 		}
 	}
@@ -1100,29 +1065,8 @@ func GetVlanID(ctx context.Context, tenantName string) (int, error) {
 	return 0, nil
 }
 
-// GetAllVNIBlocks gets all VNI blocks
-func GetAllVNIBlocks() (models.AllVNIBlocksResponse, error) {
-	ctx := context.Background()
-
-	var vniBlocks models.AllVNIBlocksResponse
-	responseBody, err := httpClient.SendRequest(ctx, "GET", "/fabric/vni/blocks", nil)
-	if err != nil {
-		log.Printf("cannot get all VNI blocks: %s %+v", responseBody, err)
-		return vniBlocks, err
-	}
-
-	err = json.Unmarshal(responseBody, &vniBlocks)
-	if err != nil {
-		log.Printf("cannot unmarshal all VNI blocks: %s %+v", responseBody, err)
-		return vniBlocks, err
-	}
-
-	return vniBlocks, nil
-
-}
-
 // CreateVNIBlock creates VNI block
-func CreateVNIBlock(ctx context.Context, tenant tapms.Tenant, sshotTenant slingshot.SlingshotTenant) (models.VNIBlockResponse, error) {
+func CreateVNIBlock(ctx context.Context, fabric *fm.Client, inv *inventory.Inventory, tenant tapms.Tenant, sshotTenant slingshot.SlingshotTenant) (models.VNIBlockResponse, error) {
 
 	//Check if VNI block name is empty
 	if sshotTenant.Spec.VNIBlockName == "" {
@@ -1130,7 +1074,7 @@ func CreateVNIBlock(ctx context.Context, tenant tapms.Tenant, sshotTenant slings
 	}
 
 	//Get VNI Partition
-	vniPartition, err := GetPartition(ctx, tenant.Spec.TenantName)
+	vniPartition, err := fabric.VNIPartitions().Get(ctx, tenant.Spec.TenantName)
 	if err != nil {
 		log.Printf("cannot get VNI partition: %+v", err)
 		return models.VNIBlockResponse{}, err
@@ -1139,14 +1083,18 @@ func CreateVNIBlock(ctx context.Context, tenant tapms.Tenant, sshotTenant slings
 	var vniBlockRequestData models.VNIBlockRequestData
 	vniBlockRequestData.VNIPartitionName = tenant.Spec.TenantName
 	vniBlockRequestData.VNIBlockName = fmt.Sprintf("%s-%s", tenant.Spec.TenantName, sshotTenant.Spec.VNIBlockName)
-	vniBlockRequestData.VNIBlockRange = vniPartition.VNIRange
+	vniBlockRequestData.VNIBlockRange, err = NormalizeVNIRanges(vniPartition.VNIRange)
+	if err != nil {
+		log.Printf("cannot parse VNI ranges of VNI partition: %+v", err)
+		return models.VNIBlockResponse{}, err
+	}
 
 	var tenantXnames []string
 	for _, t := range tenant.Spec.TenantResources {
 		tenantXnames = append(tenantXnames, t.XNames...)
 	}
 
-	edgePortDFAList, _, err := GetEdgePortDFAList(tenantXnames)
+	edgePortDFAList, _, err := GetEdgePortDFAList(ctx, fabric, tenantXnames)
 	if err != nil {
 		log.Printf("cannot get edge ports for tenant: %+v", err)
 		return models.VNIBlockResponse{}, err
@@ -1154,78 +1102,77 @@ func CreateVNIBlock(ctx context.Context, tenant tapms.Tenant, sshotTenant slings
 
 	vniBlockRequestData.PortDFAs = edgePortDFAList
 
-	// Send the request
-	vniBlockResponseBody, err := httpClient.SendRequest(ctx, "POST", "/fabric/vni/blocks", vniBlockRequestData)
+	forget, err := RecordCreate(ctx, inv, inventory.VNIBlock, vniBlockRequestData.VNIBlockName, tenant.Spec.TenantName)
 	if err != nil {
-		log.Printf("cannot create VNI block: %s %+v", vniBlockResponseBody, err)
+		log.Printf("cannot record VNI block: %+v", err)
 		return models.VNIBlockResponse{}, err
 	}
 
-	var vniBlockResponse models.VNIBlockResponse
-	err = json.Unmarshal(vniBlockResponseBody, &vniBlockResponse)
+	// Send the request
+	vniBlockResponse, err := fabric.VNIBlocks().Create(ctx, vniBlockRequestData)
+	if httpclient.IsConflict(err) {
+		vniBlockResponse, err = adoptVNIBlock(ctx, fabric, vniBlockRequestData, err)
+	}
 	if err != nil {
-		log.Printf("cannot unmarshal VNI block: %s %+v", vniBlockResponseBody, err)
+		log.Printf("cannot create VNI block: %+v", err)
+		forget(err)
+		WarningEvent(ctx, EventVNIBlockCreateFailed, "cannot create VNI block %s: %v", vniBlockRequestData.VNIBlockName, err)
 		return models.VNIBlockResponse{}, err
 	}
+	NormalEvent(ctx, EventVNIBlockCreated, "created VNI block %s with %d edge ports, enforcement task %s started", vniBlockRequestData.VNIBlockName,
+		len(edgePortDFAList), vniBlockResponse.EnforcementTaskServiceLink)
 
 	return vniBlockResponse, nil
 }
 
-// CheckVniBlockEnforceTaskServiceState checks the state of VNI block enforcement task
-func CheckVniBlockEnforceTaskServiceState(ctx context.Context, vniBlockEnforcementTaskServiceLink string) (bool, error) {
-	log.Printf("checking the state of VNI block enforcement task %s", vniBlockEnforcementTaskServiceLink)
-	var state models.VniBlockEnforcementTaskServiceState
+// NewVNIRequestData returns the VNI partition request of a slingshot tenant.
+// When vnis is not nil, the VNI ranges requested are reserved for the tenant,
+// or allocated from the pool when the slingshot tenant only requests a count
+func NewVNIRequestData(ctx context.Context, vnis *ipam.Allocator, tenantName string, sshotTenant slingshot.SlingshotTenant) (models.VNIRequestData, error) {
+	var vniRequestData models.VNIRequestData
+	vniRequestData.PartitionName = tenantName
+	vniRequestData.VNICount = sshotTenant.Spec.VNIPartition.VNICount
+	vniRequestData.VNIRange = sshotTenant.Spec.VNIPartition.VNIRange
 
-	for {
-		responseBody, err := httpClient.SendRequest(ctx, "GET", vniBlockEnforcementTaskServiceLink, nil)
-		if err != nil {
-			log.Printf("cannot get VNI block enforce task service state: %s %+v", responseBody, err)
-			return false, err
-		}
+	// Validate the VNI request data
+	err := ValidateVNIRequestData(vniRequestData)
+	if err != nil {
+		return vniRequestData, err
+	}
+	vniRequestData.VNIRange, err = NormalizeVNIRanges(vniRequestData.VNIRange)
+	if err != nil {
+		return vniRequestData, err
+	}
 
-		err = json.Unmarshal(responseBody, &state)
-		if err != nil {
-			log.Printf("cannot unmarshal VNI block enforcement task service state: %s %+v", responseBody, err)
-			return false, err
-		}
+	if vnis == nil {
+		return vniRequestData, nil
+	}
 
-		if state.TaskInfo.Stage == "FINISHED" || state.TaskInfo.Stage == "FAILED" {
-			break
+	if len(vniRequestData.VNIRange) != 0 {
+		err = vnis.Reserve(ctx, tenantName, vniRequestData.VNIRange)
+		if err != nil {
+			log.Printf("cannot reserve VNIs: %+v", err)
 		}
-
-		time.Sleep(models.WaitTime)
+		return vniRequestData, err
 	}
 
-	return state.TaskInfo.Stage == "FINISHED", nil
+	vniRanges, err := vnis.Allocate(ctx, tenantName, vniRequestData.VNICount)
+	if err != nil {
+		log.Printf("cannot allocate VNIs: %+v", err)
+		return vniRequestData, err
+	}
+	vniRequestData.VNIRange = vniRanges
 
+	return vniRequestData, nil
 }
 
+// ValidateVNIRequestData checks the VNI count and every VNI range of a VNI
+// partition request
 func ValidateVNIRequestData(vniRequestData models.VNIRequestData) error {
-	if vniRequestData.VNICount < 0 || vniRequestData.VNICount > 65535 {
+	if vniRequestData.VNICount < 0 || vniRequestData.VNICount > slingshot.MaxVNICount {
 		return fmt.Errorf("VNI count is invalid: %d", vniRequestData.VNICount)
 	}
 
-	// the VNI Range is a slice of string
-	// to compare it with integer value,
-	// we need to split the string and convert it to integer
-	// start should be greater than 0 and end should be less than 65536
-	if len(vniRequestData.VNIRange) != 0 {
-		vniRange := strings.Split(vniRequestData.VNIRange[0], "-")
-
-		startRange, err := strconv.Atoi(vniRange[0])
-		if err != nil {
-			return fmt.Errorf("VNI range is invalid: %s", vniRange[0])
-		}
-
-		endRange, err := strconv.Atoi(vniRange[1])
-		if err != nil {
-			return fmt.Errorf("VNI range is invalid: %s", vniRange[1])
-		}
-
-		if startRange < 0 || endRange > 65536 || startRange > endRange {
-			return fmt.Errorf("VNI range is invalid: %s", vniRequestData.VNIRange)
-		}
-	}
-
-	return nil
+	_, err := ParseVNIRanges(vniRequestData.VNIRange)
+	return err
 }
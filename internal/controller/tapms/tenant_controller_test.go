@@ -16,8 +16,21 @@ limitations under the License.
 package tapms
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+	tapms "github.hpe.com/hpe/sshot-net-operator/api/tapms/v1alpha2"
+	"github.hpe.com/hpe/sshot-net-operator/fm"
+	"github.hpe.com/hpe/sshot-net-operator/fm/fmtest"
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+	"github.hpe.com/hpe/sshot-net-operator/internal/ipam"
 	"github.hpe.com/hpe/sshot-net-operator/models"
 )
 
@@ -55,7 +68,28 @@ func TestValidateVNIRequestData(t *testing.T) {
 				VNICount: 10,
 				VNIRange: []string{"0-70000"},
 			},
-			expectedError: "VNI range is invalid: [0-70000]",
+			expectedError: "VNI range is invalid: 0-70000: VNIs must be between 0 and 65535",
+		},
+		{
+			name: "Valid multiple VNIRanges and single VNIs",
+			input: models.VNIRequestData{
+				VNIRange: []string{"300-400", "100", "200-299"},
+			},
+			expectedError: "",
+		},
+		{
+			name: "Invalid VNIRange (not a number)",
+			input: models.VNIRequestData{
+				VNIRange: []string{"100-200", "300-"},
+			},
+			expectedError: "VNI range is invalid: 300-: end is not a number: ",
+		},
+		{
+			name: "Invalid VNIRange (overlap)",
+			input: models.VNIRequestData{
+				VNIRange: []string{"300-400", "100-300"},
+			},
+			expectedError: "VNI ranges 100-300 and 300-400 overlap",
 		},
 		{
 			name: "Valid empty VNIRange",
@@ -71,7 +105,7 @@ func TestValidateVNIRequestData(t *testing.T) {
 				VNICount: 10,
 				VNIRange: []string{"70000-100"},
 			},
-			expectedError: "VNI range is invalid: [70000-100]",
+			expectedError: "VNI range is invalid: 70000-100: start is greater than end",
 		},
 	}
 
@@ -87,3 +121,450 @@ func TestValidateVNIRequestData(t *testing.T) {
 		})
 	}
 }
+
+// newFakeFabric starts a fake Fabric Manager with one switch and four edge
+// ports and returns it together with a client pointed at it
+func newFakeFabric(t *testing.T) (*fmtest.Server, *fm.Client) {
+	t.Helper()
+
+	server := fmtest.NewServer()
+	server.AddSwitch("x1000c2r3b0", 1, 3)
+	for i, node := range []string{"x1000c2s0b0n0", "x1000c2s0b0n1", "x1000c2s1b0n0", "x1000c2s1b0n1"} {
+		err := server.AddEdgePort("x1000c2r3b0", 100+i, fmt.Sprintf("x1000c2r3j%dp0", 100+i), node+"h0")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tenantsMap = make(map[string]tenantInfo)
+	resetTenantMetrics()
+
+	t.Cleanup(func() {
+		server.Close()
+		tenantsMap = make(map[string]tenantInfo)
+		resetTenantMetrics()
+	})
+
+	return server, fm.NewClient(httpclient.NewClient(server.URL))
+}
+
+func newTestTenants(xnames ...string) (*tapms.Tenant, slingshot.SlingshotTenant) {
+	tenant := &tapms.Tenant{
+		ObjectMeta: metav1.ObjectMeta{Name: "vcluster-blue", Generation: 1},
+		Spec: tapms.TenantSpec{
+			TenantName:      "vcluster-blue",
+			TenantResources: []tapms.TenantResources{{Type: "compute", XNames: xnames}},
+		},
+	}
+
+	sshotTenant := slingshot.SlingshotTenant{
+		ObjectMeta: metav1.ObjectMeta{Name: "vcluster-blue-slingshot", Generation: 1},
+		Spec: slingshot.SlingshotTenantSpec{
+			TenantName:   "vcluster-blue",
+			VNIBlockName: "block",
+			VNIPartition: slingshot.VNIPartition{VNIRange: []string{"2000-2009"}},
+		},
+	}
+
+	return tenant, sshotTenant
+}
+
+func TestGetEdgePortDFAList(t *testing.T) {
+	_, fabric := newFakeFabric(t)
+
+	dfas, edgePorts, err := GetEdgePortDFAList(context.Background(), fabric, []string{"x1000c2s0b0n1", "x1000c2s1b0n0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedDFAs := []int{1<<23 | 3<<18 | 101<<12, 1<<23 | 3<<18 | 102<<12}
+	if !reflect.DeepEqual(dfas, expectedDFAs) {
+		t.Errorf("expected DFAs %v, got %v", expectedDFAs, dfas)
+	}
+	expectedEdgePorts := []string{"x1000c2r3j101p0", "x1000c2r3j102p0"}
+	if !reflect.DeepEqual(edgePorts, expectedEdgePorts) {
+		t.Errorf("expected edge ports %v, got %v", expectedEdgePorts, edgePorts)
+	}
+}
+
+func TestCreateVLAN(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	inv := newInventory(newFakeClient(t))
+
+	vlan, err := CreateVLAN(ctx, fabric, inv, nil, []string{"x1000c2r3j100p0"}, "vcluster-blue", slingshot.VLANSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vlan != "/fabric/vlans/1" {
+		t.Errorf("expected VLAN /fabric/vlans/1, got %s", vlan)
+	}
+
+	portPolicy, ok := server.PortPolicy("vcluster-blue")
+	if !ok {
+		t.Fatal("port policy was not created")
+	}
+	if portPolicy.NativeVlanID != "/fabric/vlans/1" || !portPolicy.IsUntaggedAllowed {
+		t.Errorf("unexpected port policy %+v", portPolicy)
+	}
+
+	port, _ := server.Port("x1000c2r3j100p0")
+	if !reflect.DeepEqual(port.PortPolicyLinks, []string{"/fabric/port-policies/vcluster-blue"}) {
+		t.Errorf("port policy not applied to edge port: %v", port.PortPolicyLinks)
+	}
+
+	found, vlanID, err := CheckVLANExists(ctx, fabric, inv, &tapms.Tenant{Spec: tapms.TenantSpec{TenantName: "vcluster-blue"}})
+	if err != nil || !found || vlanID != 1 {
+		t.Errorf("expected VLAN 1 to exist, got %v %d %v", found, vlanID, err)
+	}
+}
+
+func TestApplyVLANPortPolicyToEdgePorts(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"site", "vcluster-blue"} {
+		_, err := fabric.PortPolicies().Create(ctx, models.VLANPortPolicyRequest{DocumentSelfLink: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := ApplyVLANPortPolicyToEdgePorts(ctx, fabric, []string{"x1000c2r3j100p0"}, models.VLANPortPolicyResponse{DocumentSelfLink: "/fabric/port-policies/site"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a policy already applied is not applied again
+	vlanPortPolicy := models.VLANPortPolicyResponse{DocumentSelfLink: "/fabric/port-policies/vcluster-blue"}
+	for i := 0; i < 2; i++ {
+		err := ApplyVLANPortPolicyToEdgePorts(ctx, fabric, []string{"x1000c2r3j100p0"}, vlanPortPolicy)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	port, _ := server.Port("x1000c2r3j100p0")
+	expected := []string{"/fabric/port-policies/vcluster-blue", "/fabric/port-policies/site"}
+	if !reflect.DeepEqual(port.PortPolicyLinks, expected) {
+		t.Errorf("expected port policies %v, got %v", expected, port.PortPolicyLinks)
+	}
+}
+
+func TestAllocateVLAN(t *testing.T) {
+	_, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	k8sClient := newFakeClient(t)
+	inv := newInventory(k8sClient)
+	vlans := ipam.NewVLANAllocator(k8sClient, "sshot-net-operator", "sshot-net-operator-vlan-allocations", []int{5, 6, 7}, AdoptVLANAllocations(inv))
+
+	// a VLAN created outside of the operator is skipped
+	_, err := fabric.VLANs().Create(ctx, models.VLANRequestData{VLANName: "other", VLANID: 5, Status: "ONLINE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vlan, err := CreateVLAN(ctx, fabric, inv, vlans, []string{"x1000c2r3j100p0"}, "vcluster-blue", slingshot.VLANSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vlan != "/fabric/vlans/6" {
+		t.Errorf("expected VLAN /fabric/vlans/6, got %s", vlan)
+	}
+	vlan, err = CreateVLAN(ctx, fabric, inv, vlans, []string{"x1000c2r3j101p0"}, "vcluster-red", slingshot.VLANSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vlan != "/fabric/vlans/7" {
+		t.Errorf("expected VLAN /fabric/vlans/7, got %s", vlan)
+	}
+
+	// the pool is exhausted until a tenant is deleted
+	_, err = CreateVLAN(ctx, fabric, inv, vlans, []string{"x1000c2r3j102p0"}, "vcluster-green", slingshot.VLANSpec{})
+	if err == nil || !strings.Contains(err.Error(), "VLAN pool exhausted") {
+		t.Fatalf("expected the VLAN pool to be exhausted, got %v", err)
+	}
+	err = TeardownTenant(ctx, k8sClient, fabric, inv, nil, vlans, "vcluster-blue", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vlan, err = CreateVLAN(ctx, fabric, inv, vlans, []string{"x1000c2r3j102p0"}, "vcluster-green", slingshot.VLANSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vlan != "/fabric/vlans/6" {
+		t.Errorf("expected the released VLAN /fabric/vlans/6, got %s", vlan)
+	}
+}
+
+func TestCreateRequestedVLAN(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	k8sClient := newFakeClient(t)
+	inv := newInventory(k8sClient)
+	vlans := ipam.NewVLANAllocator(k8sClient, "sshot-net-operator", "sshot-net-operator-vlan-allocations", []int{5, 6, 7}, nil)
+
+	// the requested VLAN ID does not have to be part of the pool
+	vlanSpec := slingshot.VLANSpec{ID: 100, Mode: slingshot.VLANModeTagged, AllowedVLANs: []int{200, 100}, Status: slingshot.VLANStatusOffline}
+	vlan, err := CreateVLAN(ctx, fabric, inv, vlans, []string{"x1000c2r3j100p0"}, "vcluster-blue", vlanSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vlan != "/fabric/vlans/100" {
+		t.Errorf("expected VLAN /fabric/vlans/100, got %s", vlan)
+	}
+	if vlans := server.VLANs(); len(vlans) != 1 || vlans[0].Status != slingshot.VLANStatusOffline {
+		t.Errorf("expected VLAN 100 to be offline, got %+v", vlans)
+	}
+
+	portPolicy, ok := server.PortPolicy("vcluster-blue")
+	if !ok {
+		t.Fatal("port policy was not created")
+	}
+	if portPolicy.NativeVlanID != "" || portPolicy.IsUntaggedAllowed ||
+		!reflect.DeepEqual(portPolicy.AllowedVlans, []string{"/fabric/vlans/100", "/fabric/vlans/200"}) {
+		t.Errorf("unexpected tagged port policy %+v", portPolicy)
+	}
+
+	// a VLAN ID in use is not taken over
+	_, err = CreateVLAN(ctx, fabric, inv, vlans, []string{"x1000c2r3j101p0"}, "vcluster-red", slingshot.VLANSpec{ID: 100})
+	if err == nil || !strings.Contains(err.Error(), "VLAN ID 100 requested by tenant vcluster-red is already in use") {
+		t.Errorf("expected the VLAN ID to conflict, got %v", err)
+	}
+
+	allocations, err := vlans.Allocations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(allocations, map[string]int{"vcluster-blue": 100}) {
+		t.Errorf("expected the requested VLAN ID to be reserved, got %v", allocations)
+	}
+}
+
+func TestUpdateVLAN(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	inv := newInventory(newFakeClient(t))
+
+	_, err := CreateVLAN(ctx, fabric, inv, nil, []string{"x1000c2r3j100p0"}, "vcluster-blue", slingshot.VLANSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing is updated when the VLAN matches the spec
+	requests := len(server.Requests())
+	err = UpdateVLAN(ctx, fabric, inv, 1, "vcluster-blue", slingshot.VLANSpec{Mode: slingshot.VLANModeUntagged})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, request := range server.Requests()[requests:] {
+		if strings.HasPrefix(request, "PATCH") {
+			t.Errorf("unexpected request %s", request)
+		}
+	}
+
+	vlanSpec := slingshot.VLANSpec{Mode: slingshot.VLANModeTagged, AllowedVLANs: []int{20}, Status: slingshot.VLANStatusOffline}
+	err = UpdateVLAN(ctx, fabric, inv, 1, "vcluster-blue", vlanSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if vlans := server.VLANs(); len(vlans) != 1 || vlans[0].Status != slingshot.VLANStatusOffline {
+		t.Errorf("expected VLAN 1 to be offline, got %+v", vlans)
+	}
+	portPolicy, _ := server.PortPolicy("vcluster-blue")
+	if portPolicy.NativeVlanID != "" || portPolicy.IsUntaggedAllowed ||
+		!reflect.DeepEqual(portPolicy.AllowedVlans, []string{"/fabric/vlans/1", "/fabric/vlans/20"}) {
+		t.Errorf("unexpected port policy %+v", portPolicy)
+	}
+}
+
+func TestHandleCreateAndDelete(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	inv := newInventory(newFakeClient(t))
+	tenant, sshotTenant := newTestTenants("x1000c2s0b0n0", "x1000c2s0b0n1")
+
+	err := HandleCreate(ctx, fabric, inv, nil, tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vniPartition, ok := server.VNIPartition("vcluster-blue")
+	if !ok {
+		t.Fatal("VNI partition was not created")
+	}
+	if !reflect.DeepEqual(vniPartition.VNIRange, []string{"2000-2009"}) || len(vniPartition.EdgePortDFA) != 2 {
+		t.Errorf("unexpected VNI partition %+v", vniPartition)
+	}
+
+	vniBlock, err := CreateVNIBlock(ctx, fabric, inv, *tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vniBlock.VNIBlockName != "vcluster-blue-block" || len(vniBlock.PortDFAs) != 2 {
+		t.Errorf("unexpected VNI block %+v", vniBlock)
+	}
+
+	state, err := fabric.VNIBlocks().EnforcementTask(ctx, vniBlock.EnforcementTaskServiceLink)
+	if err != nil || state.TaskInfo.Stage != EnforcementStageFinished {
+		t.Errorf("expected enforcement to finish, got %q %v", state.TaskInfo.Stage, err)
+	}
+
+	err = HandleDelete(ctx, fabric, inv, "vcluster-blue", "vcluster-blue-block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.VNIBlock("vcluster-blue-block"); ok {
+		t.Error("VNI block was not deleted")
+	}
+	if _, ok := server.VNIPartition("vcluster-blue"); ok {
+		t.Error("VNI partition was not deleted")
+	}
+}
+
+func TestHandleUpdate(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	inv := newInventory(newFakeClient(t))
+	tenant, sshotTenant := newTestTenants("x1000c2s0b0n0")
+
+	err := HandleCreate(ctx, fabric, inv, nil, tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = CreateVNIBlock(ctx, fabric, inv, *tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = CreateVLAN(ctx, fabric, inv, nil, []string{"x1000c2r3j100p0"}, tenant.Spec.TenantName, slingshot.VLANSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantsMap[tenant.Name] = tenantInfo{
+		tenantName:       tenant.Spec.TenantName,
+		tenantGeneration: tenant.Generation,
+		tenantXnames:     []string{"x1000c2s0b0n0"},
+	}
+
+	// move the tenant to a different node
+	tenant.Spec.TenantResources[0].XNames = []string{"x1000c2s1b0n1"}
+	tenant.Generation++
+
+	err = HandleUpdate(ctx, fabric, inv, nil, nil, tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedDFAs := []int{1<<23 | 3<<18 | 103<<12}
+	vniPartition, _ := server.VNIPartition("vcluster-blue")
+	if !reflect.DeepEqual(vniPartition.EdgePortDFA, expectedDFAs) {
+		t.Errorf("expected partition DFAs %v, got %v", expectedDFAs, vniPartition.EdgePortDFA)
+	}
+	vniBlock, _ := server.VNIBlock("vcluster-blue-block")
+	if !reflect.DeepEqual(vniBlock.PortDFAs, expectedDFAs) {
+		t.Errorf("expected block DFAs %v, got %v", expectedDFAs, vniBlock.PortDFAs)
+	}
+
+	oldPort, _ := server.Port("x1000c2r3j100p0")
+	if len(oldPort.PortPolicyLinks) != 0 {
+		t.Errorf("expected port policy to be removed from old edge port, got %v", oldPort.PortPolicyLinks)
+	}
+	newPort, _ := server.Port("x1000c2r3j103p0")
+	if !reflect.DeepEqual(newPort.PortPolicyLinks, []string{"/fabric/port-policies/vcluster-blue"}) {
+		t.Errorf("expected port policy on new edge port, got %v", newPort.PortPolicyLinks)
+	}
+
+	vlans := server.VLANs()
+	if len(vlans) != 1 || vlans[0].VLANName != "vcluster-blue" {
+		t.Errorf("expected a single VLAN for the tenant, got %+v", vlans)
+	}
+}
+
+func TestHandleUpdateRecreate(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	inv := newInventory(newFakeClient(t))
+	tenant, sshotTenant := newTestTenants("x1000c2s0b0n0")
+	createTenantNetwork(t, fabric, inv, tenant, sshotTenant)
+	tenantsMap[tenant.Name] = tenantInfo{
+		tenantName:       tenant.Spec.TenantName,
+		tenantGeneration: tenant.Generation,
+		tenantXnames:     []string{"x1000c2s0b0n0"},
+	}
+
+	// the VNI partition cannot be updated, so the tenant network is created again
+	tenant.Spec.TenantResources[0].XNames = []string{"x1000c2s1b0n1"}
+	tenant.Generation++
+	server.FailNext(http.MethodPatch, "/fabric/vni/partitions/vcluster-blue", http.StatusBadRequest)
+
+	err := HandleUpdate(ctx, fabric, inv, nil, nil, tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedDFAs := []int{1<<23 | 3<<18 | 103<<12}
+	vniPartition, _ := server.VNIPartition("vcluster-blue")
+	if !reflect.DeepEqual(vniPartition.EdgePortDFA, expectedDFAs) {
+		t.Errorf("expected partition DFAs %v, got %v", expectedDFAs, vniPartition.EdgePortDFA)
+	}
+	vniBlock, ok := server.VNIBlock("vcluster-blue-block")
+	if !ok || !reflect.DeepEqual(vniBlock.PortDFAs, expectedDFAs) {
+		t.Errorf("expected the VNI block to be created again with DFAs %v, got %+v", expectedDFAs, vniBlock)
+	}
+	newPort, _ := server.Port("x1000c2r3j103p0")
+	if !reflect.DeepEqual(newPort.PortPolicyLinks, []string{"/fabric/port-policies/vcluster-blue"}) {
+		t.Errorf("expected port policy on new edge port, got %v", newPort.PortPolicyLinks)
+	}
+	if vlans := server.VLANs(); len(vlans) != 1 || vlans[0].VLANName != "vcluster-blue" {
+		t.Errorf("expected the VLAN to be created again, got %+v", vlans)
+	}
+	for _, r := range server.Requests() {
+		if strings.HasPrefix(r, "PATCH /fabric/vni/blocks/") {
+			t.Errorf("expected the VNI block to be created, not patched: %s", r)
+		}
+	}
+}
+
+func TestAllocateVNIs(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	k8sClient := newFakeClient(t)
+	inv := newInventory(k8sClient)
+	pool, err := ipam.ParsePool([]string{"1000-1099"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vnis := ipam.New(k8sClient, "sshot-net-operator", "sshot-net-operator-vni-allocations", pool, AdoptVNIAllocations(fabric, inv))
+
+	// the VNIs of a tenant requesting a count are allocated from the pool
+	tenant, sshotTenant := newTestTenants("x1000c2s0b0n0")
+	sshotTenant.Spec.VNIPartition = slingshot.VNIPartition{VNICount: 10}
+	err = HandleCreate(ctx, fabric, inv, vnis, tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vniPartition, _ := server.VNIPartition("vcluster-blue")
+	if !reflect.DeepEqual(vniPartition.VNIRange, []string{"1000-1009"}) {
+		t.Errorf("expected the VNIs to be allocated from the pool, got %+v", vniPartition)
+	}
+
+	// requested ranges that overlap the VNIs of another tenant are rejected
+	_, sshotTenant = newTestTenants()
+	sshotTenant.Spec.VNIPartition = slingshot.VNIPartition{VNIRange: []string{"1005-1014"}}
+	_, err = NewVNIRequestData(ctx, vnis, "vcluster-red", sshotTenant)
+	if err == nil || !strings.Contains(err.Error(), "overlaps with VNI range 1000-1009 of tenant vcluster-blue") {
+		t.Errorf("expected the overlap to be rejected, got %v", err)
+	}
+
+	err = TeardownTenant(ctx, k8sClient, fabric, inv, vnis, nil, "vcluster-blue", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allocations, err := vnis.Allocations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allocations) != 0 {
+		t.Errorf("expected the VNIs to be released, got %v", allocations)
+	}
+}
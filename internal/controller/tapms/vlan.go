@@ -0,0 +1,197 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package tapms
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+	"github.hpe.com/hpe/sshot-net-operator/fm"
+	"github.hpe.com/hpe/sshot-net-operator/internal/inventory"
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+// VLANStatus returns the Fabric Manager status of the VLAN of a tenant
+func VLANStatus(vlanSpec slingshot.VLANSpec) string {
+	if vlanSpec.Status == "" {
+		return slingshot.VLANStatusOnline
+	}
+
+	return vlanSpec.Status
+}
+
+// NewVLANPortPolicyRequest returns the port policy of a tenant VLAN. In
+// untagged mode the VLAN is the native VLAN of the edge ports; in tagged mode
+// only tagged traffic is allowed. The additional VLANs of the spec are allowed
+// after the tenant VLAN
+func NewVLANPortPolicyRequest(vlanID int, tenantName string, vlanSpec slingshot.VLANSpec) models.VLANPortPolicyRequest {
+	var request models.VLANPortPolicyRequest
+	request.DocumentSelfLink = tenantName
+	if vlanSpec.Mode != slingshot.VLANModeTagged {
+		request.NativeVlanID = fm.VLANLink(vlanID)
+		request.IsUntaggedAllowed = true
+	}
+
+	request.AllowedVlans = append(request.AllowedVlans, fm.VLANLink(vlanID))
+	for _, allowed := range vlanSpec.AllowedVLANs {
+		if allowed != vlanID {
+			request.AllowedVlans = append(request.AllowedVlans, fm.VLANLink(allowed))
+		}
+	}
+
+	if policy := vlanSpec.AutoRetry; policy != nil {
+		request.AutoRetry = &models.AutoRetry{Enabled: policy.Enabled, Always: policy.Always, NumRetries: policy.Retries, DurationSec: policy.DurationSeconds}
+	}
+	if policy := vlanSpec.HeadShellReset; policy != nil {
+		request.HeadShellReset = &models.HeadShellReset{Enabled: policy.Enabled, Always: policy.Always, NumRetries: policy.Retries, DurationSec: policy.DurationSeconds}
+	}
+
+	return request
+}
+
+// VLANPortPolicyMatches checks if a port policy has the settings of a request
+func VLANPortPolicyMatches(portPolicy models.PortPolicyResponse, request models.VLANPortPolicyRequest) bool {
+	return len(portPolicyDiff(portPolicy, request)) == 0
+}
+
+// portPolicyDiff returns the fields of a port policy that differ from a
+// request. The recovery policies that are not set in the request are ignored
+func portPolicyDiff(portPolicy models.PortPolicyResponse, request models.VLANPortPolicyRequest) []string {
+	var fields []string
+	if portPolicy.NativeVlanID != request.NativeVlanID {
+		fields = append(fields, "nativeVlanId")
+	}
+	if portPolicy.IsUntaggedAllowed != request.IsUntaggedAllowed {
+		fields = append(fields, "isUntaggedAllowed")
+	}
+	if strings.Join(portPolicy.AllowedVlans, ",") != strings.Join(request.AllowedVlans, ",") {
+		fields = append(fields, "allowedVlans")
+	}
+	if request.AutoRetry != nil && portPolicy.AutoRetry != *request.AutoRetry {
+		fields = append(fields, "autoRetry")
+	}
+	if request.HeadShellReset != nil && portPolicy.HeadShellReset != *request.HeadShellReset {
+		fields = append(fields, "headShellReset")
+	}
+
+	return fields
+}
+
+// RevertsDrift checks if the VLAN of a tenant and its port policy must be
+// updated to match the VLAN spec. With the Report drift policy, they are only
+// updated when the spec changed since it was last applied
+func RevertsDrift(sshotTenant slingshot.SlingshotTenant) bool {
+	if sshotTenant.Spec.VLAN.DriftPolicy != slingshot.DriftPolicyReport {
+		return true
+	}
+
+	return !equality.Semantic.DeepEqual(sshotTenant.Status.AppliedVLAN, &sshotTenant.Spec.VLAN)
+}
+
+// RecordAppliedVLAN records the VLAN spec of a slingshot tenant as applied to Fabric Manager
+func RecordAppliedVLAN(ctx context.Context, c client.Client, sshotTenant *slingshot.SlingshotTenant) error {
+	if equality.Semantic.DeepEqual(sshotTenant.Status.AppliedVLAN, &sshotTenant.Spec.VLAN) {
+		return nil
+	}
+
+	original := sshotTenant.DeepCopy()
+	sshotTenant.Status.AppliedVLAN = sshotTenant.Spec.VLAN.DeepCopy()
+	err := c.Status().Patch(ctx, sshotTenant, client.MergeFrom(original))
+	if err != nil {
+		return fmt.Errorf("cannot record applied VLAN of slingshot tenant %s: %w", sshotTenant.Name, err)
+	}
+
+	return nil
+}
+
+// UpdateVLAN updates the status of the VLAN of a tenant and its port policy
+// when they do not match the VLAN spec. A port policy that does not exist or
+// is not owned by the operator is not updated
+func UpdateVLAN(ctx context.Context, fabric *fm.Client, inv *inventory.Inventory, vlanID int, tenantName string, vlanSpec slingshot.VLANSpec) error {
+	vlan, err := fabric.VLANs().Get(ctx, vlanID)
+	if err != nil {
+		log.Printf("cannot get VLAN: %+v", err)
+		return err
+	}
+
+	if vlan.Status != VLANStatus(vlanSpec) {
+		_, err := fabric.VLANs().Patch(ctx, vlanID, models.VLANPatchRequest{Status: VLANStatus(vlanSpec)})
+		if err != nil {
+			log.Printf("cannot update VLAN status: %+v", err)
+			WarningEvent(ctx, EventVLANUpdateFailed, "cannot update status of VLAN %d: %v", vlanID, err)
+			return err
+		}
+		log.Printf("updated status of VLAN %d from %s to %s", vlanID, vlan.Status, VLANStatus(vlanSpec))
+		NormalEvent(ctx, EventVLANUpdated, "updated status of VLAN %d from %s to %s", vlanID, vlan.Status, VLANStatus(vlanSpec))
+	}
+
+	owns, err := inv.Owns(ctx, inventory.PortPolicy, tenantName)
+	if err != nil {
+		log.Printf("cannot load inventory: %+v", err)
+		return err
+	}
+	if !owns {
+		return nil
+	}
+
+	portPolicies, err := fabric.PortPolicies().List(ctx)
+	if err != nil {
+		log.Printf("cannot get port policies: %+v", err)
+		return err
+	}
+
+	for _, link := range portPolicies.DocumentLinks {
+		if link != fm.PortPolicyLink(tenantName) {
+			continue
+		}
+
+		portPolicy, err := fabric.PortPolicies().Get(ctx, tenantName)
+		if err != nil {
+			log.Printf("cannot get port policy: %+v", err)
+			return err
+		}
+
+		request := NewVLANPortPolicyRequest(vlanID, tenantName, vlanSpec)
+		if VLANPortPolicyMatches(portPolicy, request) {
+			return nil
+		}
+
+		_, err = fabric.PortPolicies().Patch(ctx, tenantName, models.VLANPortPolicyPatchRequest{
+			AutoRetry:         request.AutoRetry,
+			HeadShellReset:    request.HeadShellReset,
+			AllowedVlans:      request.AllowedVlans,
+			NativeVlanID:      request.NativeVlanID,
+			IsUntaggedAllowed: request.IsUntaggedAllowed,
+		})
+		if err != nil {
+			log.Printf("cannot update port policy: %+v", err)
+			WarningEvent(ctx, EventVLANUpdateFailed, "cannot update port policy %s for VLAN %d: %v", tenantName, vlanID, err)
+			return err
+		}
+		log.Printf("updated %s of port policy %s for VLAN %d", strings.Join(portPolicyDiff(portPolicy, request), ", "), link, vlanID)
+		NormalEvent(ctx, EventVLANUpdated, "updated %s of port policy %s for VLAN %d", strings.Join(portPolicyDiff(portPolicy, request), ", "), tenantName, vlanID)
+	}
+
+	return nil
+}
+
+// vlanDrift describes how the VLAN of a tenant and its port policy differ
+// from the VLAN spec, or returns an empty string when they match
+func vlanDrift(vlan models.VLANResponse, portPolicy models.PortPolicyResponse, tenantName string, vlanSpec slingshot.VLANSpec) string {
+	if vlan.Status != VLANStatus(vlanSpec) {
+		return fmt.Sprintf("VLAN %d is %s instead of %s", vlan.VLANID, vlan.Status, VLANStatus(vlanSpec))
+	}
+	if fields := portPolicyDiff(portPolicy, NewVLANPortPolicyRequest(vlan.VLANID, tenantName, vlanSpec)); len(fields) > 0 {
+		return fmt.Sprintf("%s of the port policy for VLAN %d do not match the VLAN spec", strings.Join(fields, ", "), vlan.VLANID)
+	}
+
+	return ""
+}
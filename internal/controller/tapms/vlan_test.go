@@ -0,0 +1,133 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package tapms
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+func TestLinkRecoveryPolicies(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	inv := newInventory(newFakeClient(t))
+
+	vlanSpec := slingshot.VLANSpec{
+		AutoRetry:      &slingshot.LinkRecoveryPolicy{Enabled: true, Retries: 3, DurationSeconds: 60},
+		HeadShellReset: &slingshot.LinkRecoveryPolicy{Enabled: true, Always: true},
+	}
+	_, err := CreateVLAN(ctx, fabric, inv, nil, []string{"x1000c2r3j100p0"}, "vcluster-blue", vlanSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	portPolicy, _ := server.PortPolicy("vcluster-blue")
+	expectedAutoRetry := models.AutoRetry{Enabled: true, NumRetries: 3, DurationSec: 60}
+	expectedHeadShellReset := models.HeadShellReset{Enabled: true, Always: true}
+	if portPolicy.AutoRetry != expectedAutoRetry || portPolicy.HeadShellReset != expectedHeadShellReset {
+		t.Errorf("unexpected recovery policies %+v %+v", portPolicy.AutoRetry, portPolicy.HeadShellReset)
+	}
+
+	// a change made on the fabric is detected
+	_, err = fabric.PortPolicies().Patch(ctx, "vcluster-blue", models.VLANPortPolicyPatchRequest{
+		AutoRetry:         &models.AutoRetry{},
+		AllowedVlans:      portPolicy.AllowedVlans,
+		NativeVlanID:      portPolicy.NativeVlanID,
+		IsUntaggedAllowed: portPolicy.IsUntaggedAllowed,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vlan, err := fabric.VLANs().Get(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	portPolicy, _ = server.PortPolicy("vcluster-blue")
+	drift := vlanDrift(vlan, portPolicy, "vcluster-blue", vlanSpec)
+	if !strings.Contains(drift, "autoRetry of the port policy for VLAN 1") {
+		t.Errorf("expected the auto-retry policy to drift, got %q", drift)
+	}
+
+	// and reverted
+	err = UpdateVLAN(ctx, fabric, inv, 1, "vcluster-blue", vlanSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	portPolicy, _ = server.PortPolicy("vcluster-blue")
+	if portPolicy.AutoRetry != expectedAutoRetry {
+		t.Errorf("expected the auto-retry policy to be reverted, got %+v", portPolicy.AutoRetry)
+	}
+
+	// the recovery policies that are not set are left alone
+	if drift := vlanDrift(vlan, portPolicy, "vcluster-blue", slingshot.VLANSpec{}); drift != "" {
+		t.Errorf("expected no drift, got %q", drift)
+	}
+}
+
+func TestDriftPolicy(t *testing.T) {
+	_, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	_, sshotTenant := newTestTenants("x1000c2s0b0n0")
+	sshotTenant.Spec.VLAN = slingshot.VLANSpec{DriftPolicy: slingshot.DriftPolicyReport}
+	k8sClient := newFakeClient(t, &sshotTenant)
+	inv := newInventory(k8sClient)
+
+	// a spec that was not applied yet is applied
+	if !RevertsDrift(sshotTenant) {
+		t.Error("expected the new VLAN spec to be applied")
+	}
+	_, err := CreateVLAN(ctx, fabric, inv, nil, []string{"x1000c2r3j100p0"}, sshotTenant.Spec.TenantName, sshotTenant.Spec.VLAN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = RecordAppliedVLAN(ctx, k8sClient, &sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var updated slingshot.SlingshotTenant
+	err = k8sClient.Get(ctx, client.ObjectKeyFromObject(&sshotTenant), &updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.AppliedVLAN == nil || updated.Status.AppliedVLAN.DriftPolicy != slingshot.DriftPolicyReport {
+		t.Fatalf("expected the applied VLAN spec to be recorded, got %+v", updated.Status.AppliedVLAN)
+	}
+
+	// a change made on the fabric is only reported
+	_, err = fabric.VLANs().Patch(ctx, 1, models.VLANPatchRequest{Status: slingshot.VLANStatusOffline})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if RevertsDrift(updated) {
+		t.Error("expected the drift to be reported only")
+	}
+	observeVLAN(ctx, fabric, inv, &updated)
+	condition := meta.FindStatusCondition(updated.Status.Conditions, slingshot.ConditionVLANReady)
+	if condition == nil || condition.Reason != ReasonOutOfSync || !strings.Contains(condition.Message, "VLAN 1 is OFFLINE instead of ONLINE") {
+		t.Errorf("expected the drift to be reported, got %+v", condition)
+	}
+
+	// a spec change is applied
+	updated.Spec.VLAN.Status = slingshot.VLANStatusOffline
+	if !RevertsDrift(updated) {
+		t.Error("expected the changed VLAN spec to be applied")
+	}
+
+	// the Revert drift policy always applies the spec
+	updated.Spec.VLAN = *updated.Status.AppliedVLAN
+	updated.Spec.VLAN.DriftPolicy = slingshot.DriftPolicyRevert
+	updated.Status.AppliedVLAN = updated.Spec.VLAN.DeepCopy()
+	if !RevertsDrift(updated) {
+		t.Error("expected the drift to be reverted")
+	}
+}
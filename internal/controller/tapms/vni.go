@@ -0,0 +1,79 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package tapms
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+	"github.hpe.com/hpe/sshot-net-operator/fm"
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+)
+
+// appliedVNI returns the VNI partition and VNI block spec of a slingshot tenant
+func appliedVNI(sshotTenant slingshot.SlingshotTenant) *slingshot.AppliedVNISpec {
+	return &slingshot.AppliedVNISpec{
+		VNIPartition: *sshotTenant.Spec.VNIPartition.DeepCopy(),
+		VNIBlockName: sshotTenant.Spec.VNIBlockName,
+	}
+}
+
+// VNISpecChanged checks if the VNI partition and VNI block of a slingshot
+// tenant must be recreated because their spec changed since it was last
+// applied. When it was never recorded, the spec is compared with the VNI
+// partition and VNI block in Fabric Manager
+func VNISpecChanged(ctx context.Context, fabric *fm.Client, sshotTenant slingshot.SlingshotTenant) (bool, error) {
+	if sshotTenant.Status.AppliedVNI != nil {
+		return !equality.Semantic.DeepEqual(sshotTenant.Status.AppliedVNI, appliedVNI(sshotTenant)), nil
+	}
+
+	vniPartition, err := fabric.VNIPartitions().Get(ctx, sshotTenant.Spec.TenantName)
+	if err != nil {
+		return false, err
+	}
+	if len(sshotTenant.Spec.VNIPartition.VNIRange) != 0 {
+		vniRanges, err := NormalizeVNIRanges(sshotTenant.Spec.VNIPartition.VNIRange)
+		if err != nil {
+			return false, err
+		}
+		partitionRanges, err := NormalizeVNIRanges(vniPartition.VNIRange)
+		if err != nil || !reflect.DeepEqual(vniRanges, partitionRanges) {
+			return true, nil
+		}
+	} else if vniPartition.VNICount != sshotTenant.Spec.VNIPartition.VNICount {
+		return true, nil
+	}
+
+	vniBlockName := fmt.Sprintf("%s-%s", sshotTenant.Spec.TenantName, sshotTenant.Spec.VNIBlockName)
+	_, err = fabric.VNIBlocks().Get(ctx, vniBlockName)
+	if httpclient.IsNotFound(err) {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// RecordAppliedVNI records the VNI partition and VNI block spec of a slingshot
+// tenant as applied to Fabric Manager
+func RecordAppliedVNI(ctx context.Context, c client.Client, sshotTenant *slingshot.SlingshotTenant) error {
+	applied := appliedVNI(*sshotTenant)
+	if equality.Semantic.DeepEqual(sshotTenant.Status.AppliedVNI, applied) {
+		return nil
+	}
+
+	original := sshotTenant.DeepCopy()
+	sshotTenant.Status.AppliedVNI = applied
+	err := c.Status().Patch(ctx, sshotTenant, client.MergeFrom(original))
+	if err != nil {
+		return fmt.Errorf("cannot record applied VNI of slingshot tenant %s: %w", sshotTenant.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package tapms
+
+import (
+	"fmt"
+	"sort"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+// ParseVNIRanges parses the VNI ranges of a VNI partition. Each range is
+// either "start-end" or a single VNI. The ranges are returned sorted, with the
+// adjacent ranges merged, and must not overlap
+func ParseVNIRanges(vniRanges []string) ([]models.VniRange, error) {
+	ranges := make([]models.VniRange, 0, len(vniRanges))
+	for _, vniRange := range vniRanges {
+		r, err := slingshot.ParseVNIRange(vniRange)
+		if err != nil {
+			return nil, fmt.Errorf("VNI range is invalid: %s: %w", vniRange, err)
+		}
+		ranges = append(ranges, models.VniRange{Start: r.Start, End: r.End})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+
+	var merged []models.VniRange
+	for _, r := range ranges {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			if r.Start <= last.End {
+				return nil, fmt.Errorf("VNI ranges %s and %s overlap", formatVNIRange(*last), formatVNIRange(r))
+			}
+			if r.Start == last.End+1 {
+				last.End = r.End
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+
+	return merged, nil
+}
+
+// FormatVNIRanges returns the VNI ranges in the "start-end" form Fabric Manager expects
+func FormatVNIRanges(ranges []models.VniRange) []string {
+	formatted := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		formatted = append(formatted, formatVNIRange(r))
+	}
+
+	return formatted
+}
+
+// NormalizeVNIRanges parses the VNI ranges and formats them again, so that
+// the same VNIs are always sent to Fabric Manager in the same form
+func NormalizeVNIRanges(vniRanges []string) ([]string, error) {
+	if len(vniRanges) == 0 {
+		return vniRanges, nil
+	}
+
+	ranges, err := ParseVNIRanges(vniRanges)
+	if err != nil {
+		return nil, err
+	}
+
+	return FormatVNIRanges(ranges), nil
+}
+
+func formatVNIRange(r models.VniRange) string {
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
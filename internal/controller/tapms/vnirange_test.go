@@ -0,0 +1,62 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package tapms
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+func TestParseVNIRanges(t *testing.T) {
+	ranges, err := ParseVNIRanges([]string{"500", "200-299", " 100 - 199 ", "400-450", "300"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the adjacent ranges are merged
+	expected := []models.VniRange{{Start: 100, End: 300}, {Start: 400, End: 450}, {Start: 500, End: 500}}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("expected ranges %v, got %v", expected, ranges)
+	}
+	if formatted := FormatVNIRanges(ranges); !reflect.DeepEqual(formatted, []string{"100-300", "400-450", "500-500"}) {
+		t.Errorf("unexpected formatted ranges %v", formatted)
+	}
+
+	for _, vniRanges := range [][]string{{"100-200", "150"}, {"-1"}, {"1-2-3"}, {""}, {"65536"}} {
+		if _, err := ParseVNIRanges(vniRanges); err == nil {
+			t.Errorf("expected %q to be rejected", vniRanges)
+		}
+	}
+}
+
+func TestNormalizedVNIRanges(t *testing.T) {
+	server, fabric := newFakeFabric(t)
+	ctx := context.Background()
+	inv := newInventory(newFakeClient(t))
+	tenant, sshotTenant := newTestTenants("x1000c2s0b0n0")
+	sshotTenant.Spec.VNIPartition.VNIRange = []string{"2010-2019", "2000-2009", "3000"}
+
+	err := HandleCreate(ctx, fabric, inv, nil, tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = CreateVNIBlock(ctx, fabric, inv, *tenant, sshotTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"2000-2019", "3000-3000"}
+	vniPartition, _ := server.VNIPartition("vcluster-blue")
+	if !reflect.DeepEqual(vniPartition.VNIRange, expected) || vniPartition.VNICount != 21 {
+		t.Errorf("expected partition ranges %v, got %+v", expected, vniPartition)
+	}
+	vniBlock, _ := server.VNIBlock("vcluster-blue-block")
+	if !reflect.DeepEqual(vniBlock.VNIBlockRange, expected) {
+		t.Errorf("expected block ranges %v, got %v", expected, vniBlock.VNIBlockRange)
+	}
+}
@@ -0,0 +1,378 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+// Package gc removes the Fabric Manager resources that were created by the
+// operator for a tenant that no longer exists
+package gc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+	tapmsapi "github.hpe.com/hpe/sshot-net-operator/api/tapms/v1alpha2"
+	"github.hpe.com/hpe/sshot-net-operator/fm"
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+	"github.hpe.com/hpe/sshot-net-operator/internal/controller/tapms"
+	"github.hpe.com/hpe/sshot-net-operator/internal/inventory"
+	"github.hpe.com/hpe/sshot-net-operator/internal/ipam"
+)
+
+// Kinds of the Fabric Manager resources collected
+const (
+	KindVNIBlock     = "VNIBlock"
+	KindVNIPartition = "VNIPartition"
+	KindPortPolicy   = "PortPolicy"
+	KindVLAN         = "VLAN"
+)
+
+// Orphan is a Fabric Manager resource created by the operator whose tenant no longer exists
+type Orphan struct {
+	Kind string
+	Name string
+
+	// Since is when the resource was first found orphaned
+	Since time.Time
+}
+
+func (o Orphan) String() string {
+	return o.Kind + "/" + o.Name
+}
+
+// Report is the result of a collection
+type Report struct {
+	// Orphans are all the orphaned resources found
+	Orphans []Orphan
+
+	// Deleted are the orphans deleted, or that would have been deleted in dry-run mode
+	Deleted []Orphan
+}
+
+// Collector periodically deletes orphaned Fabric Manager resources. A resource
+// is only deleted once it has been orphaned for longer than the grace period,
+// so a tenant that is being created or recreated is left alone.
+//
+// Only the resources recorded in the inventory of the operator are
+// considered; a resource is orphaned when its tenant no longer has both a
+// Tenant and a SlingshotTenant.
+type Collector struct {
+	client    client.Reader
+	fabric    *fm.Client
+	inventory *inventory.Inventory
+	vnis      *ipam.Allocator
+	vlans     *ipam.VLANAllocator
+
+	// Interval is the time between two collections
+	Interval time.Duration
+
+	// GracePeriod is how long a resource must be orphaned before it is deleted
+	GracePeriod time.Duration
+
+	// DryRun only reports the orphans that would be deleted
+	DryRun bool
+
+	mu    sync.Mutex
+	since map[string]time.Time
+	now   func() time.Time
+}
+
+// NewCollector returns a collector that reads the tenants through c and the
+// resources owned by the operator from inv. The VNIs of the deleted VNI
+// partitions and the IDs of the deleted VLANs are released from vnis and
+// vlans when they are not nil
+func NewCollector(c client.Reader, fabric *fm.Client, inv *inventory.Inventory, vnis *ipam.Allocator, vlans *ipam.VLANAllocator, interval time.Duration, gracePeriod time.Duration, dryRun bool) *Collector {
+	return &Collector{
+		client:      c,
+		fabric:      fabric,
+		inventory:   inv,
+		vnis:        vnis,
+		vlans:       vlans,
+		Interval:    interval,
+		GracePeriod: gracePeriod,
+		DryRun:      dryRun,
+		since:       make(map[string]time.Time),
+		now:         time.Now,
+	}
+}
+
+// Start runs a collection every interval until ctx is done
+func (c *Collector) Start(ctx context.Context) error {
+	log.Printf("starting fabric garbage collector, interval %s, grace period %s, dry-run %t", c.Interval, c.GracePeriod, c.DryRun)
+
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			_, err := c.Collect(ctx)
+			if err != nil {
+				log.Printf("cannot collect orphaned fabric resources: %+v", err)
+			}
+		}
+	}
+}
+
+// NeedLeaderElection makes the collector run on the leader only
+func (c *Collector) NeedLeaderElection() bool {
+	return true
+}
+
+// kinds maps the inventory kinds to the kinds collected, in the order they must be deleted
+var kinds = []struct {
+	inventory inventory.Kind
+	kind      string
+}{
+	{inventory.VNIBlock, KindVNIBlock},
+	{inventory.VNIPartition, KindVNIPartition},
+	{inventory.PortPolicy, KindPortPolicy},
+	{inventory.VLAN, KindVLAN},
+}
+
+// fabricState holds the names of the resources found in Fabric Manager, by inventory kind
+type fabricState map[inventory.Kind]map[string]bool
+
+// Collect finds the orphaned resources and deletes the ones whose grace period is over
+func (c *Collector) Collect(ctx context.Context) (Report, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var report Report
+
+	owned, err := c.inventory.Load(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	state, err := c.readFabric(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	backed, err := c.backedNetworks(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	orphans, gone := findOrphans(owned, state, backed)
+
+	// entries of deleted tenants whose resources were removed outside of the operator
+	for _, entry := range gone {
+		log.Printf("forgetting %s %s of tenant %s, it no longer exists", entry.Kind, entry.Name, entry.Tenant)
+		err := c.inventory.Forget(ctx, entry.Kind, entry.Name)
+		if err != nil {
+			return report, err
+		}
+		err = c.release(ctx, entry.Kind, entry.Tenant)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	now := c.now()
+	seen := make(map[string]time.Time)
+	for i, orphan := range orphans {
+		since, ok := c.since[orphan.String()]
+		if !ok {
+			since = now
+			log.Printf("found orphaned fabric resource %s", orphan)
+		}
+		seen[orphan.String()] = since
+		orphans[i].Since = since
+	}
+	// resources that are no longer orphaned, or no longer exist, start over
+	c.since = seen
+	report.Orphans = orphans
+
+	for _, orphan := range orphans {
+		if now.Sub(orphan.Since) < c.GracePeriod {
+			continue
+		}
+
+		if c.DryRun {
+			log.Printf("dry-run: would delete orphaned fabric resource %s, orphaned since %s", orphan, orphan.Since.Format(time.RFC3339))
+			report.Deleted = append(report.Deleted, orphan)
+			continue
+		}
+
+		err := c.delete(ctx, orphan)
+		if err != nil {
+			return report, fmt.Errorf("cannot delete orphaned fabric resource %s: %w", orphan, err)
+		}
+		log.Printf("deleted orphaned fabric resource %s", orphan)
+		delete(c.since, orphan.String())
+		report.Deleted = append(report.Deleted, orphan)
+	}
+
+	return report, nil
+}
+
+// readFabric lists the VNI partitions, VNI blocks, VLANs and port policies
+func (c *Collector) readFabric(ctx context.Context) (fabricState, error) {
+	state := make(fabricState)
+
+	vniPartitions, err := c.fabric.VNIPartitions().List(ctx)
+	if err != nil {
+		return state, err
+	}
+	state[inventory.VNIPartition] = linkNames(vniPartitions.DocumentLinks)
+
+	vniBlocks, err := c.fabric.VNIBlocks().List(ctx)
+	if err != nil {
+		return state, err
+	}
+	state[inventory.VNIBlock] = linkNames(vniBlocks.DocumentLinks)
+
+	vlans, err := c.fabric.VLANs().List(ctx)
+	if err != nil {
+		return state, err
+	}
+	state[inventory.VLAN] = linkNames(vlans.DocumentLinks)
+
+	portPolicies, err := c.fabric.PortPolicies().List(ctx)
+	if err != nil {
+		return state, err
+	}
+	state[inventory.PortPolicy] = linkNames(portPolicies.DocumentLinks)
+
+	return state, nil
+}
+
+func linkNames(links []string) map[string]bool {
+	names := make(map[string]bool, len(links))
+	for _, link := range links {
+		names[fm.LinkName(link)] = true
+	}
+
+	return names
+}
+
+// backedNetworks returns the VNI block names of the tenants that have both a
+// Tenant and a SlingshotTenant, keyed by tenant name
+func (c *Collector) backedNetworks(ctx context.Context) (map[string]string, error) {
+	backed := make(map[string]string)
+
+	var tenants tapmsapi.TenantList
+	if err := c.client.List(ctx, &tenants); err != nil {
+		return backed, fmt.Errorf("cannot list tenants: %w", err)
+	}
+
+	var sshotTenants slingshot.SlingshotTenantList
+	if err := c.client.List(ctx, &sshotTenants); err != nil {
+		return backed, fmt.Errorf("cannot list slingshot tenants: %w", err)
+	}
+
+	tenantNames := make(map[string]bool)
+	for _, tenant := range tenants.Items {
+		tenantNames[tenant.Spec.TenantName] = true
+	}
+
+	for _, sshotTenant := range sshotTenants.Items {
+		if tenantNames[sshotTenant.Spec.TenantName] {
+			backed[sshotTenant.Spec.TenantName] = fmt.Sprintf("%s-%s", sshotTenant.Spec.TenantName, sshotTenant.Spec.VNIBlockName)
+		}
+	}
+
+	return backed, nil
+}
+
+// findOrphans returns the owned resources that are not backed by a tenant, in
+// the order they must be deleted, and the owned entries of tenants that are
+// not backed whose resources no longer exist
+func findOrphans(owned inventory.Owned, state fabricState, backed map[string]string) ([]Orphan, []inventory.Entry) {
+	var orphans []Orphan
+	var gone []inventory.Entry
+
+	entries := owned.Entries()
+	for _, k := range kinds {
+		for _, entry := range entries {
+			if entry.Kind != k.inventory {
+				continue
+			}
+
+			vniBlockName, ok := backed[entry.Tenant]
+			if ok && (entry.Kind != inventory.VNIBlock || entry.Name == vniBlockName) {
+				continue
+			}
+
+			if !state[entry.Kind][entry.Name] {
+				// a resource of a backed tenant may be about to be created
+				if !ok {
+					gone = append(gone, entry)
+				}
+				continue
+			}
+
+			orphans = append(orphans, Orphan{Kind: k.kind, Name: entry.Name})
+		}
+	}
+
+	return orphans, gone
+}
+
+// delete deletes an orphaned resource and removes it from the inventory
+func (c *Collector) delete(ctx context.Context, orphan Orphan) error {
+	switch orphan.Kind {
+	case KindVNIBlock:
+		err := httpclient.IgnoreNotFound(c.fabric.VNIBlocks().Delete(ctx, orphan.Name))
+		if err != nil {
+			return err
+		}
+		return c.inventory.Forget(ctx, inventory.VNIBlock, orphan.Name)
+	case KindVNIPartition:
+		err := httpclient.IgnoreNotFound(c.fabric.VNIPartitions().Delete(ctx, orphan.Name))
+		if err != nil {
+			return err
+		}
+		err = c.inventory.Forget(ctx, inventory.VNIPartition, orphan.Name)
+		if err != nil {
+			return err
+		}
+		return c.release(ctx, inventory.VNIPartition, orphan.Name)
+	case KindPortPolicy:
+		return tapms.DeletePortPolicy(ctx, c.fabric, c.inventory, orphan.Name)
+	case KindVLAN:
+		vlanID, err := strconv.Atoi(orphan.Name)
+		if err != nil {
+			return fmt.Errorf("cannot convert VLAN ID to integer: %w", err)
+		}
+		owned, err := c.inventory.Load(ctx)
+		if err != nil {
+			return err
+		}
+		tenantName, _ := owned.Tenant(inventory.VLAN, orphan.Name)
+		err = httpclient.IgnoreNotFound(c.fabric.VLANs().Delete(ctx, vlanID))
+		if err != nil {
+			return err
+		}
+		err = c.inventory.ForgetVLAN(ctx, vlanID)
+		if err != nil {
+			return err
+		}
+		return c.release(ctx, inventory.VLAN, tenantName)
+	default:
+		return fmt.Errorf("unknown kind %s", orphan.Kind)
+	}
+}
+
+// release releases the VNIs of the tenant of a deleted VNI partition, or the
+// VLAN ID of the tenant of a deleted VLAN
+func (c *Collector) release(ctx context.Context, kind inventory.Kind, tenantName string) error {
+	switch {
+	case kind == inventory.VNIPartition && c.vnis != nil:
+		return c.vnis.Release(ctx, tenantName)
+	case kind == inventory.VLAN && c.vlans != nil && tenantName != "":
+		return c.vlans.Release(ctx, tenantName)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,203 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package gc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+	tapmsapi "github.hpe.com/hpe/sshot-net-operator/api/tapms/v1alpha2"
+	"github.hpe.com/hpe/sshot-net-operator/fm"
+	"github.hpe.com/hpe/sshot-net-operator/fm/fmtest"
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+	"github.hpe.com/hpe/sshot-net-operator/internal/controller/tapms"
+	"github.hpe.com/hpe/sshot-net-operator/internal/inventory"
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+func newTestTenants(name string, xname string) (*tapmsapi.Tenant, *slingshot.SlingshotTenant) {
+	tenant := &tapmsapi.Tenant{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: tapmsapi.TenantSpec{
+			TenantName:      name,
+			TenantResources: []tapmsapi.TenantResources{{Type: "compute", XNames: []string{xname}}},
+		},
+	}
+
+	sshotTenant := &slingshot.SlingshotTenant{
+		ObjectMeta: metav1.ObjectMeta{Name: name + "-slingshot"},
+		Spec: slingshot.SlingshotTenantSpec{
+			TenantName:   name,
+			VNIBlockName: "block",
+			VNIPartition: slingshot.VNIPartition{VNICount: 10},
+		},
+	}
+
+	return tenant, sshotTenant
+}
+
+// createNetwork creates the VNI partition, VLAN and VNI block of a tenant the way the operator does
+func createNetwork(t *testing.T, fabric *fm.Client, inv *inventory.Inventory, tenant *tapmsapi.Tenant, sshotTenant *slingshot.SlingshotTenant, edgePort string) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := tapms.HandleCreate(ctx, fabric, inv, nil, tenant, *sshotTenant); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tapms.CreateVLAN(ctx, fabric, inv, nil, []string{edgePort}, tenant.Spec.TenantName, slingshot.VLANSpec{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tapms.CreateVNIBlock(ctx, fabric, inv, *tenant, *sshotTenant); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func orphanNames(orphans []Orphan) []string {
+	var names []string
+	for _, orphan := range orphans {
+		names = append(names, orphan.String())
+	}
+
+	return names
+}
+
+func TestCollect(t *testing.T) {
+	ctx := context.Background()
+
+	server := fmtest.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSwitch("x1000c2r3b0", 1, 3)
+	for i, node := range []string{"x1000c2s0b0n0", "x1000c2s0b0n1"} {
+		if err := server.AddEdgePort("x1000c2r3b0", 100+i, fmt.Sprintf("x1000c2r3j%dp0", 100+i), node+"h0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fabric := fm.NewClient(httpclient.NewClient(server.URL))
+
+	blue, blueSlingshot := newTestTenants("vcluster-blue", "x1000c2s0b0n0")
+	red, redSlingshot := newTestTenants("vcluster-red", "x1000c2s0b0n1")
+
+	// only the blue tenant still exists
+	scheme := runtime.NewScheme()
+	if err := core.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := slingshot.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := tapmsapi.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(blue, blueSlingshot).Build()
+	inv := inventory.New(k8sClient, "sshot-net-operator", "sshot-net-operator-inventory", nil)
+
+	createNetwork(t, fabric, inv, blue, blueSlingshot, "x1000c2r3j100p0")
+	createNetwork(t, fabric, inv, red, redSlingshot, "x1000c2r3j101p0")
+
+	// resources not created by the operator are never collected, even when
+	// they follow the operator naming
+	if _, err := fabric.VNIPartitions().Create(ctx, models.VNIRequestData{PartitionName: "vcluster-green", VNICount: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fabric.VLANs().Create(ctx, models.VLANRequestData{VLANName: "vcluster-green", VLANID: 100, Status: "ONLINE"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// an owned resource deleted outside of the operator is forgotten
+	if err := inv.Record(ctx, inventory.VNIBlock, "vcluster-gone-block", "vcluster-gone"); err != nil {
+		t.Fatal(err)
+	}
+
+	collector := NewCollector(k8sClient, fabric, inv, nil, nil, time.Minute, time.Hour, true)
+	now := time.Now()
+	collector.now = func() time.Time { return now }
+
+	expected := []string{"VNIBlock/vcluster-red-block", "VNIPartition/vcluster-red", "PortPolicy/vcluster-red", "VLAN/2"}
+
+	report, err := collector.Collect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(orphanNames(report.Orphans), expected) {
+		t.Errorf("expected orphans %v, got %v", expected, orphanNames(report.Orphans))
+	}
+	if len(report.Deleted) != 0 {
+		t.Errorf("expected nothing to be deleted within the grace period, got %v", orphanNames(report.Deleted))
+	}
+	if owns, _ := inv.Owns(ctx, inventory.VNIBlock, "vcluster-gone-block"); owns {
+		t.Error("VNI block deleted outside of the operator is still in the inventory")
+	}
+
+	// dry-run only reports
+	now = now.Add(time.Hour)
+	report, err = collector.Collect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(orphanNames(report.Deleted), expected) {
+		t.Errorf("expected %v to be reported, got %v", expected, orphanNames(report.Deleted))
+	}
+	if _, ok := server.VNIPartition("vcluster-red"); !ok {
+		t.Error("VNI partition was deleted in dry-run mode")
+	}
+
+	collector.DryRun = false
+	report, err = collector.Collect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(orphanNames(report.Deleted), expected) {
+		t.Errorf("expected %v to be deleted, got %v", expected, orphanNames(report.Deleted))
+	}
+
+	if _, ok := server.VNIBlock("vcluster-red-block"); ok {
+		t.Error("orphaned VNI block was not deleted")
+	}
+	if _, ok := server.VNIPartition("vcluster-red"); ok {
+		t.Error("orphaned VNI partition was not deleted")
+	}
+	if _, ok := server.PortPolicy("vcluster-red"); ok {
+		t.Error("orphaned port policy was not deleted")
+	}
+	if port, _ := server.Port("x1000c2r3j101p0"); len(port.PortPolicyLinks) != 0 {
+		t.Errorf("orphaned port policy was not removed from the edge port: %+v", port.PortPolicyLinks)
+	}
+	for _, vlan := range server.VLANs() {
+		if vlan.VLANName == "vcluster-red" {
+			t.Error("orphaned VLAN was not deleted")
+		}
+	}
+
+	owned, err := inv.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range owned.Entries() {
+		if entry.Tenant != "vcluster-blue" {
+			t.Errorf("deleted %s %s is still in the inventory", entry.Kind, entry.Name)
+		}
+	}
+
+	for _, name := range []string{"vcluster-blue", "vcluster-green"} {
+		if _, ok := server.VNIPartition(name); !ok {
+			t.Errorf("VNI partition %s was deleted", name)
+		}
+	}
+	if _, ok := server.VNIBlock("vcluster-blue-block"); !ok {
+		t.Error("VNI block of the blue tenant was deleted")
+	}
+	if len(server.VLANs()) != 2 {
+		t.Errorf("expected the blue and green VLANs to remain, got %+v", server.VLANs())
+	}
+}
@@ -0,0 +1,233 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+// Package inventory records the Fabric Manager documents created by the
+// operator, so that only documents the operator owns are updated or deleted
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Kind is the kind of a Fabric Manager document
+type Kind string
+
+// Kinds of the Fabric Manager documents created by the operator
+const (
+	VNIPartition Kind = "vnipartition"
+	VNIBlock     Kind = "vniblock"
+	VLAN         Kind = "vlan"
+	PortPolicy   Kind = "portpolicy"
+)
+
+// Entry is a Fabric Manager document owned by the operator
+type Entry struct {
+	Kind Kind
+
+	// Name is the name of the document, or the ID for a VLAN
+	Name string
+
+	// Tenant is the name of the tenant the document was created for
+	Tenant string
+}
+
+// SeedFunc returns the entries of a new inventory. It is called once, when the
+// inventory ConfigMap does not exist yet, to adopt the documents created by a
+// previous version of the operator
+type SeedFunc func(ctx context.Context) ([]Entry, error)
+
+// Owned is a snapshot of the inventory
+type Owned map[string]string
+
+// Tenant returns the tenant a document was created for and whether it is owned
+func (o Owned) Tenant(kind Kind, name string) (string, bool) {
+	tenant, ok := o[key(kind, name)]
+	return tenant, ok
+}
+
+// Has returns true when the operator owns the document
+func (o Owned) Has(kind Kind, name string) bool {
+	_, ok := o[key(kind, name)]
+	return ok
+}
+
+// VLAN returns true when the operator owns the VLAN
+func (o Owned) VLAN(vlanID int) bool {
+	return o.Has(VLAN, strconv.Itoa(vlanID))
+}
+
+// Entries returns the entries of the snapshot sorted by key
+func (o Owned) Entries() []Entry {
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	entries := make([]Entry, 0, len(keys))
+	for _, k := range keys {
+		kind, name, _ := strings.Cut(k, ".")
+		entries = append(entries, Entry{Kind: Kind(kind), Name: name, Tenant: o[k]})
+	}
+
+	return entries
+}
+
+// Inventory stores the documents owned by the operator in a ConfigMap. Each
+// key of the ConfigMap is <kind>.<name> and its value is the tenant name.
+// The ConfigMap must be read and written without a cache so that a document
+// recorded by one reconciler is immediately seen by the others.
+type Inventory struct {
+	client client.Client
+	key    types.NamespacedName
+	seed   SeedFunc
+
+	mu sync.Mutex
+}
+
+// New returns an inventory stored in the ConfigMap namespace/name
+func New(c client.Client, namespace string, name string, seed SeedFunc) *Inventory {
+	return &Inventory{
+		client: c,
+		key:    types.NamespacedName{Namespace: namespace, Name: name},
+		seed:   seed,
+	}
+}
+
+// Load returns a snapshot of the inventory
+func (i *Inventory) Load(ctx context.Context) (Owned, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	configMap, err := i.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	owned := make(Owned, len(configMap.Data))
+	for k, v := range configMap.Data {
+		owned[k] = v
+	}
+
+	return owned, nil
+}
+
+// Owns returns true when the operator owns the document
+func (i *Inventory) Owns(ctx context.Context, kind Kind, name string) (bool, error) {
+	owned, err := i.Load(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return owned.Has(kind, name), nil
+}
+
+// Record records a document created by the operator for a tenant
+func (i *Inventory) Record(ctx context.Context, kind Kind, name string, tenant string) error {
+	return i.update(ctx, func(data map[string]string) {
+		data[key(kind, name)] = tenant
+	})
+}
+
+// RecordVLAN records a VLAN created by the operator for a tenant
+func (i *Inventory) RecordVLAN(ctx context.Context, vlanID int, tenant string) error {
+	return i.Record(ctx, VLAN, strconv.Itoa(vlanID), tenant)
+}
+
+// Forget removes a document deleted by the operator
+func (i *Inventory) Forget(ctx context.Context, kind Kind, name string) error {
+	return i.update(ctx, func(data map[string]string) {
+		delete(data, key(kind, name))
+	})
+}
+
+// ForgetVLAN removes a VLAN deleted by the operator
+func (i *Inventory) ForgetVLAN(ctx context.Context, vlanID int) error {
+	return i.Forget(ctx, VLAN, strconv.Itoa(vlanID))
+}
+
+// update applies modify to the inventory, retrying on conflicts
+func (i *Inventory) update(ctx context.Context, modify func(data map[string]string)) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := i.get(ctx)
+		if err != nil {
+			return err
+		}
+
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+		modify(configMap.Data)
+
+		return i.client.Update(ctx, configMap)
+	})
+	if err != nil {
+		return fmt.Errorf("cannot update inventory %s: %w", i.key, err)
+	}
+
+	return nil
+}
+
+// get gets the inventory ConfigMap, creating and seeding it when it does not exist
+func (i *Inventory) get(ctx context.Context) (*core.ConfigMap, error) {
+	var configMap core.ConfigMap
+	err := i.client.Get(ctx, i.key, &configMap)
+	if err == nil {
+		return &configMap, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("cannot get inventory %s: %w", i.key, err)
+	}
+
+	configMap = core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: i.key.Namespace,
+			Name:      i.key.Name,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "sshot-net-operator"},
+		},
+		Data: make(map[string]string),
+	}
+
+	if i.seed != nil {
+		entries, err := i.seed(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot seed inventory %s: %w", i.key, err)
+		}
+		for _, entry := range entries {
+			log.Printf("adopting %s %s of tenant %s", entry.Kind, entry.Name, entry.Tenant)
+			configMap.Data[key(entry.Kind, entry.Name)] = entry.Tenant
+		}
+	}
+
+	err = i.client.Create(ctx, &configMap)
+	if apierrors.IsAlreadyExists(err) {
+		err = i.client.Get(ctx, i.key, &configMap)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot create inventory %s: %w", i.key, err)
+	}
+	log.Printf("created inventory %s with %d documents", i.key, len(configMap.Data))
+
+	return &configMap, nil
+}
+
+func key(kind Kind, name string) string {
+	return string(kind) + "." + name
+}
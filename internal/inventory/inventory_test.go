@@ -0,0 +1,161 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newFakeClient(t *testing.T) client.Client {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := core.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	return fake.NewClientBuilder().WithScheme(scheme).Build()
+}
+
+func TestInventory(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+	inv := New(c, "sshot-net-operator", "inventory", nil)
+
+	owned, err := inv.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(owned) != 0 {
+		t.Errorf("expected an empty inventory, got %v", owned)
+	}
+
+	if err := inv.Record(ctx, VNIPartition, "vcluster-blue", "vcluster-blue"); err != nil {
+		t.Fatal(err)
+	}
+	if err := inv.Record(ctx, VNIBlock, "vcluster-blue-block", "vcluster-blue"); err != nil {
+		t.Fatal(err)
+	}
+	if err := inv.RecordVLAN(ctx, 7, "vcluster-blue"); err != nil {
+		t.Fatal(err)
+	}
+
+	owned, err = inv.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !owned.VLAN(7) || owned.VLAN(8) {
+		t.Errorf("expected only VLAN 7 to be owned, got %v", owned)
+	}
+	if tenant, ok := owned.Tenant(VNIBlock, "vcluster-blue-block"); !ok || tenant != "vcluster-blue" {
+		t.Errorf("expected the VNI block to be owned by vcluster-blue, got %q %v", tenant, ok)
+	}
+	if owned.Has(VNIPartition, "vcluster-blue-block") {
+		t.Error("kinds must not share names")
+	}
+
+	expected := []Entry{
+		{Kind: VLAN, Name: "7", Tenant: "vcluster-blue"},
+		{Kind: VNIBlock, Name: "vcluster-blue-block", Tenant: "vcluster-blue"},
+		{Kind: VNIPartition, Name: "vcluster-blue", Tenant: "vcluster-blue"},
+	}
+	if !reflect.DeepEqual(owned.Entries(), expected) {
+		t.Errorf("expected entries %v, got %v", expected, owned.Entries())
+	}
+
+	if err := inv.ForgetVLAN(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	if owns, err := inv.Owns(ctx, VLAN, "7"); err != nil || owns {
+		t.Errorf("expected VLAN 7 to be forgotten, got %v %v", owns, err)
+	}
+
+	// the inventory is stored in the ConfigMap
+	var configMap core.ConfigMap
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "sshot-net-operator", Name: "inventory"}, &configMap); err != nil {
+		t.Fatal(err)
+	}
+	if configMap.Data["vnipartition.vcluster-blue"] != "vcluster-blue" || len(configMap.Data) != 2 {
+		t.Errorf("unexpected inventory ConfigMap %v", configMap.Data)
+	}
+}
+
+func TestInventorySeed(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+
+	var seeded int
+	seed := func(ctx context.Context) ([]Entry, error) {
+		seeded++
+		return []Entry{{Kind: PortPolicy, Name: "vcluster-blue", Tenant: "vcluster-blue"}}, nil
+	}
+
+	inv := New(c, "sshot-net-operator", "inventory", seed)
+	if owns, err := inv.Owns(ctx, PortPolicy, "vcluster-blue"); err != nil || !owns {
+		t.Errorf("expected the seeded port policy to be owned, got %v %v", owns, err)
+	}
+
+	// the seed only runs when the ConfigMap is created
+	if err := inv.Forget(ctx, PortPolicy, "vcluster-blue"); err != nil {
+		t.Fatal(err)
+	}
+	restarted := New(c, "sshot-net-operator", "inventory", seed)
+	if owns, err := restarted.Owns(ctx, PortPolicy, "vcluster-blue"); err != nil || owns {
+		t.Errorf("expected the forgotten port policy not to be owned, got %v %v", owns, err)
+	}
+	if seeded != 1 {
+		t.Errorf("expected the seed to run once, ran %d times", seeded)
+	}
+
+	failing := New(newFakeClient(t), "sshot-net-operator", "inventory", func(ctx context.Context) ([]Entry, error) {
+		return nil, fmt.Errorf("fabric manager is unavailable")
+	})
+	if _, err := failing.Load(ctx); err == nil {
+		t.Error("expected a failed seed to fail the load")
+	}
+}
+
+func TestInventoryConcurrentRecord(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+
+	// two inventories on the same ConfigMap, as after a leader change
+	first := New(c, "sshot-net-operator", "inventory", nil)
+	second := New(c, "sshot-net-operator", "inventory", nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		inv := first
+		if i%2 == 1 {
+			inv = second
+		}
+		wg.Add(1)
+		go func(inv *Inventory, vlanID int) {
+			defer wg.Done()
+			if err := inv.RecordVLAN(ctx, vlanID, "vcluster-blue"); err != nil {
+				t.Error(err)
+			}
+		}(inv, i+1)
+	}
+	wg.Wait()
+
+	owned, err := first.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(owned) != 20 {
+		t.Errorf("expected 20 VLANs to be recorded, got %d", len(owned))
+	}
+}
@@ -0,0 +1,354 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+// Package ipam allocates the VNIs of the tenants that only request a VNI
+// count and the VLAN IDs of the tenants from global pools, and records them in
+// the cluster so that no two tenants are given the same VNIs or VLAN ID
+package ipam
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+)
+
+// SeedFunc returns the VNI ranges of each tenant for a new allocation map. It
+// is called once, when the allocation ConfigMap does not exist yet, to adopt
+// the VNI partitions created before the allocator existed
+type SeedFunc func(ctx context.Context) (map[string][]string, error)
+
+// Allocator stores the VNI ranges of each tenant in a ConfigMap. Each key of
+// the ConfigMap is a tenant name and its value is the comma separated list of
+// the VNI ranges of the tenant, e.g. "1000-1099,2000-2049". Like the
+// inventory, the ConfigMap must be read and written without a cache.
+type Allocator struct {
+	store *store
+	pool  []slingshot.VNIRange
+}
+
+// New returns an allocator handing out VNIs from pool, stored in the
+// ConfigMap namespace/name
+func New(c client.Client, namespace string, name string, pool []slingshot.VNIRange, seed SeedFunc) *Allocator {
+	a := &Allocator{
+		store: &store{client: c, key: types.NamespacedName{Namespace: namespace, Name: name}, kind: "VNI"},
+		pool:  normalize(pool),
+	}
+	a.store.observe = a.observe
+	if seed != nil {
+		a.store.seed = func(ctx context.Context) (map[string]string, error) {
+			return adoptVNIs(ctx, seed)
+		}
+	}
+
+	return a
+}
+
+// ParsePool parses the VNI ranges of a pool or of a tenant
+func ParsePool(pool []string) ([]slingshot.VNIRange, error) {
+	ranges := make([]slingshot.VNIRange, 0, len(pool))
+	for _, vniRange := range pool {
+		r, err := slingshot.ParseVNIRange(vniRange)
+		if err != nil {
+			return nil, fmt.Errorf("VNI range %q is invalid: %w", vniRange, err)
+		}
+		ranges = append(ranges, r)
+	}
+
+	return ranges, nil
+}
+
+// Allocate returns the VNI ranges of a tenant requesting count VNIs. A tenant
+// keeps the VNIs it was already given: when the count grows, VNIs are added
+// from the pool, preferring a single free range large enough over fragments,
+// and when it shrinks, the highest VNIs are released
+func (a *Allocator) Allocate(ctx context.Context, tenant string, count int) ([]string, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf("VNI count must be positive: %d", count)
+	}
+
+	var allocated []slingshot.VNIRange
+	err := a.update(ctx, func(allocations map[string][]slingshot.VNIRange) error {
+		current := allocations[tenant]
+		size := total(current)
+
+		switch {
+		case size > count:
+			allocated = trim(current, count)
+		case size < count:
+			free := subtract(a.pool, allocations)
+			added, err := take(free, count-size)
+			if err != nil {
+				return err
+			}
+			allocated = normalize(append(append([]slingshot.VNIRange{}, current...), added...))
+		default:
+			allocated = current
+		}
+
+		allocations[tenant] = allocated
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return format(allocated), nil
+}
+
+// Reserve records the VNI ranges requested by a tenant. The ranges do not
+// have to be part of the pool, but must not overlap the VNIs of another tenant
+func (a *Allocator) Reserve(ctx context.Context, tenant string, vniRanges []string) error {
+	ranges, err := ParsePool(vniRanges)
+	if err != nil {
+		return err
+	}
+
+	return a.update(ctx, func(allocations map[string][]slingshot.VNIRange) error {
+		others := make([]string, 0, len(allocations))
+		for other := range allocations {
+			if other != tenant {
+				others = append(others, other)
+			}
+		}
+		sort.Strings(others)
+
+		for _, other := range others {
+			for _, r := range ranges {
+				for _, o := range allocations[other] {
+					if r.Overlaps(o) {
+						return fmt.Errorf("VNI range %s of tenant %s overlaps with VNI range %s of tenant %s", r, tenant, o, other)
+					}
+				}
+			}
+		}
+
+		allocations[tenant] = normalize(ranges)
+		return nil
+	})
+}
+
+// Release releases the VNIs of a tenant, even when they cannot be decoded
+func (a *Allocator) Release(ctx context.Context, tenant string) error {
+	return a.store.update(ctx, func(data map[string]string) error {
+		if value, ok := data[tenant]; ok {
+			log.Printf("releasing VNIs %s of tenant %s", value, tenant)
+			delete(data, tenant)
+		}
+		return nil
+	})
+}
+
+// Allocations returns the VNI ranges of every tenant
+func (a *Allocator) Allocations(ctx context.Context) (map[string][]string, error) {
+	data, err := a.store.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	allocations := make(map[string][]string, len(data))
+	for tenant, ranges := range decode(data) {
+		allocations[tenant] = format(ranges)
+	}
+
+	return allocations, nil
+}
+
+// Observe reads the allocations to update the metrics of the pool
+func (a *Allocator) Observe(ctx context.Context) error {
+	_, err := a.store.load(ctx)
+	return err
+}
+
+// observe records how many VNIs of the pool are allocated. The VNI ranges
+// requested outside of the pool are not counted
+func (a *Allocator) observe(data map[string]string) {
+	size := total(a.pool)
+	setPoolMetrics(poolVNI, size, size-total(subtract(a.pool, decode(data))))
+}
+
+// update applies modify to the allocations
+func (a *Allocator) update(ctx context.Context, modify func(allocations map[string][]slingshot.VNIRange) error) error {
+	return a.store.update(ctx, func(data map[string]string) error {
+		allocations := decode(data)
+		decoded := make(map[string]bool, len(allocations))
+		for tenant := range allocations {
+			decoded[tenant] = true
+		}
+		err := modify(allocations)
+		if err != nil {
+			return err
+		}
+
+		//the VNIs that cannot be decoded are kept as they are
+		for tenant := range decoded {
+			if _, ok := allocations[tenant]; !ok {
+				delete(data, tenant)
+			}
+		}
+		for tenant, value := range encode(allocations) {
+			data[tenant] = value
+		}
+		return nil
+	})
+}
+
+// adoptVNIs returns the data of a new allocation ConfigMap from the VNI
+// ranges returned by seed, ignoring the ranges that cannot be parsed
+func adoptVNIs(ctx context.Context, seed SeedFunc) (map[string]string, error) {
+	seeded, err := seed(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]string, len(seeded))
+	for tenant, vniRanges := range seeded {
+		ranges, err := ParsePool(vniRanges)
+		if err != nil {
+			log.Printf("cannot adopt VNIs of tenant %s: %+v", tenant, err)
+			continue
+		}
+		log.Printf("adopting VNIs %s of tenant %s", strings.Join(vniRanges, ","), tenant)
+		data[tenant] = strings.Join(format(normalize(ranges)), ",")
+	}
+
+	return data, nil
+}
+
+func decode(data map[string]string) map[string][]slingshot.VNIRange {
+	allocations := make(map[string][]slingshot.VNIRange, len(data))
+	for tenant, value := range data {
+		ranges, err := ParsePool(strings.Split(value, ","))
+		if err != nil {
+			log.Printf("ignoring VNIs %q of tenant %s, keeping them as they are: %+v", value, tenant, err)
+			continue
+		}
+		allocations[tenant] = ranges
+	}
+
+	return allocations
+}
+
+func encode(allocations map[string][]slingshot.VNIRange) map[string]string {
+	data := make(map[string]string, len(allocations))
+	for tenant, ranges := range allocations {
+		data[tenant] = strings.Join(format(ranges), ",")
+	}
+
+	return data
+}
+
+func format(ranges []slingshot.VNIRange) []string {
+	formatted := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		formatted = append(formatted, r.String())
+	}
+
+	return formatted
+}
+
+func total(ranges []slingshot.VNIRange) int {
+	size := 0
+	for _, r := range ranges {
+		size += r.Size()
+	}
+
+	return size
+}
+
+// normalize sorts the ranges and merges the ranges that overlap or are adjacent
+func normalize(ranges []slingshot.VNIRange) []slingshot.VNIRange {
+	sorted := append([]slingshot.VNIRange{}, ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var merged []slingshot.VNIRange
+	for _, r := range sorted {
+		if n := len(merged); n > 0 && r.Start <= merged[n-1].End+1 {
+			if r.End > merged[n-1].End {
+				merged[n-1].End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+
+	return merged
+}
+
+// subtract returns the ranges of the pool that are not allocated to any tenant
+func subtract(pool []slingshot.VNIRange, allocations map[string][]slingshot.VNIRange) []slingshot.VNIRange {
+	var used []slingshot.VNIRange
+	for _, ranges := range allocations {
+		used = append(used, ranges...)
+	}
+	used = normalize(used)
+
+	var free []slingshot.VNIRange
+	for _, r := range pool {
+		start := r.Start
+		for _, u := range used {
+			if u.End < start || u.Start > r.End {
+				continue
+			}
+			if u.Start > start {
+				free = append(free, slingshot.VNIRange{Start: start, End: u.Start - 1})
+			}
+			start = u.End + 1
+		}
+		if start <= r.End {
+			free = append(free, slingshot.VNIRange{Start: start, End: r.End})
+		}
+	}
+
+	return free
+}
+
+// take takes count VNIs from the free ranges: the lowest free range large
+// enough when there is one, otherwise the lowest fragments
+func take(free []slingshot.VNIRange, count int) ([]slingshot.VNIRange, error) {
+	for _, r := range free {
+		if r.Size() >= count {
+			return []slingshot.VNIRange{{Start: r.Start, End: r.Start + count - 1}}, nil
+		}
+	}
+
+	if total(free) < count {
+		return nil, fmt.Errorf("VNI pool exhausted: %d VNIs requested, %d free", count, total(free))
+	}
+
+	var taken []slingshot.VNIRange
+	for _, r := range free {
+		if count <= r.Size() {
+			taken = append(taken, slingshot.VNIRange{Start: r.Start, End: r.Start + count - 1})
+			break
+		}
+		taken = append(taken, r)
+		count -= r.Size()
+	}
+
+	return taken, nil
+}
+
+// trim keeps the lowest count VNIs of the ranges
+func trim(ranges []slingshot.VNIRange, count int) []slingshot.VNIRange {
+	var trimmed []slingshot.VNIRange
+	for _, r := range ranges {
+		if count == 0 {
+			break
+		}
+		if r.Size() > count {
+			r.End = r.Start + count - 1
+		}
+		trimmed = append(trimmed, r)
+		count -= r.Size()
+	}
+
+	return trimmed
+}
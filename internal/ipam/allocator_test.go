@@ -0,0 +1,192 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package ipam
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newFakeClient(t *testing.T) client.Client {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := core.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	return fake.NewClientBuilder().WithScheme(scheme).Build()
+}
+
+func newAllocator(t *testing.T, c client.Client, pool ...string) *Allocator {
+	t.Helper()
+
+	ranges, err := ParsePool(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New(c, "sshot-net-operator", "vnis", ranges, nil)
+}
+
+func TestAllocate(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+	vnis := newAllocator(t, c, "100-199", "300-349")
+
+	allocate := func(tenant string, count int, expected ...string) {
+		t.Helper()
+		ranges, err := vnis.Allocate(ctx, tenant, count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ranges, expected) {
+			t.Errorf("expected %s to be allocated %v, got %v", tenant, expected, ranges)
+		}
+	}
+
+	allocate("vcluster-blue", 60, "100-159")
+	allocate("vcluster-blue", 60, "100-159")
+	// a contiguous range is preferred to fragments
+	allocate("vcluster-red", 45, "300-344")
+	// the pool is fragmented
+	allocate("vcluster-green", 45, "160-199", "345-349")
+
+	if _, err := vnis.Allocate(ctx, "vcluster-white", 1); err == nil || !strings.Contains(err.Error(), "VNI pool exhausted") {
+		t.Errorf("expected the pool to be exhausted, got %v", err)
+	}
+
+	// a tenant keeps its VNIs when its count changes
+	allocate("vcluster-blue", 10, "100-109")
+	allocate("vcluster-blue", 20, "100-119")
+
+	if err := vnis.Release(ctx, "vcluster-red"); err != nil {
+		t.Fatal(err)
+	}
+	allocate("vcluster-white", 50, "120-159", "300-309")
+
+	// the allocations are persisted
+	allocations, err := newAllocator(t, c, "100-199", "300-349").Allocations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		"vcluster-blue":  {"100-119"},
+		"vcluster-green": {"160-199", "345-349"},
+		"vcluster-white": {"120-159", "300-309"},
+	}
+	if !reflect.DeepEqual(allocations, expected) {
+		t.Errorf("expected allocations %v, got %v", expected, allocations)
+	}
+
+	var configMap core.ConfigMap
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "sshot-net-operator", Name: "vnis"}, &configMap); err != nil {
+		t.Fatal(err)
+	}
+	if configMap.Data["vcluster-green"] != "160-199,345-349" {
+		t.Errorf("unexpected ConfigMap data %v", configMap.Data)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	ctx := context.Background()
+	vnis := newAllocator(t, newFakeClient(t), "100-199")
+
+	if _, err := vnis.Allocate(ctx, "vcluster-blue", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	// requested ranges may be outside of the pool
+	if err := vnis.Reserve(ctx, "vcluster-red", []string{"1000-1999", "110-119"}); err != nil {
+		t.Fatal(err)
+	}
+	err := vnis.Reserve(ctx, "vcluster-green", []string{"105-120"})
+	if err == nil || !strings.Contains(err.Error(), "overlaps with VNI range 100-109 of tenant vcluster-blue") {
+		t.Errorf("expected the overlap to be rejected, got %v", err)
+	}
+
+	// reserved VNIs are not allocated
+	ranges, err := vnis.Allocate(ctx, "vcluster-green", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ranges, []string{"120-129"}) {
+		t.Errorf("expected the VNIs after the reserved range, got %v", ranges)
+	}
+}
+
+func TestUndecodedAllocations(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+	vnis := newAllocator(t, c, "100-199")
+
+	if _, err := vnis.Allocate(ctx, "vcluster-blue", 10); err != nil {
+		t.Fatal(err)
+	}
+	key := types.NamespacedName{Namespace: "sshot-net-operator", Name: "vnis"}
+	var configMap core.ConfigMap
+	if err := c.Get(ctx, key, &configMap); err != nil {
+		t.Fatal(err)
+	}
+	configMap.Data["vcluster-red"] = "2000-1000"
+	if err := c.Update(ctx, &configMap); err != nil {
+		t.Fatal(err)
+	}
+
+	// VNIs that cannot be decoded are kept when the other allocations change
+	if _, err := vnis.Allocate(ctx, "vcluster-green", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := vnis.Release(ctx, "vcluster-blue"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, key, &configMap); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"vcluster-green": "110-119", "vcluster-red": "2000-1000"}
+	if !reflect.DeepEqual(configMap.Data, expected) {
+		t.Errorf("expected allocations %v, got %v", expected, configMap.Data)
+	}
+
+	// VNIs that cannot be decoded are still released
+	if err := vnis.Release(ctx, "vcluster-red"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, key, &configMap); err != nil {
+		t.Fatal(err)
+	}
+	expected = map[string]string{"vcluster-green": "110-119"}
+	if !reflect.DeepEqual(configMap.Data, expected) {
+		t.Errorf("expected allocations %v, got %v", expected, configMap.Data)
+	}
+}
+
+func TestAllocatorSeed(t *testing.T) {
+	ctx := context.Background()
+	ranges, err := ParsePool([]string{"100-199"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vnis := New(newFakeClient(t), "sshot-net-operator", "vnis", ranges, func(ctx context.Context) (map[string][]string, error) {
+		return map[string][]string{"vcluster-blue": {"100-149"}, "vcluster-red": {"bad"}}, nil
+	})
+
+	allocated, err := vnis.Allocate(ctx, "vcluster-red", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(allocated, []string{"150-159"}) {
+		t.Errorf("expected the VNIs of the adopted partition to be skipped, got %v", allocated)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package ipam
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// metricsNamespace prefixes the names of the metrics of the operator
+	metricsNamespace = "sshot_net_operator"
+
+	// poolVNI is the pool label of the VNI pool
+	poolVNI = "vni"
+
+	// poolVLAN is the pool label of the VLAN ID pool
+	poolVLAN = "vlan"
+)
+
+var (
+	poolSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "pool_size",
+		Help:      "Number of VNIs or VLAN IDs in the pool.",
+	}, []string{"pool"})
+
+	poolAllocated = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "pool_allocated",
+		Help:      "Number of VNIs or VLAN IDs of the pool given to tenants.",
+	}, []string{"pool"})
+
+	poolUtilization = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "pool_utilization",
+		Help:      "Ratio of the VNIs or VLAN IDs of the pool given to tenants.",
+	}, []string{"pool"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(poolSize, poolAllocated, poolUtilization)
+}
+
+// setPoolMetrics records the size of a pool and how much of it is allocated
+func setPoolMetrics(pool string, size int, allocated int) {
+	poolSize.WithLabelValues(pool).Set(float64(size))
+	poolAllocated.WithLabelValues(pool).Set(float64(allocated))
+
+	utilization := 0.0
+	if size > 0 {
+		utilization = float64(allocated) / float64(size)
+	}
+	poolUtilization.WithLabelValues(pool).Set(utilization)
+}
@@ -0,0 +1,62 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package ipam
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPoolMetrics(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+	vnis := newAllocator(t, c, "100-199", "300-349")
+	vlans := NewVLANAllocator(c, "sshot-net-operator", "vlans", []int{2, 3, 4, 5}, nil)
+
+	_, err := vnis.Allocate(ctx, "vcluster-blue", 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// VNIs requested outside of the pool are not counted
+	err = vnis.Reserve(ctx, "vcluster-red", []string{"190-209"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = vlans.Allocate(ctx, "vcluster-blue", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = vlans.Reserve(ctx, "vcluster-red", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := func(pool string, size float64, allocated float64, utilization float64) {
+		t.Helper()
+		if value := testutil.ToFloat64(poolSize.WithLabelValues(pool)); value != size {
+			t.Errorf("expected %s pool size %v, got %v", pool, size, value)
+		}
+		if value := testutil.ToFloat64(poolAllocated.WithLabelValues(pool)); value != allocated {
+			t.Errorf("expected %v allocated in %s pool, got %v", allocated, pool, value)
+		}
+		if value := testutil.ToFloat64(poolUtilization.WithLabelValues(pool)); value != utilization {
+			t.Errorf("expected %s pool utilization %v, got %v", pool, utilization, value)
+		}
+	}
+	expect(poolVNI, 150, 40, 40.0/150)
+	expect(poolVLAN, 4, 1, 0.25)
+
+	err = vnis.Release(ctx, "vcluster-blue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = vnis.Observe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(poolVNI, 150, 10, 10.0/150)
+}
@@ -0,0 +1,127 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package ipam
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// store keeps the allocations of each tenant in the data of a ConfigMap. An
+// update conflicts when another replica changed the ConfigMap since it was
+// read, so two tenants are never given the same resource
+type store struct {
+	client client.Client
+	key    types.NamespacedName
+	kind   string
+
+	// seed returns the data of a new ConfigMap
+	seed func(ctx context.Context) (map[string]string, error)
+
+	// observe is called with the data of the ConfigMap after it is read or written
+	observe func(data map[string]string)
+
+	mu sync.Mutex
+}
+
+// load returns the data of the ConfigMap
+func (s *store) load(ctx context.Context) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	configMap, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.notify(configMap.Data)
+
+	return configMap.Data, nil
+}
+
+// update applies modify to the data of the ConfigMap, retrying on conflicts
+func (s *store) update(ctx context.Context, modify func(data map[string]string) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var updated map[string]string
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := s.get(ctx)
+		if err != nil {
+			return err
+		}
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+
+		err = modify(configMap.Data)
+		if err != nil {
+			return err
+		}
+
+		updated = configMap.Data
+		return s.client.Update(ctx, configMap)
+	})
+	if err != nil {
+		return fmt.Errorf("cannot update %s allocations %s: %w", s.kind, s.key, err)
+	}
+	s.notify(updated)
+
+	return nil
+}
+
+// notify calls observe with the data of the ConfigMap
+func (s *store) notify(data map[string]string) {
+	if s.observe != nil {
+		s.observe(data)
+	}
+}
+
+// get gets the ConfigMap, creating and seeding it when it does not exist
+func (s *store) get(ctx context.Context) (*core.ConfigMap, error) {
+	var configMap core.ConfigMap
+	err := s.client.Get(ctx, s.key, &configMap)
+	if err == nil {
+		return &configMap, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("cannot get %s allocations %s: %w", s.kind, s.key, err)
+	}
+
+	configMap = core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: s.key.Namespace,
+			Name:      s.key.Name,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "sshot-net-operator"},
+		},
+		Data: make(map[string]string),
+	}
+
+	if s.seed != nil {
+		configMap.Data, err = s.seed(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot seed %s allocations %s: %w", s.kind, s.key, err)
+		}
+	}
+
+	err = s.client.Create(ctx, &configMap)
+	if apierrors.IsAlreadyExists(err) {
+		err = s.client.Get(ctx, s.key, &configMap)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot create %s allocations %s: %w", s.kind, s.key, err)
+	}
+	log.Printf("created %s allocations %s with %d tenants", s.kind, s.key, len(configMap.Data))
+
+	return &configMap, nil
+}
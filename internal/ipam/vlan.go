@@ -0,0 +1,254 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package ipam
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	slingshot "github.hpe.com/hpe/sshot-net-operator/api/slingshot/v1alpha1"
+)
+
+const (
+	// MinVLANID is the lowest VLAN ID that can be allocated
+	MinVLANID = 1
+
+	// MaxVLANID is the highest VLAN ID that can be allocated
+	MaxVLANID = slingshot.MaxVLANID
+)
+
+// VLANSeedFunc returns the VLAN ID of each tenant for a new allocation map.
+// It is called once, when the allocation ConfigMap does not exist yet, to
+// adopt the VLANs created before the allocator existed
+type VLANSeedFunc func(ctx context.Context) (map[string]int, error)
+
+// VLANAllocator stores the VLAN ID of each tenant in a ConfigMap. Each key of
+// the ConfigMap is a tenant name and its value is the VLAN ID of the tenant.
+// Like the inventory, the ConfigMap must be read and written without a cache.
+type VLANAllocator struct {
+	store *store
+	pool  []int
+}
+
+// NewVLANAllocator returns an allocator handing out the VLAN IDs of pool,
+// stored in the ConfigMap namespace/name
+func NewVLANAllocator(c client.Client, namespace string, name string, pool []int, seed VLANSeedFunc) *VLANAllocator {
+	a := &VLANAllocator{
+		store: &store{client: c, key: types.NamespacedName{Namespace: namespace, Name: name}, kind: "VLAN"},
+		pool:  pool,
+	}
+	a.store.observe = a.observe
+	if seed != nil {
+		a.store.seed = func(ctx context.Context) (map[string]string, error) {
+			return adoptVLANs(ctx, seed)
+		}
+	}
+
+	return a
+}
+
+// ParseVLANIDs parses a list of VLAN IDs and VLAN ID ranges, e.g.
+// "1-4094" or "100", and returns the sorted VLAN IDs without duplicates
+func ParseVLANIDs(vlanRanges []string) ([]int, error) {
+	ids := make(map[int]bool)
+	for _, vlanRange := range vlanRanges {
+		r, err := slingshot.ParseVNIRange(vlanRange)
+		if err != nil {
+			return nil, fmt.Errorf("VLAN range %q is invalid: %w", vlanRange, err)
+		}
+		if r.Start < MinVLANID || r.End > MaxVLANID {
+			return nil, fmt.Errorf("VLAN range %q is invalid: VLAN IDs must be between %d and %d", vlanRange, MinVLANID, MaxVLANID)
+		}
+		for id := r.Start; id <= r.End; id++ {
+			ids[id] = true
+		}
+	}
+
+	sorted := make([]int, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Ints(sorted)
+
+	return sorted, nil
+}
+
+// VLANPool returns the VLAN IDs of the pool ranges that are not excluded
+func VLANPool(poolRanges []string, excludedRanges []string) ([]int, error) {
+	pool, err := ParseVLANIDs(poolRanges)
+	if err != nil {
+		return nil, err
+	}
+	excluded, err := ParseVLANIDs(excludedRanges)
+	if err != nil {
+		return nil, err
+	}
+
+	isExcluded := make(map[int]bool, len(excluded))
+	for _, id := range excluded {
+		isExcluded[id] = true
+	}
+
+	ids := make([]int, 0, len(pool))
+	for _, id := range pool {
+		if !isExcluded[id] {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("VLAN pool is empty")
+	}
+
+	return ids, nil
+}
+
+// Allocate returns the VLAN ID of a tenant. A tenant keeps the VLAN ID it was
+// already given unless that ID is in inUse; otherwise the lowest free VLAN ID
+// of the pool that is not in inUse is reserved for it. inUse are the VLAN IDs
+// that exist in Fabric Manager for other tenants or outside of the operator
+func (a *VLANAllocator) Allocate(ctx context.Context, tenant string, inUse []int) (int, error) {
+	used := make(map[int]bool, len(inUse))
+	for _, id := range inUse {
+		used[id] = true
+	}
+
+	var vlanID int
+	err := a.store.update(ctx, func(data map[string]string) error {
+		allocations := decodeVLANs(data)
+		if id, ok := allocations[tenant]; ok && !used[id] {
+			vlanID = id
+			return nil
+		}
+
+		for other, id := range allocations {
+			if other != tenant {
+				used[id] = true
+			}
+		}
+		for _, id := range a.pool {
+			if !used[id] {
+				vlanID = id
+				data[tenant] = strconv.Itoa(id)
+				return nil
+			}
+		}
+
+		return fmt.Errorf("VLAN pool exhausted: %d VLAN IDs in use", len(used))
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return vlanID, nil
+}
+
+// Reserve records the VLAN ID requested by a tenant. The ID does not have to
+// be part of the pool, but must not be the VLAN ID of another tenant
+func (a *VLANAllocator) Reserve(ctx context.Context, tenant string, id int) error {
+	if id < MinVLANID || id > MaxVLANID {
+		return fmt.Errorf("VLAN ID %d is invalid: VLAN IDs must be between %d and %d", id, MinVLANID, MaxVLANID)
+	}
+
+	return a.store.update(ctx, func(data map[string]string) error {
+		allocations := decodeVLANs(data)
+		others := make([]string, 0, len(allocations))
+		for other := range allocations {
+			if other != tenant {
+				others = append(others, other)
+			}
+		}
+		sort.Strings(others)
+
+		for _, other := range others {
+			if allocations[other] == id {
+				return fmt.Errorf("VLAN ID %d of tenant %s is already the VLAN ID of tenant %s", id, tenant, other)
+			}
+		}
+
+		data[tenant] = strconv.Itoa(id)
+		return nil
+	})
+}
+
+// Release releases the VLAN ID of a tenant
+func (a *VLANAllocator) Release(ctx context.Context, tenant string) error {
+	return a.store.update(ctx, func(data map[string]string) error {
+		if id, ok := data[tenant]; ok {
+			log.Printf("releasing VLAN %s of tenant %s", id, tenant)
+			delete(data, tenant)
+		}
+		return nil
+	})
+}
+
+// Allocations returns the VLAN ID of every tenant
+func (a *VLANAllocator) Allocations(ctx context.Context) (map[string]int, error) {
+	data, err := a.store.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeVLANs(data), nil
+}
+
+// Observe reads the allocations to update the metrics of the pool
+func (a *VLANAllocator) Observe(ctx context.Context) error {
+	_, err := a.store.load(ctx)
+	return err
+}
+
+// observe records how many VLAN IDs of the pool are allocated. The VLAN IDs
+// requested outside of the pool are not counted
+func (a *VLANAllocator) observe(data map[string]string) {
+	allocated := make(map[int]bool)
+	for _, id := range decodeVLANs(data) {
+		allocated[id] = true
+	}
+
+	count := 0
+	for _, id := range a.pool {
+		if allocated[id] {
+			count++
+		}
+	}
+	setPoolMetrics(poolVLAN, len(a.pool), count)
+}
+
+// adoptVLANs returns the data of a new allocation ConfigMap from the VLAN IDs
+// returned by seed
+func adoptVLANs(ctx context.Context, seed VLANSeedFunc) (map[string]string, error) {
+	seeded, err := seed(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]string, len(seeded))
+	for tenant, id := range seeded {
+		log.Printf("adopting VLAN %d of tenant %s", id, tenant)
+		data[tenant] = strconv.Itoa(id)
+	}
+
+	return data, nil
+}
+
+func decodeVLANs(data map[string]string) map[string]int {
+	allocations := make(map[string]int, len(data))
+	for tenant, value := range data {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			log.Printf("ignoring VLAN %q of tenant %s: %+v", value, tenant, err)
+			continue
+		}
+		allocations[tenant] = id
+	}
+
+	return allocations
+}
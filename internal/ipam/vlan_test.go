@@ -0,0 +1,105 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package ipam
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVLANPool(t *testing.T) {
+	pool, err := VLANPool([]string{"1-10", "5-12"}, []string{"1", "3-4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pool, []int{2, 5, 6, 7, 8, 9, 10, 11, 12}) {
+		t.Errorf("unexpected VLAN pool %v", pool)
+	}
+
+	for _, ranges := range [][]string{{"0-10"}, {"4000-4095"}, {"x"}} {
+		if _, err := ParseVLANIDs(ranges); err == nil {
+			t.Errorf("expected %q to be rejected", ranges)
+		}
+	}
+	if _, err := VLANPool([]string{"1-2"}, []string{"1-2"}); err == nil {
+		t.Error("expected an empty pool to be rejected")
+	}
+}
+
+func TestAllocateVLAN(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+	vlans := NewVLANAllocator(c, "sshot-net-operator", "vlans", []int{2, 3, 4, 5}, func(ctx context.Context) (map[string]int, error) {
+		return map[string]int{"vcluster-blue": 3}, nil
+	})
+
+	allocate := func(tenant string, inUse []int, expected int) {
+		t.Helper()
+		id, err := vlans.Allocate(ctx, tenant, inUse)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != expected {
+			t.Errorf("expected %s to be allocated VLAN %d, got %d", tenant, expected, id)
+		}
+	}
+
+	// the adopted VLAN is kept, and IDs used outside of the operator are skipped
+	allocate("vcluster-blue", nil, 3)
+	allocate("vcluster-red", []int{2}, 4)
+	allocate("vcluster-red", []int{2}, 4)
+	allocate("vcluster-green", nil, 2)
+
+	// a VLAN ID taken in Fabric Manager is allocated again
+	allocate("vcluster-green", []int{2}, 5)
+	if _, err := vlans.Allocate(ctx, "vcluster-white", []int{2}); err == nil || !strings.Contains(err.Error(), "VLAN pool exhausted") {
+		t.Errorf("expected the pool to be exhausted, got %v", err)
+	}
+
+	// a released VLAN ID is reused
+	if err := vlans.Release(ctx, "vcluster-red"); err != nil {
+		t.Fatal(err)
+	}
+	allocate("vcluster-white", []int{2}, 4)
+
+	allocations, err := NewVLANAllocator(c, "sshot-net-operator", "vlans", nil, nil).Allocations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]int{"vcluster-blue": 3, "vcluster-green": 5, "vcluster-white": 4}
+	if !reflect.DeepEqual(allocations, expected) {
+		t.Errorf("expected allocations %v, got %v", expected, allocations)
+	}
+}
+
+func TestReserveVLAN(t *testing.T) {
+	ctx := context.Background()
+	vlans := NewVLANAllocator(newFakeClient(t), "sshot-net-operator", "vlans", []int{2, 3}, nil)
+
+	// a reserved VLAN ID may be outside of the pool and is not allocated again
+	if err := vlans.Reserve(ctx, "vcluster-blue", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := vlans.Reserve(ctx, "vcluster-red", 100); err != nil {
+		t.Fatal(err)
+	}
+	id, err := vlans.Allocate(ctx, "vcluster-green", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 3 {
+		t.Errorf("expected VLAN 3 to be allocated, got %d", id)
+	}
+
+	err = vlans.Reserve(ctx, "vcluster-white", 100)
+	if err == nil || !strings.Contains(err.Error(), "already the VLAN ID of tenant vcluster-red") {
+		t.Errorf("expected the VLAN ID to conflict, got %v", err)
+	}
+	if err := vlans.Reserve(ctx, "vcluster-white", 4095); err == nil {
+		t.Error("expected VLAN ID 4095 to be rejected")
+	}
+}
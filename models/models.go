@@ -5,44 +5,6 @@
 // Package models provides types definition
 package models
 
-import (
-	"time"
-)
-
-const (
-	//BaseURL is the base URL for Fabric Manager
-	BaseURL = "https://api-gw-service-nmn.local/apis/fabric-manager"
-
-	//WaitTime is the wait time in between the requests
-	WaitTime = 100 * time.Millisecond
-
-	//CAPublicKey is the path to the CA certificate
-	CAPublicKey = "/var/run/configmap/ca-public-key.pem"
-
-	OperatorConstFabric   = "/fabric"
-	OperatorConstSwitches = "/switches/"
-	OperatorConstPorts    = "/ports/"
-
-	ReconciliationTime = 60 * time.Second
-)
-
-var (
-	//AccessToken is the access token for Fabric Manager
-	AccessToken string
-
-	//NamespaceForClientData is the namespace for CSM client data
-	NamespaceForClientData string
-
-	//SecretForClientData is the secret for CSM client data
-	SecretForClientData string
-
-	//ClientID is the client ID for CSM client data
-	ClientID string
-
-	//SkipTLSVerify is the flag to skip TLS verification
-	SkipTLSVerify string
-)
-
 // VNIRequestData defines the Payload for VNI configuration
 type VNIRequestData struct {
 	PartitionName string   `json:"partitionName,omitempty"`
@@ -179,10 +141,12 @@ type EdgePortsInfo struct {
 
 // VLANPortPolicyRequest defines the VLAN port policy
 type VLANPortPolicyRequest struct {
-	AllowedVlans      []string `json:"allowedVlans,omitempty"`
-	NativeVlanID      string   `json:"nativeVlanId,omitempty"`
-	IsUntaggedAllowed bool     `json:"isUntaggedAllowed,omitempty"`
-	DocumentSelfLink  string   `json:"documentSelfLink,omitempty"`
+	AutoRetry         *AutoRetry      `json:"autoRetry,omitempty"`
+	HeadShellReset    *HeadShellReset `json:"headShellReset,omitempty"`
+	AllowedVlans      []string        `json:"allowedVlans,omitempty"`
+	NativeVlanID      string          `json:"nativeVlanId,omitempty"`
+	IsUntaggedAllowed bool            `json:"isUntaggedAllowed,omitempty"`
+	DocumentSelfLink  string          `json:"documentSelfLink,omitempty"`
 }
 
 // VLANPortPolicyResponse defines the response for VLAN port policy
@@ -251,9 +215,24 @@ type VLANResponse struct {
 	DocumentOwner                string `json:"documentOwner"`
 }
 
+// VLANPatchRequest defines the payload for a VLAN PATCH request
+type VLANPatchRequest struct {
+	Status string `json:"status"`
+}
+
+// VLANPortPolicyPatchRequest defines the payload for a VLAN port policy PATCH request
+type VLANPortPolicyPatchRequest struct {
+	AutoRetry         *AutoRetry      `json:"autoRetry,omitempty"`
+	HeadShellReset    *HeadShellReset `json:"headShellReset,omitempty"`
+	AllowedVlans      []string        `json:"allowedVlans"`
+	NativeVlanID      string          `json:"nativeVlanId"`
+	IsUntaggedAllowed bool            `json:"isUntaggedAllowed"`
+}
+
 // PortPATCHRequest defines the payload for PATCH request
 type PortPATCHRequest struct {
 	PortPolicyLinks []string `json:"portPolicyLinks"`
+	DocumentVersion int      `json:"documentVersion,omitempty"`
 }
 
 // PortPoliciesResponse defines the response for port policies
@@ -0,0 +1,156 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+// Package token provides the access token for the CSM API gateway
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+	"github.hpe.com/hpe/sshot-net-operator/models"
+)
+
+const (
+	//DefaultRefreshBefore is how long before expiry the token is refreshed
+	DefaultRefreshBefore = 30 * time.Second
+
+	//DefaultLifetime is the lifetime of a token returned without expires_in
+	DefaultLifetime = 5 * time.Minute
+
+	//clientSecretKey is the key of the client secret in the client secret
+	clientSecretKey = "client-secret"
+
+	//endpointKey is the key of the token endpoint in the client secret
+	endpointKey = "endpoint"
+)
+
+// Provider caches the access token for the CSM API gateway and is safe for
+// concurrent use. The token is requested from the endpoint stored in the
+// client secret and is requested again when it is about to expire, when it is
+// invalidated after a 401 or when the client secret changes.
+type Provider struct {
+	reader     client.Reader
+	httpClient *httpclient.Client
+	clientID   string
+	secret     types.NamespacedName
+
+	// RefreshBefore is how long before expiry the token is refreshed
+	RefreshBefore time.Duration
+
+	mu            sync.Mutex
+	accessToken   string
+	refreshAt     time.Time
+	secretVersion string
+	now           func() time.Time
+}
+
+// NewProvider returns a provider that reads the client secret through reader
+// and sends the token requests through httpClient
+func NewProvider(reader client.Reader, httpClient *httpclient.Client, clientID string, secret types.NamespacedName) *Provider {
+	return &Provider{
+		reader:        reader,
+		httpClient:    httpClient,
+		clientID:      clientID,
+		secret:        secret,
+		RefreshBefore: DefaultRefreshBefore,
+		now:           time.Now,
+	}
+}
+
+// Token returns the cached access token, requesting a new one when needed
+func (p *Provider) Token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var clientSecret core.Secret
+	err := p.reader.Get(ctx, client.ObjectKey(p.secret), &clientSecret)
+	if err != nil {
+		return "", fmt.Errorf("cannot get client secret %s: %w", p.secret, err)
+	}
+
+	if clientSecret.ResourceVersion != p.secretVersion && p.accessToken != "" {
+		log.Printf("client secret %s changed, refreshing access token", p.secret)
+		p.accessToken = ""
+	}
+
+	if p.accessToken != "" && p.now().Before(p.refreshAt) {
+		return p.accessToken, nil
+	}
+
+	tokenResponse, err := p.requestToken(ctx, &clientSecret)
+	if err != nil {
+		return "", err
+	}
+
+	p.accessToken = tokenResponse.AccessToken
+	p.refreshAt = p.now().Add(p.refreshAfter(tokenResponse.ExpiresIn))
+	p.secretVersion = clientSecret.ResourceVersion
+
+	return p.accessToken, nil
+}
+
+// refreshAfter returns how long a token that expires in expiresIn seconds is
+// used. A token without a lifetime is used as if it expired after
+// DefaultLifetime, and a token expiring within RefreshBefore is refreshed
+// halfway through its lifetime, so that it is not requested on every call
+func (p *Provider) refreshAfter(expiresIn int) time.Duration {
+	lifetime := time.Duration(expiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = DefaultLifetime
+	}
+	if lifetime <= p.RefreshBefore {
+		return lifetime / 2
+	}
+
+	return lifetime - p.RefreshBefore
+}
+
+// Invalidate drops the cached access token so the next call to Token requests a new one
+func (p *Provider) Invalidate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.accessToken = ""
+}
+
+// requestToken requests an access token from the endpoint in the client secret
+func (p *Provider) requestToken(ctx context.Context, clientSecret *core.Secret) (models.TokenResponse, error) {
+	var result models.TokenResponse
+
+	//create form data
+	data := make(map[string]string)
+	data["grant_type"] = "client_credentials"
+	data["client_id"] = p.clientID
+	data["scope"] = "openid"
+	data["client_secret"] = strings.TrimSpace(string(clientSecret.Data[clientSecretKey]))
+
+	endpoint := string(clientSecret.Data[endpointKey])
+	resp, err := p.httpClient.SendRequest(ctx, "POST", endpoint, data)
+	if err != nil {
+		return result, fmt.Errorf("cannot get access token: %w", err)
+	}
+
+	// parse the access token from the response body
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return result, fmt.Errorf("cannot unmarshal access token: %w", err)
+	}
+
+	if result.AccessToken == "" {
+		return result, fmt.Errorf("token endpoint returned an empty access token")
+	}
+
+	return result, nil
+}
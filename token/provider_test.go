@@ -0,0 +1,199 @@
+/*
+(C) Copyright Hewlett Packard Enterprise Development LP
+*/
+
+package token
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.hpe.com/hpe/sshot-net-operator/fm/fmtest"
+	"github.hpe.com/hpe/sshot-net-operator/httpclient"
+)
+
+var secretName = types.NamespacedName{Namespace: "services", Name: "system-slingshot-client-auth"}
+
+func newTestProvider(t *testing.T) (*fmtest.Server, client.Client, *Provider) {
+	t.Helper()
+
+	server := fmtest.NewServer()
+	t.Cleanup(server.Close)
+
+	k8sClient := fake.NewClientBuilder().WithObjects(&core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: secretName.Namespace, Name: secretName.Name},
+		Data: map[string][]byte{
+			"client-secret": []byte("secret\n"),
+			"endpoint":      []byte(server.TokenURL()),
+		},
+	}).Build()
+
+	return server, k8sClient, NewProvider(k8sClient, httpclient.NewClient(""), "system-slingshot-client", secretName)
+}
+
+func tokenRequests(server *fmtest.Server) int {
+	count := 0
+	for _, r := range server.Requests() {
+		if r == "POST "+fmtest.TokenPath {
+			count++
+		}
+	}
+
+	return count
+}
+
+func TestTokenIsCached(t *testing.T) {
+	ctx := context.Background()
+	server, _, provider := newTestProvider(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			accessToken, err := provider.Token(ctx)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if accessToken != "fmtest-token" {
+				t.Errorf("expected fmtest-token, got %q", accessToken)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := tokenRequests(server); n != 1 {
+		t.Errorf("expected 1 token request, got %d", n)
+	}
+}
+
+func TestTokenRefreshBeforeExpiry(t *testing.T) {
+	ctx := context.Background()
+	server, _, provider := newTestProvider(t)
+
+	now := time.Now()
+	provider.now = func() time.Time { return now }
+
+	if _, err := provider.Token(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// still valid for longer than RefreshBefore
+	now = now.Add(300*time.Second - provider.RefreshBefore - time.Second)
+	if _, err := provider.Token(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := tokenRequests(server); n != 1 {
+		t.Fatalf("expected 1 token request, got %d", n)
+	}
+
+	server.SetAccessToken("fmtest-token-2")
+	now = now.Add(2 * time.Second)
+	accessToken, err := provider.Token(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if accessToken != "fmtest-token-2" {
+		t.Errorf("expected the token to be refreshed, got %q", accessToken)
+	}
+}
+
+func TestTokenWithoutExpiry(t *testing.T) {
+	ctx := context.Background()
+	server, _, provider := newTestProvider(t)
+
+	now := time.Now()
+	provider.now = func() time.Time { return now }
+
+	// a token without expires_in is used for the default lifetime
+	server.TokenExpiresIn = 0
+	for i := 0; i < 3; i++ {
+		if _, err := provider.Token(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if n := tokenRequests(server); n != 1 {
+		t.Fatalf("expected 1 token request, got %d", n)
+	}
+	now = now.Add(DefaultLifetime - provider.RefreshBefore)
+	if _, err := provider.Token(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := tokenRequests(server); n != 2 {
+		t.Fatalf("expected the token to be refreshed, got %d token requests", n)
+	}
+
+	// a token expiring within RefreshBefore is refreshed halfway through its lifetime
+	server.TokenExpiresIn = 10
+	now = now.Add(DefaultLifetime)
+	for i := 0; i < 3; i++ {
+		if _, err := provider.Token(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if n := tokenRequests(server); n != 3 {
+		t.Fatalf("expected 3 token requests, got %d", n)
+	}
+	now = now.Add(5 * time.Second)
+	if _, err := provider.Token(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := tokenRequests(server); n != 4 {
+		t.Errorf("expected the token to be refreshed, got %d token requests", n)
+	}
+}
+
+func TestTokenRefreshOnSecretRotation(t *testing.T) {
+	ctx := context.Background()
+	server, k8sClient, provider := newTestProvider(t)
+
+	if _, err := provider.Token(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var secret core.Secret
+	if err := k8sClient.Get(ctx, secretName, &secret); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	secret.Data["client-secret"] = []byte("rotated")
+	if err := k8sClient.Update(ctx, &secret); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := provider.Token(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := tokenRequests(server); n != 2 {
+		t.Errorf("expected 2 token requests, got %d", n)
+	}
+}
+
+func TestUnauthorizedRetry(t *testing.T) {
+	ctx := context.Background()
+	server, _, provider := newTestProvider(t)
+	server.RequireAuth = true
+
+	fabricClient := httpclient.NewClient(server.URL)
+	fabricClient.TokenSource = provider
+
+	if _, err := fabricClient.SendRequest(ctx, "GET", "/fabric/switches", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the cached token is revoked, the request is retried with a new one
+	server.SetAccessToken("fmtest-token-2")
+	if _, err := fabricClient.SendRequest(ctx, "GET", "/fabric/switches", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := tokenRequests(server); n != 2 {
+		t.Errorf("expected 2 token requests, got %d", n)
+	}
+}